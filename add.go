@@ -0,0 +1,348 @@
+package main
+
+import (
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/modfile"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var addCommand = &cli.Command{
+	Name:      "add",
+	Usage:     "Resolves repositories and adds or updates their require directives in the nearest go.mod",
+	ArgsUsage: "repo-url [repo-url...]",
+	Description: "Versions are selected as when printing require directives, so global flags such as\n" +
+		"--constraint, --stable-only or --branch apply. Existing requirements are updated in place,\n" +
+		"keeping the layout and comments of go.mod. With --import, the versions are taken from a file\n" +
+		"written by grg export-results instead, without querying any repository.\n\n" +
+		"With --workspace, requirements are added to the module of the nearest go.work holding the\n" +
+		"current directory, and --use-local adds modules present anywhere under the workspace root\n" +
+		"to go.work as use directives instead of requiring them.",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "import",
+			Usage: "Adds the results in `FILE`, written by grg export-results or in batch mode, instead of resolving repositories",
+		},
+		&cli.BoolFlag{
+			Name:  "workspace",
+			Usage: "Adds requirements to the module of the nearest go.work holding the current directory",
+		},
+		&cli.BoolFlag{
+			Name:  "use-local",
+			Usage: "Adds modules found under the workspace root as use directives of go.work instead of requiring them (requires --workspace)",
+		},
+		&cli.BoolFlag{
+			Name:  "with-sum",
+			Usage: "Also adds the go.sum lines of the added versions, from the checksum database or computed locally for modules GONOSUMDB exempts",
+		},
+		&cli.BoolFlag{
+			Name:  "tidy",
+			Usage: "Runs go mod tidy once go.mod is written, restoring go.mod and go.sum when it fails",
+		},
+		smokeTestFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		imported := ctx.String("import")
+		if (ctx.NArg() == 0) == (imported == "") {
+			return cli.ShowSubcommandHelp(ctx)
+		}
+		if err := validateSmokeTest(ctx.String("smoke-test")); err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		if ctx.Bool("use-local") && !ctx.Bool("workspace") {
+			return cli.Exit(tr("--use-local requires --workspace"), 1)
+		}
+		var path, workPath string
+		var err error
+		if ctx.Bool("workspace") {
+			if workPath, err = findGoWork("."); err == nil {
+				path, err = workspaceModule(workPath, ".")
+			}
+		} else {
+			path, err = findGoMod(".")
+		}
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+
+		var git *gitRunner
+		var cfg *Config
+		if imported == "" || ctx.Bool("with-sum") {
+			if git, cfg, err = setup(ctx); err != nil {
+				return err
+			}
+		}
+		var resolved []resolution
+		var errs []string
+		if imported != "" {
+			if resolved, errs, err = readBatchResults(imported); err != nil {
+				return cli.Exit(trf("Could not read %s: %s", imported, err), 1)
+			}
+		} else if resolved, errs, err = resolveForAdd(ctx, git, cfg); err != nil {
+			return err
+		}
+		// go.mod is left untouched unless every repository was resolved.
+		if len(errs) > 0 {
+			return cli.Exit(tr("The following errors were found:")+"\n"+strings.Join(errs, "\n"), 1)
+		}
+
+		var work *modfile.WorkFile
+		used := 0
+		if ctx.Bool("use-local") {
+			if work, err = readGoWork(workPath); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			used = len(work.Use)
+			if resolved, err = useLocalModules(work, workPath, resolved); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+		}
+		var sums []string
+		if ctx.Bool("with-sum") {
+			sumdb := &checksummer{cacheDir: ctx.String("cache-dir")}
+			for _, r := range resolved {
+				lines, err := sumdb.sums(ctx.Context, git, r.Path, r.Version)
+				if err != nil {
+					return cli.Exit(trf("Could not obtain the checksums of %s %s: %s", r.Path, r.Version, err), 1)
+				}
+				sums = append(sums, lines...)
+			}
+		}
+		if len(resolved) > 0 {
+			if err = addRequirements(ctx, path, resolved, sums); err != nil {
+				return err
+			}
+		}
+		if work != nil && len(work.Use) > used {
+			if err = writeGoWork(workPath, work); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			fmt.Printf(tr("Wrote %s\n"), workPath)
+		}
+		return nil
+	},
+}
+
+// addRequirements adds or updates the require directives of resolved in the
+// go.mod at path, along with the go.sum lines in sums, running go mod tidy
+// and the smoke test as requested. Errors are returned ready to be handed
+// back to cli.
+func addRequirements(ctx *cli.Context, path string, resolved []resolution, sums []string) error {
+	backup, err := backupGoMod(path)
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+	mod, err := readGoMod(path)
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+	current := map[string]string{}
+	for _, r := range mod.Require {
+		current[r.Mod.Path] = r.Mod.Version
+	}
+	for _, r := range resolved {
+		if err = mod.AddRequire(r.Path, r.Version); err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		switch old, ok := current[r.Path]; {
+		case !ok:
+			fmt.Printf(tr("Added %s %s\n"), r.Path, r.Version)
+		case old == r.Version:
+			fmt.Printf(tr("%s is already required at %s\n"), r.Path, r.Version)
+		default:
+			fmt.Printf(tr("Updated %s from %s to %s\n"), r.Path, old, r.Version)
+		}
+	}
+	if err = writeGoMod(path, mod); err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+	if len(sums) > 0 {
+		if err = addGoSumLines(backup.sumPath(), sums); err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+	}
+
+	if ctx.Bool("tidy") {
+		if err = goModTidy(backup); err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		reportTidied(path, resolved)
+	}
+	if cmd := ctx.String("smoke-test"); cmd != "" {
+		if err = smokeTest(backup, cmd); err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+	}
+	fmt.Printf(tr("Wrote %s\n"), path)
+	return nil
+}
+
+// resolveForAdd resolves the repositories given to grg add, printing the
+// warnings found. Repositories that could not be resolved are returned as
+// errors, in the form they are listed.
+func resolveForAdd(ctx *cli.Context, git *gitRunner, cfg *Config) ([]resolution, []string, error) {
+	opts, err := resolveOptionsFromFlags(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	reqs, err := buildRequests(ctx.Args().Slice(), ctx.StringSlice("module-path"))
+	if err == nil {
+		err = applyRefFlags(reqs, ctx.String("commit"), ctx.String("tag"), ctx.String("branch"))
+	}
+	if err != nil {
+		return nil, nil, cli.Exit(err.Error(), 1)
+	}
+
+	var resolved []resolution
+	var errs []string
+	resolveAligned(ctx.Context, newRunBudget(0), git, cfg, opts, reqs, 1, nil, func(o outcome) {
+		printWarnings(os.Stderr, o.r)
+		if o.err != nil {
+			errs = append(errs, fmt.Sprintf("  %s: %s", o.req.Input, o.err))
+			return
+		}
+		resolved = append(resolved, o.r)
+	})
+	return resolved, errs, nil
+}
+
+// goModTidy runs go mod tidy in the module b was taken from, restoring b
+// when it fails.
+func goModTidy(b *goModBackup) error {
+	c := exec.Command("go", "mod", "tidy")
+	c.Dir = filepath.Dir(b.path)
+	out, err := c.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if rerr := b.restore(); rerr != nil {
+		return fmt.Errorf("go mod tidy failed, and %s could not be restored: %w", b.path, rerr)
+	}
+	return fmt.Errorf("go mod tidy failed, so %s was restored:\n%s", b.path, indent(strings.TrimSpace(string(out))))
+}
+
+// reportTidied tells about added requirements go mod tidy dropped, which it
+// does for modules no package of the module imports yet.
+func reportTidied(path string, resolved []resolution) {
+	mod, err := readGoMod(path)
+	if err != nil {
+		return
+	}
+	kept := map[string]bool{}
+	for _, r := range mod.Require {
+		kept[r.Mod.Path] = true
+	}
+	for _, r := range resolved {
+		if !kept[r.Path] {
+			fmt.Printf(tr("go mod tidy removed %s, as no package imports it yet\n"), r.Path)
+		}
+	}
+}
+
+// workspaceModule returns the go.mod of the member of the workspace defined
+// by the go.work at workPath that holds dir.
+func workspaceModule(workPath, dir string) (string, error) {
+	work, err := readGoWork(workPath)
+	if err != nil {
+		return "", err
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	root := filepath.Dir(workPath)
+	best := ""
+	for _, u := range work.Use {
+		member := filepath.FromSlash(u.Path)
+		if !filepath.IsAbs(member) {
+			member = filepath.Join(root, member)
+		}
+		rel, err := filepath.Rel(member, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if len(member) > len(best) {
+			best = member
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("%s is not inside any module used by %s", dir, workPath)
+	}
+	return filepath.Join(best, "go.mod"), nil
+}
+
+// localModules maps the path of every module found under root to its
+// directory, relative to root in the form go.work uses. Directories the go
+// command ignores, such as vendor, testdata and hidden ones, are skipped.
+func localModules(root string) (map[string]string, error) {
+	mods := map[string]string{}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if p != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "vendor" || name == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != "go.mod" {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		mod := modfile.ModulePath(data)
+		if _, ok := mods[mod]; mod == "" || ok {
+			return nil
+		}
+		rel, err := filepath.Rel(root, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		mods[mod] = "./" + filepath.ToSlash(rel)
+		if rel == "." {
+			mods[mod] = "."
+		}
+		return nil
+	})
+	return mods, err
+}
+
+// useLocalModules adds the modules of resolved present under the root of
+// the workspace defined by work, read from workPath, as its use directives,
+// returning the resolutions left to be required.
+func useLocalModules(work *modfile.WorkFile, workPath string, resolved []resolution) ([]resolution, error) {
+	root := filepath.Dir(workPath)
+	local, err := localModules(root)
+	if err != nil {
+		return nil, err
+	}
+	used := map[string]bool{}
+	for _, u := range work.Use {
+		used[filepath.Join(root, filepath.FromSlash(u.Path))] = true
+	}
+
+	var remaining []resolution
+	for _, r := range resolved {
+		dir, ok := local[r.Path]
+		switch {
+		case !ok:
+			remaining = append(remaining, r)
+		case used[filepath.Join(root, filepath.FromSlash(dir))]:
+			fmt.Printf(tr("%s is already used from %s\n"), r.Path, dir)
+		default:
+			if err = work.AddUse(dir, r.Path); err != nil {
+				return nil, err
+			}
+			fmt.Printf(tr("Using %s from %s instead of requiring %s\n"), r.Path, dir, r.Version)
+		}
+	}
+	return remaining, nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/heyvito/go-require-generator/pkg/input"
+	"golang.org/x/mod/module"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// errNoRelease is returned by releaseAssetListers when the tag has no
+// associated release.
+var errNoRelease = errors.New("no release found")
+
+// releaseAssetLister lists the names of the assets attached to the release
+// of tag in the repository at path.
+type releaseAssetLister func(ctx context.Context, path, tag string) ([]string, error)
+
+// releaseAssetListers holds the hosts whose releases can be inspected.
+var releaseAssetListers = map[string]releaseAssetLister{
+	"github.com": listGitHubReleaseAssets,
+}
+
+func listGitHubReleaseAssets(ctx context.Context, path, tag string) ([]string, error) {
+	u := fmt.Sprintf("https://api.github.com/repos/%s/releases/tags/%s", path, url.PathEscape(tag))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := apiClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(res)
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, errNoRelease
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s fetching release %s of %s", res.Status, tag, path)
+	}
+
+	var release struct {
+		Assets []struct {
+			Name string `json:"name"`
+		} `json:"assets"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&release); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(release.Assets))
+	for i, a := range release.Assets {
+		names[i] = a.Name
+	}
+	return names, nil
+}
+
+// platformAliases lists the names release tooling commonly uses for each
+// GOOS and GOARCH value, besides the value itself.
+var platformAliases = map[string][]string{
+	"darwin":  {"macos", "mac", "osx", "apple"},
+	"windows": {"win"},
+	"amd64":   {"x86_64", "x64", "64bit"},
+	"386":     {"i386", "i686", "32bit"},
+	"arm64":   {"aarch64"},
+	"arm":     {"armv6", "armv7"},
+}
+
+// validatePlatform checks that p has the GOOS/GOARCH form.
+func validatePlatform(p string) error {
+	goos, goarch, ok := strings.Cut(p, "/")
+	if !ok || goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+		return fmt.Errorf("invalid platform %q, expected GOOS/GOARCH", p)
+	}
+	return nil
+}
+
+// assetMatches reports whether an asset named name is likely a binary for
+// platform p, based on the OS and architecture names it contains.
+func assetMatches(name, p string) bool {
+	goos, goarch, _ := strings.Cut(p, "/")
+	// Separators are normalized so names are only matched as whole words,
+	// keeping "arm" from matching "arm64".
+	normalize := strings.NewReplacer("_", "-", ".", "-", " ", "-")
+	words := "-" + normalize.Replace(strings.ToLower(name)) + "-"
+	has := func(value string) bool {
+		for _, want := range append([]string{value}, platformAliases[value]...) {
+			if strings.Contains(words, "-"+normalize.Replace(want)+"-") {
+				return true
+			}
+		}
+		return false
+	}
+	return has(goos) && has(goarch)
+}
+
+// releaseRepo returns the host of the repository at the URL raw, and its
+// path on that host, as releaseAssetListers expect it.
+func releaseRepo(raw string) (string, string) {
+	repo := raw
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return "", ""
+		}
+		repo = u.Path
+	} else if _, p, ok := strings.Cut(raw, ":"); ok {
+		repo = p
+	}
+	return urlHost(raw), strings.TrimSuffix(strings.Trim(repo, "/"), ".git")
+}
+
+// checkReleaseAssets warns about every platform in platforms lacking a
+// prebuilt binary in the release of r.Version, whose tag carries tagPrefix
+// for modules in a monorepo subdirectory. The release is looked up in the
+// repository at repoURL, or, when it is empty as in proxy mode, in the one
+// r.Path names. Repositories on hosts without a releaseAssetLister,
+// versions without a release and API failures are skipped, as prebuilt
+// binaries are only a hint about how to install a tool.
+func checkReleaseAssets(ctx context.Context, git *gitRunner, r *resolution, repoURL, tagPrefix string, platforms []string) {
+	if len(platforms) == 0 || module.IsPseudoVersion(r.Version) {
+		return
+	}
+	host, repo := releaseRepo(repoURL)
+	if repoURL == "" {
+		h, candidates, err := input.RepoRoots(r.Path)
+		if err != nil {
+			return
+		}
+		host, repo = h, candidates[0]
+	}
+	list, ok := releaseAssetListers[host]
+	if !ok {
+		git.logf("Release assets of %s cannot be inspected\n", host)
+		return
+	}
+	assets, err := list(ctx, repo, tagPrefix+versionTag(r.Version))
+	if err != nil {
+		git.logf("Could not list release assets of %s %s: %s\n", r.Path, r.Version, err)
+		return
+	}
+
+	for _, p := range platforms {
+		found := false
+		for _, a := range assets {
+			if assetMatches(a, p) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			r.warn(warnNoPrebuiltBinary, "release %s has no prebuilt binary for %s", r.Version, p)
+		}
+	}
+}
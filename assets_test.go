@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReleaseRepo(t *testing.T) {
+	for _, tt := range []struct {
+		url, host, repo string
+	}{
+		{"https://github.com/owner/repo", "github.com", "owner/repo"},
+		{"https://github.com/owner/repo.git/", "github.com", "owner/repo"},
+		{"ssh://git@github.com:22/owner/repo.git", "github.com", "owner/repo"},
+		{"git@github.com:owner/repo.git", "github.com", "owner/repo"},
+	} {
+		host, repo := releaseRepo(tt.url)
+		if host != tt.host || repo != tt.repo {
+			t.Errorf("releaseRepo(%q) = %s, %s, want %s, %s", tt.url, host, repo, tt.host, tt.repo)
+		}
+	}
+}
+
+func TestCheckReleaseAssetsVanityPath(t *testing.T) {
+	var queried []string
+	saved := releaseAssetListers["github.com"]
+	releaseAssetListers["github.com"] = func(ctx context.Context, path, tag string) ([]string, error) {
+		queried = append(queried, path+"@"+tag)
+		return []string{"tool_linux_amd64.tar.gz"}, nil
+	}
+	defer func() { releaseAssetListers["github.com"] = saved }()
+
+	// The module is served through a go-import meta tag by a repository
+	// named unlike its path.
+	r := resolution{Path: "go.example.org/tool", Version: "v1.2.0"}
+	checkReleaseAssets(context.Background(), &gitRunner{}, &r, "https://github.com/owner/tool-src.git", "cmd/", []string{"linux/amd64", "darwin/arm64"})
+	if len(queried) != 1 || queried[0] != "owner/tool-src@cmd/v1.2.0" {
+		t.Fatalf("checkReleaseAssets() queried %v, want [owner/tool-src@cmd/v1.2.0]", queried)
+	}
+	if len(r.Warnings) != 1 || r.Warnings[0].Code != warnNoPrebuiltBinary {
+		t.Errorf("checkReleaseAssets() warned %+v, want a missing darwin/arm64 binary", r.Warnings)
+	}
+}
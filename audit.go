@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+var auditCommand = &cli.Command{
+	Name:  "audit",
+	Usage: "Reports outdated and vulnerable requirements of the current module or workspace",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "workspace",
+			Usage: "Audits every module listed in the nearest go.work file as a whole",
+		},
+		modfileFlag,
+		changedOnlyFlag,
+		changedBaseFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		if ctx.Bool("workspace") && ctx.IsSet("modfile") {
+			return cli.Exit(tr("--workspace cannot be combined with --modfile"), 1)
+		}
+
+		var members []*modfile.File
+		if ctx.Bool("workspace") {
+			path, err := findGoWork(".")
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			if members, err = readWorkspace(path); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+		} else {
+			path, err := goModPath(ctx)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			f, err := readGoMod(path)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			members = []*modfile.File{f}
+		}
+
+		git, cfg, err := setup(ctx)
+		if err != nil {
+			return err
+		}
+
+		audited := members
+		if ctx.Bool("changed-only") {
+			if audited, err = changedGoMods(ctx.Context, git, members, ctx.String("base")); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			if len(audited) == 0 {
+				fmt.Printf(tr("No go.mod changed since %s\n"), ctx.String("base"))
+				return nil
+			}
+		}
+
+		deps := collectRequirements(audited, members)
+		if len(deps) == 0 {
+			fmt.Println(tr("No requirements found"))
+			return nil
+		}
+
+		vulnerable := false
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, tr("MODULE\tREQUIRED\tLATEST\tVULNERABILITIES\tUSED BY"))
+		for _, d := range deps {
+			row := auditRow(ctx.Context, git, cfg, d)
+			if row.vulnerable {
+				vulnerable = true
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", d.Path, strings.Join(d.versions(), ", "), row.latest, row.vulns, strings.Join(d.users(), ", "))
+		}
+		if err = w.Flush(); err != nil {
+			return err
+		}
+
+		if vulnerable {
+			return cli.Exit("", 1)
+		}
+		return nil
+	},
+}
+
+// findGoWork walks up from dir looking for the nearest go.work file.
+func findGoWork(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		p := filepath.Join(dir, "go.work")
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("could not find a go.work file in the current directory or any of its parents")
+		}
+		dir = parent
+	}
+}
+
+// readGoWork parses the go.work file at path.
+func readGoWork(path string) (*modfile.WorkFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	work, err := modfile.ParseWork(path, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing %s: %w", path, err)
+	}
+	return work, nil
+}
+
+// readWorkspace parses the go.work file at path and returns the go.mod files
+// of all of its members.
+func readWorkspace(path string) ([]*modfile.File, error) {
+	work, err := readGoWork(path)
+	if err != nil {
+		return nil, err
+	}
+
+	root := filepath.Dir(path)
+	members := make([]*modfile.File, 0, len(work.Use))
+	for _, u := range work.Use {
+		dir := filepath.FromSlash(u.Path)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(root, dir)
+		}
+		f, err := readGoMod(filepath.Join(dir, "go.mod"))
+		if err != nil {
+			return nil, fmt.Errorf("workspace member %s: %w", u.Path, err)
+		}
+		members = append(members, f)
+	}
+	return members, nil
+}
+
+// requirement aggregates every version of a module required across a set of
+// go.mod files, along with the modules requiring each of them.
+type requirement struct {
+	Path string
+	// By maps each required version to the modules requiring it.
+	By map[string][]string
+}
+
+// versions returns the required versions in ascending semver order.
+func (r requirement) versions() []string {
+	vs := make([]string, 0, len(r.By))
+	for v := range r.By {
+		vs = append(vs, v)
+	}
+	semver.Sort(vs)
+	return vs
+}
+
+// users returns the modules requiring r, sorted and without duplicates.
+func (r requirement) users() []string {
+	seen := map[string]bool{}
+	var users []string
+	for _, by := range r.By {
+		for _, m := range by {
+			if !seen[m] {
+				seen[m] = true
+				users = append(users, m)
+			}
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+// collectRequirements merges the requirements of members, sorted by module
+// path. Modules of the workspace, whose members are given in workspace, are
+// left out, since the workspace resolves them from disk.
+func collectRequirements(members, workspace []*modfile.File) []requirement {
+	local := map[string]bool{}
+	for _, f := range workspace {
+		if f.Module != nil {
+			local[f.Module.Mod.Path] = true
+		}
+	}
+
+	byPath := map[string]*requirement{}
+	for _, f := range members {
+		name := "(unnamed module)"
+		if f.Module != nil {
+			name = f.Module.Mod.Path
+		}
+		for _, r := range f.Require {
+			if local[r.Mod.Path] {
+				continue
+			}
+			req, ok := byPath[r.Mod.Path]
+			if !ok {
+				req = &requirement{Path: r.Mod.Path, By: map[string][]string{}}
+				byPath[r.Mod.Path] = req
+			}
+			req.By[r.Mod.Version] = append(req.By[r.Mod.Version], name)
+		}
+	}
+
+	result := make([]requirement, 0, len(byPath))
+	for _, r := range byPath {
+		result = append(result, *r)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result
+}
+
+// auditResult holds the columns of an audit report row.
+type auditResult struct {
+	latest     string
+	vulns      string
+	vulnerable bool
+}
+
+// auditRow resolves the latest version of r and queries vulnerabilities for
+// each of its required versions. Failures are reported as "?" so a single
+// unreachable repository does not abort the whole report.
+func auditRow(ctx context.Context, git *gitRunner, cfg *Config, r requirement) auditResult {
+	res := auditResult{latest: "?", vulns: "?"}
+	versions := r.versions()
+	current := versions[len(versions)-1]
+
+	// Other major versions are other modules, whose path has another /vN
+	// suffix, so the latest version is looked up within the required one.
+	repoPath, _, _ := module.SplitPathVersion(r.Path)
+	latest, err := processRepo(ctx, git, cfg, updateOptions(resolveOptions{}, current, "minor"), repoRequest{Input: r.Path, ModulePath: repoPath})
+	if err != nil {
+		git.logf("Could not resolve %s: %s\n", r.Path, err)
+	} else if semver.Major(latest.Version) == semver.Major(current) && semver.Compare(latest.Version, current) > 0 {
+		res.latest = latest.Version
+	} else {
+		res.latest = "✓"
+	}
+
+	var found []string
+	for _, v := range versions {
+		vulns, err := queryVulnerabilities(ctx, r.Path, v)
+		if err != nil {
+			git.logf("Could not query vulnerabilities for %s: %s\n", r.Path, err)
+			return res
+		}
+		for _, vuln := range vulns {
+			found = append(found, fmt.Sprintf("%s (%s)", vuln.ID, v))
+		}
+	}
+	if len(found) == 0 {
+		res.vulns = "none"
+	} else {
+		res.vulns = strings.Join(found, ", ")
+		res.vulnerable = true
+	}
+	return res
+}
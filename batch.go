@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// defaultBatchLimit is the default maximum number of repositories accepted in
+// a single batch.
+const defaultBatchLimit = 500
+
+// batchResult is a single NDJSON record emitted in batch mode.
+type batchResult struct {
+	Input    string    `json:"input"`
+	Path     string    `json:"path,omitempty"`
+	Version  string    `json:"version,omitempty"`
+	Note     string    `json:"note,omitempty"`
+	Require  string    `json:"require,omitempty"`
+	Warnings []warning `json:"warnings,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// readBatch reads repository arguments from path, one per line, ignoring
+// blank lines and lines starting with #. A path of "-" reads from stdin.
+func readBatch(path string) ([]string, error) {
+	var r io.Reader
+	if path == "-" {
+		r = os.Stdin
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+
+	var args []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		args = append(args, line)
+	}
+	return args, scanner.Err()
+}
+
+// shareSSHConnections configures git to multiplex SSH connections per host
+// through a control master, so a batch touching many repositories on the same
+// host only pays for one SSH handshake. It returns a cleanup function. Users
+// providing their own GIT_SSH_COMMAND or GIT_SSH are left untouched.
+func shareSSHConnections(git *gitRunner) func() {
+	noop := func() {}
+	if runtime.GOOS == "windows" || os.Getenv("GIT_SSH_COMMAND") != "" || os.Getenv("GIT_SSH") != "" {
+		return noop
+	}
+
+	dir, err := os.MkdirTemp("", "grg-ssh-")
+	if err != nil {
+		git.logf("Could not create SSH control directory: %s\n", err)
+		return noop
+	}
+
+	control := filepath.Join(dir, "%C")
+	git.env = append(git.env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -o ControlMaster=auto -o ControlPath=%s -o ControlPersist=30s", control))
+	return func() { _ = os.RemoveAll(dir) }
+}
+
+// writeBatchResult emits a single NDJSON record for a processed repository.
+func writeBatchResult(w io.Writer, req repoRequest, r resolution, err error) {
+	res := batchResult{Input: req.Input}
+	if err != nil {
+		res.Error = err.Error()
+	} else {
+		res.Path = r.Path
+		res.Version = r.Version
+		res.Note = r.Note
+		res.Require = r.requireLine()
+		res.Warnings = r.Warnings
+	}
+	data, _ := json.Marshal(res)
+	_, _ = fmt.Fprintf(w, "%s\n", data)
+}
+
+// readBatchResults reads the NDJSON records written in batch mode or by grg
+// export-results from path, or from stdin when path is "-". Records of
+// failed repositories are returned as errors, in the form resolution errors
+// are listed.
+func readBatchResults(path string) ([]resolution, []string, error) {
+	var r io.Reader
+	if path == "-" {
+		r = os.Stdin
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+
+	var results []resolution
+	var errs []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var res batchResult
+		if err := json.Unmarshal(scanner.Bytes(), &res); err != nil {
+			return nil, nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		switch {
+		case res.Error != "":
+			errs = append(errs, fmt.Sprintf("  %s: %s", res.Input, res.Error))
+		case res.Path == "" || res.Version == "":
+			return nil, nil, fmt.Errorf("%s:%d: record of %s has no path or version", path, line, res.Input)
+		default:
+			results = append(results, resolution{Path: res.Path, Version: res.Version, Note: res.Note, Warnings: res.Warnings})
+		}
+	}
+	return results, errs, scanner.Err()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errTimedOut is returned for repositories that could not be resolved
+// within their share of --deadline.
+var errTimedOut = errors.New("timed out")
+
+// errInterrupted is returned for repositories left unresolved when grg is
+// interrupted.
+var errInterrupted = errors.New("interrupted")
+
+// runBudget shares the time allowed for an invocation across the
+// repositories it resolves. Each repository gets an equal share of the time
+// left, so time saved by fast repositories benefits the ones after them.
+type runBudget struct {
+	// end is when the invocation must complete. Zero means no deadline.
+	end time.Time
+}
+
+func newRunBudget(d time.Duration) runBudget {
+	if d <= 0 {
+		return runBudget{}
+	}
+	return runBudget{end: time.Now().Add(d)}
+}
+
+// expired reports whether the whole budget has been used.
+func (b runBudget) expired() bool {
+	return !b.end.IsZero() && !time.Now().Before(b.end)
+}
+
+// process resolves req within its share of the budget, recording the time
+// spent in each stage in the returned resolution. Once the budget is used,
+// remaining requests fail immediately with errTimedOut, and once git's
+// context is cancelled, with errInterrupted.
+func (b runBudget) process(ctx context.Context, git *gitRunner, cfg *Config, opts resolveOptions, req repoRequest, left int) (resolution, error) {
+	if err := contextErr(ctx); err != nil {
+		return resolution{}, err
+	}
+	if b.expired() {
+		return resolution{}, errTimedOut
+	}
+	g := *git
+	g.timings = newStageTimings()
+	if !b.end.IsZero() {
+		// Each repository gets an equal share of the time left.
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadlineCause(ctx, time.Now().Add(time.Until(b.end)/time.Duration(left)), errTimedOut)
+		defer cancel()
+	}
+	r, err := processRepo(ctx, &g, cfg, opts, req)
+	if cerr := contextErr(ctx); err != nil && cerr != nil {
+		return resolution{}, cerr
+	}
+	r.Timings = g.timings
+	return r, err
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/mod/module"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultCacheDir returns the default location of grg's persistent cache.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "grg")
+}
+
+// moduleCacheEntry is the persistent record grg keeps for each module.
+type moduleCacheEntry struct {
+	Module string `json:"module"`
+	// Tags maps every tag seen for the module to the commit it pointed to
+	// when it was first seen (or last accepted as moved).
+	Tags     map[string]string `json:"tags"`
+	LastUsed time.Time         `json:"lastUsed"`
+	// Hits counts the times the entry was found in the cache.
+	Hits int `json:"hits,omitempty"`
+}
+
+// moduleCache stores moduleCacheEntry values as JSON files under a
+// directory, one per module.
+type moduleCache struct {
+	dir string
+}
+
+func (c moduleCache) entryPath(mod string) (string, error) {
+	escaped, err := module.EscapePath(mod)
+	if err != nil {
+		// Local module paths may not be valid import paths; fall back to
+		// a plain escaping that is still safe on every filesystem.
+		escaped = filepath.ToSlash(filepath.Clean(mod))
+	}
+	return filepath.Join(c.dir, "modules", filepath.FromSlash(escaped)+".json"), nil
+}
+
+// load reads the entry for mod, returning an empty entry when none exists.
+func (c moduleCache) load(mod string) (*moduleCacheEntry, error) {
+	entry := &moduleCacheEntry{Module: mod, Tags: map[string]string{}}
+	p, err := c.entryPath(mod)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return entry, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, entry); err != nil {
+		return nil, fmt.Errorf("corrupted cache entry %s: %w", p, err)
+	}
+	if entry.Tags == nil {
+		entry.Tags = map[string]string{}
+	}
+	entry.Hits++
+	return entry, nil
+}
+
+// entries returns every module entry in the cache, along with the size of
+// its file. Unreadable entries are reported and skipped.
+func (c moduleCache) entries() ([]*moduleCacheEntry, []int64, error) {
+	var entries []*moduleCacheEntry
+	var sizes []int64
+	err := filepath.WalkDir(filepath.Join(c.dir, "modules"), func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return filepath.SkipDir
+		}
+		if err != nil || !d.Type().IsRegular() || filepath.Ext(p) != ".json" {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		entry := &moduleCacheEntry{}
+		if err = json.Unmarshal(data, entry); err != nil || entry.Module == "" {
+			warnf(tr("skipping corrupted cache entry %s"), p)
+			return nil
+		}
+		entries = append(entries, entry)
+		sizes = append(sizes, int64(len(data)))
+		return nil
+	})
+	return entries, sizes, err
+}
+
+// remove deletes the entry for mod, failing with os.ErrNotExist when there
+// is none.
+func (c moduleCache) remove(mod string) error {
+	p, err := c.entryPath(mod)
+	if err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
+
+// save atomically writes entry, updating its last use time.
+func (c moduleCache) save(entry *moduleCacheEntry) error {
+	p, err := c.entryPath(entry.Module)
+	if err != nil {
+		return err
+	}
+	entry.LastUsed = time.Now().UTC()
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, data)
+}
+
+// writeFileAtomic writes data to p through a temporary file, so concurrent
+// readers never observe a partially written file.
+func writeFileAtomic(p string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".entry-*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Close()
+	} else {
+		_ = tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), p)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/heyvito/go-require-generator/pkg/input"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/module"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var cacheCommand = &cli.Command{
+	Name:  "cache",
+	Usage: "Manages grg's persistent cache",
+	Subcommands: []*cli.Command{
+		cacheLsCommand,
+		cacheStatsCommand,
+		cacheRmCommand,
+		cacheGCCommand,
+	},
+}
+
+// cacheDirFlag returns the cache directory selected by --cache-dir, failing
+// when the cache is disabled.
+func cacheDirFlag(ctx *cli.Context) (string, error) {
+	dir := ctx.String("cache-dir")
+	if dir == "" {
+		return "", cli.Exit(tr("The cache is disabled"), 1)
+	}
+	return dir, nil
+}
+
+var cacheLsCommand = &cli.Command{
+	Name:  "ls",
+	Usage: "Lists the modules and hosts in the cache",
+	Action: func(ctx *cli.Context) error {
+		dir, err := cacheDirFlag(ctx)
+		if err != nil {
+			return err
+		}
+		entries, sizes, err := moduleCache{dir: dir}.entries()
+		if err != nil {
+			return cli.Exit(trf("Could not read the cache: %s", err), 1)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, tr("MODULE\tTAGS\tHITS\tLAST USED\tSIZE"))
+		for i, e := range entries {
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", e.Module, len(e.Tags), e.Hits, e.LastUsed.Local().Format(time.DateTime), formatByteSize(sizes[i]))
+		}
+		_ = w.Flush()
+
+		hosts := cachedHosts(dir)
+		if len(hosts) > 0 {
+			fmt.Println()
+			w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, tr("HOST\tPROTOCOL\tMEASURED"))
+			for _, h := range hosts {
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", h.Host, h.Preferred, h.Measured.Local().Format(time.DateTime))
+			}
+			_ = w.Flush()
+		}
+		return nil
+	},
+}
+
+var cacheStatsCommand = &cli.Command{
+	Name:  "stats",
+	Usage: "Summarizes the size and effectiveness of the cache",
+	Action: func(ctx *cli.Context) error {
+		dir, err := cacheDirFlag(ctx)
+		if err != nil {
+			return err
+		}
+		entries, _, err := moduleCache{dir: dir}.entries()
+		if err != nil {
+			return cli.Exit(trf("Could not read the cache: %s", err), 1)
+		}
+		files, err := cacheFiles(dir)
+		if err != nil {
+			return cli.Exit(trf("Could not read the cache: %s", err), 1)
+		}
+
+		// Every entry was a miss the first time its module was looked up.
+		hits, tags := 0, 0
+		for _, e := range entries {
+			hits += e.Hits
+			tags += len(e.Tags)
+		}
+		lookups := hits + len(entries)
+
+		fmt.Printf(tr("Directory:  %s\n"), dir)
+		fmt.Printf(tr("Size:       %s in %d files\n"), formatByteSize(totalSize(files)), len(files))
+		fmt.Printf(tr("Modules:    %d, recording %d tags\n"), len(entries), tags)
+		fmt.Printf(tr("Hosts:      %d\n"), len(cachedHosts(dir)))
+		if lookups > 0 {
+			fmt.Printf(tr("Hit rate:   %.1f%% (%d of %d lookups)\n"), float64(hits)*100/float64(lookups), hits, lookups)
+		}
+		return nil
+	},
+}
+
+var cacheRmCommand = &cli.Command{
+	Name:  "rm",
+	Usage: "Removes everything cached about the given modules",
+	Description: "The tag history, the registry record and the cached HTTP responses of each module, such as\n" +
+		"proxy, checksum database and release lookups, are removed, so the next run resolves it afresh.",
+	ArgsUsage: "<module>...",
+	Action: func(ctx *cli.Context) error {
+		dir, err := cacheDirFlag(ctx)
+		if err != nil {
+			return err
+		}
+		if ctx.NArg() == 0 {
+			return cli.Exit(tr("No modules given"), 1)
+		}
+		reg, err := loadRegistry(dir)
+		if err != nil {
+			return cli.Exit(trf("Could not read the registry: %s", err), 1)
+		}
+		cache := moduleCache{dir: dir}
+		failed, unregistered := false, false
+		for _, mod := range ctx.Args().Slice() {
+			removed := false
+			switch err := cache.remove(mod); {
+			case err == nil:
+				removed = true
+			case !errors.Is(err, os.ErrNotExist):
+				warnf(tr("could not remove %s: %s"), mod, err)
+				failed = true
+				continue
+			}
+			if _, ok := reg.Modules[mod]; ok {
+				delete(reg.Modules, mod)
+				removed, unregistered = true, true
+			}
+			n, err := removeCachedResponses(filepath.Join(dir, "http"), mod)
+			if err != nil {
+				warnf(tr("could not remove %s: %s"), mod, err)
+				failed = true
+				continue
+			}
+			if removed || n > 0 {
+				fmt.Printf(tr("removed %s\n"), mod)
+			} else {
+				warnf(tr("%s is not cached"), mod)
+			}
+		}
+		if unregistered {
+			if err = reg.save(dir); err != nil {
+				return cli.Exit(trf("Could not write the registry: %s", err), 1)
+			}
+		}
+		if failed {
+			return cli.Exit("", 1)
+		}
+		return nil
+	},
+}
+
+// removeCachedResponses deletes the HTTP responses cached under dir that
+// concern mod, returning how many were removed.
+func removeCachedResponses(dir, mod string) (int, error) {
+	files, err := cacheFiles(dir)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, f := range files {
+		data, err := os.ReadFile(f.path)
+		if err != nil {
+			return removed, err
+		}
+		var res cachedResponse
+		if json.Unmarshal(data, &res) != nil || !responseConcerns(res.URL, mod) {
+			continue
+		}
+		if err = os.Remove(f.path); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// responseConcerns reports whether the response to the request for raw was
+// about mod: proxy and checksum database lookups name its escaped path,
+// go-import discovery its path, and the GitHub API its repository.
+func responseConcerns(raw, mod string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	target := u.Host + u.Path
+	paths := []string{mod}
+	if escaped, err := module.EscapePath(mod); err == nil && escaped != mod {
+		paths = append(paths, escaped)
+	}
+	for _, p := range paths {
+		if target == p || strings.Contains(target, "/"+p+"/@") || strings.Contains(target, "/"+p+"@") {
+			return true
+		}
+	}
+	host, candidates, err := input.RepoRoots(mod)
+	return err == nil && host == "github.com" && u.Host == "api.github.com" && strings.HasPrefix(u.Path+"/", "/repos/"+candidates[0]+"/")
+}
+
+// cachedHosts returns the protocol preferences recorded in the cache.
+func cachedHosts(dir string) []hostProtocol {
+	paths, _ := filepath.Glob(filepath.Join(dir, "hosts", "*.json"))
+	var hosts []hostProtocol
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		var h hostProtocol
+		if json.Unmarshal(data, &h) == nil && h.Host != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+var cacheGCCommand = &cli.Command{
+	Name:  "gc",
+	Usage: "Evicts cache entries not used recently, least recently used first",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "max-size",
+			Usage: "Evicts the least recently used entries until the cache fits in this size, such as 500M or 5G",
+		},
+		&cli.StringFlag{
+			Name:  "max-age",
+			Usage: "Evicts entries not used for this long, such as 36h, 30d or 2w",
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Lists the entries that would be evicted without removing them",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if !ctx.IsSet("max-size") && !ctx.IsSet("max-age") {
+			return cli.Exit(tr("At least one of --max-size or --max-age is required"), 1)
+		}
+		var maxSize int64 = -1
+		if ctx.IsSet("max-size") {
+			n, err := parseByteSize(ctx.String("max-size"))
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			maxSize = n
+		}
+		var maxAge time.Duration
+		if ctx.IsSet("max-age") {
+			d, err := parseAge(ctx.String("max-age"))
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			maxAge = d
+		}
+
+		dir, err := cacheDirFlag(ctx)
+		if err != nil {
+			return err
+		}
+		entries, err := cacheFiles(dir)
+		if err != nil {
+			return cli.Exit(trf("Could not read the cache: %s", err), 1)
+		}
+
+		evict, kept := gcPlan(entries, maxSize, maxAge, time.Now())
+		var freed int64
+		for _, e := range evict {
+			if !ctx.Bool("dry-run") {
+				if err := os.Remove(e.path); err != nil {
+					warnf(tr("could not remove %s: %s"), e.path, err)
+					continue
+				}
+			}
+			freed += e.size
+			rel, _ := filepath.Rel(dir, e.path)
+			fmt.Printf(tr("evicted %s (last used %s)\n"), rel, e.lastUsed.Format(time.DateOnly))
+		}
+		summary := tr("Freed %s; %d entries remain, using %s\n")
+		if ctx.Bool("dry-run") {
+			summary = tr("Would free %s; %d entries remain, using %s\n")
+		}
+		fmt.Printf(summary, formatByteSize(freed), len(kept), formatByteSize(totalSize(kept)))
+		return nil
+	},
+}
+
+// cacheFile is a single file of the persistent cache.
+type cacheFile struct {
+	path string
+	size int64
+	// lastUsed is the time the entry was last written. Entries are rewritten
+	// whenever they are used, so this is also when they were last used.
+	lastUsed time.Time
+}
+
+// cacheFiles lists the regular files under the cache directory dir. A
+// missing directory is an empty cache.
+func cacheFiles(dir string) ([]cacheFile, error) {
+	var files []cacheFile
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, cacheFile{path: p, size: info.Size(), lastUsed: info.ModTime()})
+		return nil
+	})
+	return files, err
+}
+
+// gcPlan splits files into those to evict and those to keep. Files unused
+// for longer than maxAge are evicted first, then the least recently used ones
+// until the rest fits in maxSize. Zero maxAge and negative maxSize disable the
+// respective limit.
+func gcPlan(files []cacheFile, maxSize int64, maxAge time.Duration, now time.Time) (evict, keep []cacheFile) {
+	sorted := append([]cacheFile{}, files...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].lastUsed.Before(sorted[j].lastUsed) })
+
+	size := totalSize(sorted)
+	for i, f := range sorted {
+		expired := maxAge > 0 && now.Sub(f.lastUsed) > maxAge
+		oversized := maxSize >= 0 && size > maxSize
+		if !expired && !oversized {
+			return evict, sorted[i:]
+		}
+		evict = append(evict, f)
+		size -= f.size
+	}
+	return evict, nil
+}
+
+func totalSize(files []cacheFile) int64 {
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	return total
+}
+
+// parseAge parses durations as accepted by time.ParseDuration, plus whole
+// days (30d) and weeks (2w).
+func parseAge(s string) (time.Duration, error) {
+	v := strings.TrimSpace(s)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(v, suffix); ok {
+			days, err := strconv.Atoi(n)
+			if err != nil || days < 0 {
+				return 0, fmt.Errorf("invalid age %q", s)
+			}
+			return time.Duration(days) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
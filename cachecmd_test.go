@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestResponseConcerns(t *testing.T) {
+	for _, tt := range []struct {
+		url, mod string
+		want     bool
+	}{
+		{"https://proxy.golang.org/github.com/!owner/repo/@v/list", "github.com/Owner/repo", true},
+		{"https://proxy.golang.org/github.com/owner/repo/@latest", "github.com/owner/repo", true},
+		{"https://proxy.golang.org/github.com/owner/repo/v2/@v/list", "github.com/owner/repo", false},
+		{"https://sum.golang.org/lookup/github.com/owner/repo@v1.2.0", "github.com/owner/repo", true},
+		{"https://go.example.org/tool?go-get=1", "go.example.org/tool", true},
+		{"https://api.github.com/repos/owner/repo/releases/tags/v1.2.0", "github.com/owner/repo/sub", true},
+		{"https://api.github.com/repos/owner/repository/releases/tags/v1.2.0", "github.com/owner/repo", false},
+	} {
+		if got := responseConcerns(tt.url, tt.mod); got != tt.want {
+			t.Errorf("responseConcerns(%s, %s) = %v, want %v", tt.url, tt.mod, got, tt.want)
+		}
+	}
+}
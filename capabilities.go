@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// schemaVersions lists the version of each machine-readable format grg reads
+// or writes. A version is raised whenever a format changes in a way that
+// could break existing consumers; new optional fields do not count.
+var schemaVersions = map[string]string{
+	// batch-result covers the NDJSON records of --batch and
+	// export-results, which add --import reads back.
+	"batch-result": "1",
+	"run-manifest": "1",
+	"registry":     "1",
+	"outdated":     "1",
+	"capabilities": "1",
+	"sarif":        "2.1.0",
+}
+
+// resolutionBackends lists the sources versions can be resolved from.
+var resolutionBackends = []string{
+	// git clones or queries repositories over ssh or https.
+	"git",
+	// proxy picks versions from the module proxies in GOPROXY, with --proxy.
+	"proxy",
+	// registry answers from the modules recorded by grg registry sync.
+	"registry",
+	// sumdb looks up go.sum lines in the checksum database, with --with-sum.
+	"sumdb",
+}
+
+// capabilities describes what this build of grg supports.
+type capabilities struct {
+	Version        string            `json:"version"`
+	Backends       []string          `json:"backends"`
+	Formats        []string          `json:"formats"`
+	VersionSchemes []string          `json:"versionSchemes"`
+	Commands       []string          `json:"commands"`
+	Schemas        map[string]string `json:"schemas"`
+}
+
+var capabilitiesCommand = &cli.Command{
+	Name:  "capabilities",
+	Usage: "Lists the backends, output formats, commands and schema versions of this build",
+	Description: "Wrapper tooling should check for what it needs here instead of comparing versions of grg.\n" +
+		"Commands are listed with their parent commands, as in registry sync.",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "Prints the capabilities as a JSON object",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		c := capabilities{
+			Version:        buildVersion(),
+			Backends:       resolutionBackends,
+			Formats:        outputFormatNames(),
+			VersionSchemes: []string{"semver", "calver", "regexp"},
+			Commands:       commandNames("", ctx.App.Commands),
+			Schemas:        schemaVersions,
+		}
+		if ctx.Bool("json") {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(c)
+		}
+
+		schemas := make([]string, 0, len(c.Schemas))
+		for name, v := range c.Schemas {
+			schemas = append(schemas, name+" "+v)
+		}
+		sort.Strings(schemas)
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "version\t%s\n", c.Version)
+		_, _ = fmt.Fprintf(w, "backends\t%s\n", strings.Join(c.Backends, ", "))
+		_, _ = fmt.Fprintf(w, "formats\t%s\n", strings.Join(c.Formats, ", "))
+		_, _ = fmt.Fprintf(w, "version schemes\t%s\n", strings.Join(c.VersionSchemes, ", "))
+		_, _ = fmt.Fprintf(w, "commands\t%s\n", strings.Join(c.Commands, ", "))
+		_, _ = fmt.Fprintf(w, "schemas\t%s\n", strings.Join(schemas, ", "))
+		return w.Flush()
+	},
+}
+
+// buildVersion returns the version grg was built at, as recorded by the go
+// command, which is (devel) for builds from a working tree.
+func buildVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// commandNames returns the names of cmds and their subcommands, prefixed by
+// the names of their parents, sorted. Hidden commands and help are left out.
+func commandNames(parent string, cmds []*cli.Command) []string {
+	var names []string
+	for _, c := range cmds {
+		if c.Hidden || c.Name == "help" {
+			continue
+		}
+		name := strings.TrimSpace(parent + " " + c.Name)
+		names = append(names, name)
+		names = append(names, commandNames(name, c.Subcommands)...)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"os"
+	"path/filepath"
+)
+
+var checkCommand = &cli.Command{
+	Name:  "check",
+	Usage: "Checks the requirements of the current go.mod against the configured policy and known vulnerabilities",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "vulnerabilities",
+			Usage: "Also reports known vulnerabilities affecting the required versions",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "Output format: text or sarif",
+			Value: "text",
+		},
+		modfileFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		format := ctx.String("format")
+		if format != "text" && format != "sarif" {
+			return cli.Exit(trf("Unknown format %q", format), 1)
+		}
+
+		cfg, err := loadConfig(ctx.String("config"), ctx.IsSet("config"))
+		if err != nil {
+			return cli.Exit(trf("Could not load configuration: %s", err), 1)
+		}
+		path, err := goModPath(ctx)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		f, err := readGoMod(path)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+
+		// SARIF locations are relative to the directory grg runs from.
+		file := path
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, path); err == nil {
+				file = filepath.ToSlash(rel)
+			}
+		}
+
+		var findings []finding
+		for _, r := range f.Require {
+			line := 0
+			if r.Syntax != nil {
+				line = r.Syntax.Start.Line
+			}
+			for _, v := range cfg.Policy.check(r.Mod.Path, r.Mod.Version) {
+				rule, desc := "policy-floor", "Required version is below the policy floor"
+				if v.Ceiling {
+					rule, desc = "policy-ceiling", "Required version is above the policy ceiling"
+				}
+				findings = append(findings, finding{RuleID: rule, Description: desc, Level: "error", Message: v.Error(), File: file, Line: line})
+			}
+
+			if !ctx.Bool("vulnerabilities") {
+				continue
+			}
+			vulns, err := queryVulnerabilities(ctx.Context, r.Mod.Path, r.Mod.Version)
+			if err != nil {
+				return cli.Exit(trf("Could not query vulnerabilities: %s", err), 1)
+			}
+			for _, v := range vulns {
+				msg := fmt.Sprintf("%s %s is affected by %s", r.Mod.Path, r.Mod.Version, v.ID)
+				if v.Summary != "" {
+					msg += ": " + v.Summary
+				}
+				findings = append(findings, finding{
+					RuleID:      v.ID,
+					Description: v.Summary,
+					Level:       "error",
+					Message:     msg,
+					HelpURI:     "https://osv.dev/vulnerability/" + v.ID,
+					File:        file,
+					Line:        line,
+				})
+			}
+		}
+
+		if format == "sarif" {
+			if err = writeSARIF(os.Stdout, findings); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+		} else if len(findings) == 0 {
+			fmt.Println(tr("No issues found"))
+		} else {
+			fmt.Println(tr("The following issues were found:"))
+			for _, f := range findings {
+				fmt.Printf("  %s\n", f.Message)
+			}
+		}
+
+		if len(findings) > 0 {
+			return cli.Exit("", 1)
+		}
+		return nil
+	},
+}
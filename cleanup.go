@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+var cleanupCommand = &cli.Command{
+	Name:  "cleanup",
+	Usage: "Finds stale replace directives, obsolete excludes and duplicate requires in the current go.mod",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "fix",
+			Usage: "Removes the reported directives from go.mod",
+		},
+		modfileFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		if ctx.Bool("fix") && ctx.IsSet("modfile") {
+			return cli.Exit(tr("--fix cannot be combined with --modfile, which is meant for read-only analysis"), 1)
+		}
+		path, err := goModPath(ctx)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		f, err := readGoMod(path)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+
+		git, cfg, err := setup(ctx)
+		if err != nil {
+			return err
+		}
+
+		var fixes []func()
+		report := func(format string, args ...any) {
+			fmt.Printf("  "+format+"\n", args...)
+		}
+
+		dupes, keep := duplicateRequires(f)
+		if len(dupes) > 0 {
+			fmt.Println(tr("Duplicate requires:"))
+			for _, p := range dupes {
+				p, v, indirect := p, keep[p].Mod.Version, keep[p].Indirect
+				report("%s is required more than once; keeping %s", p, v)
+				fixes = append(fixes, func() {
+					_ = f.DropRequire(p)
+					f.AddNewRequire(p, v, indirect)
+				})
+			}
+		}
+
+		if excludes := obsoleteExcludes(f); len(excludes) > 0 {
+			fmt.Println(tr("Obsolete excludes:"))
+			for _, e := range excludes {
+				e := e
+				report("%s %s is older than the required version and can never be selected", e.Mod.Path, e.Mod.Version)
+				fixes = append(fixes, func() { _ = f.DropExclude(e.Mod.Path, e.Mod.Version) })
+			}
+		}
+
+		if replaces := staleReplaces(ctx.Context, git, cfg, f); len(replaces) > 0 {
+			fmt.Println(tr("Stale replaces:"))
+			for _, r := range replaces {
+				r := r
+				report("%s => %s %s: %s", r.Old.Path, r.New.Path, r.New.Version, r.reason)
+				fixes = append(fixes, func() { _ = f.DropReplace(r.Old.Path, r.Old.Version) })
+			}
+		}
+
+		if len(fixes) == 0 {
+			fmt.Println(tr("Nothing to clean up"))
+			return nil
+		}
+
+		if !ctx.Bool("fix") {
+			fmt.Println()
+			fmt.Println(tr("Run grg cleanup --fix to remove them."))
+			return nil
+		}
+
+		for _, fix := range fixes {
+			fix()
+		}
+		if err = writeGoMod(path, f); err != nil {
+			return cli.Exit(trf("Could not write %s: %s", path, err), 1)
+		}
+		fmt.Printf(tr("\nUpdated %s\n"), path)
+		return nil
+	},
+}
+
+// requiredVersions returns the highest version each module is required at.
+func requiredVersions(f *modfile.File) map[string]string {
+	versions := map[string]string{}
+	for _, r := range f.Require {
+		if v, ok := versions[r.Mod.Path]; !ok || semver.Compare(r.Mod.Version, v) > 0 {
+			versions[r.Mod.Path] = r.Mod.Version
+		}
+	}
+	return versions
+}
+
+// duplicateRequires returns the paths of modules required more than once, in
+// order of appearance, along with the requirement that should be kept for
+// each of them.
+func duplicateRequires(f *modfile.File) ([]string, map[string]*modfile.Require) {
+	count := map[string]int{}
+	keep := map[string]*modfile.Require{}
+	var dupes []string
+	for _, r := range f.Require {
+		count[r.Mod.Path]++
+		if count[r.Mod.Path] == 2 {
+			dupes = append(dupes, r.Mod.Path)
+		}
+		if k, ok := keep[r.Mod.Path]; !ok || semver.Compare(r.Mod.Version, k.Mod.Version) > 0 {
+			keep[r.Mod.Path] = r
+		}
+	}
+	return dupes, keep
+}
+
+// obsoleteExcludes returns exclude directives for versions lower than the
+// version the module itself requires. Minimal version selection never picks
+// a version lower than a direct requirement, so such excludes have no effect.
+func obsoleteExcludes(f *modfile.File) []*modfile.Exclude {
+	required := requiredVersions(f)
+	var result []*modfile.Exclude
+	for _, e := range f.Exclude {
+		if v, ok := required[e.Mod.Path]; ok && semver.Compare(e.Mod.Version, v) < 0 {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+type staleReplace struct {
+	*modfile.Replace
+	reason string
+}
+
+// staleReplaces returns replace directives pointing to another module (as
+// opposed to a local directory) whose upstream has since published a release
+// newer than the version the replacement is based on.
+func staleReplaces(ctx context.Context, git *gitRunner, cfg *Config, f *modfile.File) []staleReplace {
+	var result []staleReplace
+	for _, r := range f.Replace {
+		if r.New.Version == "" {
+			continue
+		}
+
+		base := r.New.Version
+		if module.IsPseudoVersion(base) {
+			base, _ = module.PseudoVersionBase(base)
+		}
+
+		res, err := processRepo(ctx, git, cfg, resolveOptions{}, repoRequest{Input: r.Old.Path, ModulePath: r.Old.Path})
+		if err != nil {
+			git.logf("Could not resolve %s: %s\n", r.Old.Path, err)
+			continue
+		}
+		if module.IsPseudoVersion(res.Version) {
+			continue
+		}
+		if base == "" || semver.Compare(res.Version, base) > 0 {
+			result = append(result, staleReplace{r, fmt.Sprintf("upstream has since released %s", res.Version)})
+		}
+	}
+	return result
+}
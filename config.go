@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// Config represents the contents of grg's configuration file.
+type Config struct {
+	Hosts    map[string]HostConfig `yaml:"hosts"`
+	Policy   Policy                `yaml:"policy"`
+	Snippets map[string]Snippet    `yaml:"snippets"`
+	// Recommendations replaces the built-in rules of grg recommend.
+	Recommendations []Recommendation `yaml:"recommendations"`
+	// Groups maps the name of each version alignment group to the pattern
+	// of the module paths it holds, as described in groupMatches. Members of
+	// a group resolved together are kept at the same version.
+	Groups map[string]string `yaml:"groups"`
+}
+
+// HostConfig holds settings that apply to a single git host.
+type HostConfig struct {
+	// CloneURL is a text/template used to build the clone URL for
+	// repositories on this host. It receives a CloneURLData value. When set,
+	// it replaces the default SSH and HTTPS URLs.
+	CloneURL string `yaml:"clone-url"`
+}
+
+// CloneURLData is the value passed to HostConfig.CloneURL templates.
+type CloneURLData struct {
+	Host string
+	Path string
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "grg", "config.yaml")
+}
+
+// loadConfig reads the configuration file at path. A missing file is only an
+// error when required is set, which is the case when the user explicitly
+// provided a path.
+func loadConfig(path string, required bool) (*Config, error) {
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !required {
+			return cfg, nil
+		}
+		return nil, err
+	}
+
+	if err = yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+
+	for host, h := range cfg.Hosts {
+		if h.CloneURL == "" {
+			continue
+		}
+		if _, err = template.New(host).Parse(h.CloneURL); err != nil {
+			return nil, fmt.Errorf("invalid clone-url for host %s: %w", host, err)
+		}
+	}
+
+	if err = cfg.Policy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+
+	for i, r := range cfg.Recommendations {
+		if err = r.validate(i); err != nil {
+			return nil, fmt.Errorf("invalid configuration file %s: %w", path, err)
+		}
+	}
+
+	if err = validateGroups(cfg.Groups); err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+
+	for name, s := range cfg.Snippets {
+		if err = s.validate(name); err != nil {
+			return nil, fmt.Errorf("invalid configuration file %s: %w", path, err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// cloneURLs returns the URLs that should be attempted to clone path from
+// host, in order.
+func (c *Config) cloneURLs(host, path string) ([]string, error) {
+	if h, ok := c.Hosts[host]; ok && h.CloneURL != "" {
+		tpl, err := template.New(host).Parse(h.CloneURL)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err = tpl.Execute(&buf, CloneURLData{Host: host, Path: path}); err != nil {
+			return nil, fmt.Errorf("failed rendering clone-url for host %s: %w", host, err)
+		}
+		return []string{buf.String()}, nil
+	}
+
+	return []string{
+		fmt.Sprintf("git@%s:%s", host, path),
+		fmt.Sprintf("https://%s/%s", host, path),
+	}, nil
+}
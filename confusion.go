@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/heyvito/go-require-generator/pkg/input"
+	"github.com/urfave/cli/v2"
+	"strings"
+)
+
+// publicGoProxy is the proxy consulted by confusion-check, regardless of
+// GOPROXY, which commonly points to an internal mirror.
+const publicGoProxy = "https://proxy.golang.org"
+
+var confusionCheckCommand = &cli.Command{
+	Name:      "confusion-check",
+	Usage:     "Checks whether internal module paths are also available publicly",
+	ArgsUsage: "module-path [module-path [...]]",
+	Description: "A module path that resolves publicly can be used to serve a malicious module to\n" +
+		"builds that are not configured to fetch it from its internal location. Each path\n" +
+		"is looked up on the public module proxy, and its repository is queried without\n" +
+		"credentials.",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "public-proxy",
+			Usage: "Public module proxy to query",
+			Value: publicGoProxy,
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() == 0 {
+			return cli.ShowSubcommandHelp(ctx)
+		}
+		git, _, err := setup(ctx)
+		if err != nil {
+			return err
+		}
+
+		exposed := false
+		for _, mod := range ctx.Args().Slice() {
+			findings, err := publicExposure(ctx.Context, git, ctx.String("public-proxy"), strings.Trim(mod, "/"))
+			if err != nil {
+				return cli.Exit(trf("Could not check %s: %s", mod, err), 1)
+			}
+			if len(findings) == 0 {
+				fmt.Printf(tr("%s: not found publicly\n"), mod)
+				continue
+			}
+			exposed = true
+			for _, f := range findings {
+				fmt.Printf("%s: %s\n", mod, f)
+			}
+		}
+
+		if exposed {
+			return cli.Exit(tr("One or more internal modules are available publicly"), 1)
+		}
+		return nil
+	},
+}
+
+// publicExposure lists the ways mod can be obtained without access to
+// internal infrastructure.
+func publicExposure(ctx context.Context, git *gitRunner, proxy, mod string) ([]string, error) {
+	var findings []string
+
+	info, err := fetchProxyInfo(ctx, proxy, mod, "")
+	switch {
+	case err == nil:
+		findings = append(findings, fmt.Sprintf("available on %s (latest %s)", proxy, info.Version))
+	case !errors.Is(err, errProxyNotFound):
+		return nil, err
+	}
+
+	host, candidates, err := input.RepoRoots(mod)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range candidates {
+		u := fmt.Sprintf("https://%s/%s", host, c)
+		if anonymousLsRemote(ctx, git, u) {
+			findings = append(findings, fmt.Sprintf("publicly readable repository at %s", u))
+			break
+		}
+	}
+	return findings, nil
+}
+
+// anonymousLsRemote reports whether url can be read without credentials.
+func anonymousLsRemote(ctx context.Context, git *gitRunner, url string) bool {
+	env := []string{"GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS="}
+	_, err := git.run(ctx, "", env, "-c", "credential.helper=", "ls-remote", url, "HEAD")
+	return err == nil
+}
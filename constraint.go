@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"golang.org/x/mod/semver"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// versionConstraint is a semver range given through --constraint. It is a
+// list of alternatives separated by ||, each made of comparisons separated
+// by whitespace or commas that must all hold.
+type versionConstraint struct {
+	text         string
+	alternatives [][]comparison
+}
+
+// comparison checks a version against a bound with one of <, <=, >, >= or =.
+type comparison struct {
+	op    string
+	bound string
+}
+
+func (c comparison) holds(v string) bool {
+	r := semver.Compare(v, c.bound)
+	switch c.op {
+	case "<":
+		return r < 0
+	case "<=":
+		return r <= 0
+	case ">":
+		return r > 0
+	case ">=":
+		return r >= 0
+	}
+	return r == 0
+}
+
+// constraintOperators lists the operators a term of a constraint may start
+// with, longest first.
+var constraintOperators = []string{"<=", ">=", "<", ">", "=", "^", "~"}
+
+// parseConstraint parses ranges such as ^1.4, ~1.2, <2.0.0 or
+// ">=1.2, <1.5 || ^2". Carets allow changes that do not modify the
+// leftmost non-zero part, tildes allow patch changes (or minor ones when
+// only the major version is given), and partial versions without an
+// operator stand for every version they prefix. The v prefix is optional,
+// and operators may be separated from their version by whitespace, as in
+// ">= v1.2.0".
+func parseConstraint(text string) (*versionConstraint, error) {
+	c := &versionConstraint{text: text}
+	for _, alt := range strings.Split(text, "||") {
+		var all []comparison
+		fields := strings.FieldsFunc(alt, func(r rune) bool { return unicode.IsSpace(r) || r == ',' })
+		for i := 0; i < len(fields); i++ {
+			term := fields[i]
+			if slices.Contains(constraintOperators, term) && i+1 < len(fields) {
+				i++
+				term += fields[i]
+			}
+			cmp, err := parseComparisons(term)
+			if err != nil {
+				return nil, fmt.Errorf("invalid constraint %q: %w", text, err)
+			}
+			all = append(all, cmp...)
+		}
+		if len(all) == 0 {
+			return nil, fmt.Errorf("invalid constraint %q: empty range", text)
+		}
+		c.alternatives = append(c.alternatives, all)
+	}
+	return c, nil
+}
+
+// parseComparisons turns a single term of a constraint into the comparisons
+// it stands for.
+func parseComparisons(term string) ([]comparison, error) {
+	op := ""
+	for _, o := range constraintOperators {
+		if rest, ok := strings.CutPrefix(term, o); ok {
+			op, term = o, rest
+			break
+		}
+	}
+	v, parts, err := parseConstraintVersion(term)
+	if err != nil {
+		return nil, err
+	}
+
+	// Upper bounds derived from partial versions exclude the pre-releases
+	// of the bound, so ^1.4 does not allow v2.0.0-rc.1.
+	upper := func(major, minor, patch int) comparison {
+		return comparison{"<", fmt.Sprintf("v%d.%d.%d-0", major, minor, patch)}
+	}
+	major, minor := parts[0], 0
+	if len(parts) > 1 {
+		minor = parts[1]
+	}
+	switch op {
+	case "^":
+		switch {
+		case major > 0 || len(parts) == 1:
+			return []comparison{{">=", v}, upper(major+1, 0, 0)}, nil
+		case minor > 0 || len(parts) == 2:
+			return []comparison{{">=", v}, upper(0, minor+1, 0)}, nil
+		}
+		return []comparison{{">=", v}, upper(0, 0, parts[2]+1)}, nil
+	case "~":
+		if len(parts) == 1 {
+			return []comparison{{">=", v}, upper(major+1, 0, 0)}, nil
+		}
+		return []comparison{{">=", v}, upper(major, minor+1, 0)}, nil
+	case "", "=":
+		switch len(parts) {
+		case 1:
+			return []comparison{{">=", v}, upper(major+1, 0, 0)}, nil
+		case 2:
+			return []comparison{{">=", v}, upper(major, minor+1, 0)}, nil
+		}
+		return []comparison{{"=", v}}, nil
+	}
+	return []comparison{{op, v}}, nil
+}
+
+// parseConstraintVersion parses a version of a constraint, which may be
+// partial, returning it as a complete semver version along with the
+// numeric parts given.
+func parseConstraintVersion(s string) (string, []int, error) {
+	v := "v" + strings.TrimPrefix(s, "v")
+	if !semver.IsValid(v) || semver.Build(v) != "" {
+		return "", nil, fmt.Errorf("%q is not a version", s)
+	}
+	core, _, _ := strings.Cut(strings.TrimPrefix(v, "v"), "-")
+	var parts []int
+	for _, p := range strings.Split(core, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return "", nil, fmt.Errorf("%q is not a version", s)
+		}
+		parts = append(parts, n)
+	}
+	return semver.Canonical(v), parts, nil
+}
+
+// allows reports whether v satisfies any of the alternatives.
+func (c *versionConstraint) allows(v string) bool {
+	for _, alt := range c.alternatives {
+		ok := true
+		for _, cmp := range alt {
+			ok = ok && cmp.holds(v)
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// filter returns the semver tags satisfying the constraint.
+func (c *versionConstraint) filter(tags []remoteTag) []remoteTag {
+	var result []remoteTag
+	for _, t := range tags {
+		if semver.IsValid(t.Name) && c.allows(t.Name) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+func (c *versionConstraint) String() string {
+	return c.text
+}
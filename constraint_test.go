@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestParseConstraint(t *testing.T) {
+	for _, tt := range []struct {
+		text    string
+		allowed []string
+		denied  []string
+	}{
+		{"^1.4", []string{"v1.4.0", "v1.9.3"}, []string{"v1.3.9", "v2.0.0", "v2.0.0-rc.1"}},
+		{"^0.3", []string{"v0.3.0", "v0.3.7"}, []string{"v0.4.0"}},
+		{"^0.0.3", []string{"v0.0.3"}, []string{"v0.0.4"}},
+		{"~1.2", []string{"v1.2.0", "v1.2.9"}, []string{"v1.3.0"}},
+		{"~1", []string{"v1.0.0", "v1.9.0"}, []string{"v2.0.0"}},
+		{"1.2", []string{"v1.2.0", "v1.2.5"}, []string{"v1.3.0"}},
+		{"v1.2.3", []string{"v1.2.3"}, []string{"v1.2.4"}},
+		{"<2.0.0", []string{"v1.9.9"}, []string{"v2.0.0"}},
+		{">=1.2, <1.5 || ^2", []string{"v1.2.0", "v1.4.9", "v2.3.0"}, []string{"v1.5.0", "v3.0.0"}},
+		// Operators may be separated from their version by whitespace.
+		{">= v1.2.0", []string{"v1.2.0", "v3.0.0"}, []string{"v1.1.9"}},
+		{">= 1.2 < 1.5", []string{"v1.4.0"}, []string{"v1.1.0", "v1.5.0"}},
+		{">=1.2,\t<= 1.5", []string{"v1.5.0"}, []string{"v1.5.1"}},
+		{"  ^ 1.4  ||  = 2.0.0  ", []string{"v1.5.0", "v2.0.0"}, []string{"v2.0.1"}},
+	} {
+		c, err := parseConstraint(tt.text)
+		if err != nil {
+			t.Errorf("parseConstraint(%q) failed: %s", tt.text, err)
+			continue
+		}
+		for _, v := range tt.allowed {
+			if !c.allows(v) {
+				t.Errorf("parseConstraint(%q) does not allow %s", tt.text, v)
+			}
+		}
+		for _, v := range tt.denied {
+			if c.allows(v) {
+				t.Errorf("parseConstraint(%q) allows %s", tt.text, v)
+			}
+		}
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	for _, text := range []string{"", "||", ">=", ">= ", "^1.4 || ", "1.x", ">=1.2+build", "<= >= 1.2"} {
+		if _, err := parseConstraint(text); err == nil {
+			t.Errorf("parseConstraint(%q) succeeded", text)
+		}
+	}
+}
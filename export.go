@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"io"
+	"os"
+)
+
+var exportResultsCommand = &cli.Command{
+	Name:      "export-results",
+	Usage:     "Resolves repositories and writes the results as NDJSON, for grg add --import to apply elsewhere",
+	ArgsUsage: "file repo-url [repo-url...]",
+	Description: "Versions are selected as when printing require directives, so global flags apply. The file\n" +
+		"holds one record per repository, as written in batch mode, and - writes to stdout. Records of\n" +
+		"repositories that could not be resolved are written as well, and make grg exit with an error.",
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() < 2 {
+			return cli.ShowSubcommandHelp(ctx)
+		}
+		git, cfg, err := setup(ctx)
+		if err != nil {
+			return err
+		}
+		opts, err := resolveOptionsFromFlags(ctx)
+		if err != nil {
+			return err
+		}
+		reqs, err := buildRequests(ctx.Args().Slice()[1:], ctx.StringSlice("module-path"))
+		if err == nil {
+			err = applyRefFlags(reqs, ctx.String("commit"), ctx.String("tag"), ctx.String("branch"))
+		}
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+
+		var w io.Writer = os.Stdout
+		if path := ctx.Args().First(); path != "-" {
+			f, err := os.Create(path)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			defer func() { _ = f.Close() }()
+			w = f
+		}
+
+		failed := false
+		resolveAligned(ctx.Context, newRunBudget(0), git, cfg, opts, reqs, 1, nil, func(o outcome) {
+			printWarnings(os.Stderr, o.r)
+			writeBatchResult(w, o.req, o.r, o.err)
+			if o.err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", o.req.Input, o.err)
+				failed = true
+			}
+		})
+		if failed {
+			return cli.Exit(tr("One or more repositories could not be resolved"), 1)
+		}
+		return nil
+	},
+}
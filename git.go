@@ -0,0 +1,586 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/heyvito/go-require-generator/pkg/resolver"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type GitExecError struct {
+	StdOut        string
+	StdErr        string
+	Status        int
+	OriginalError error
+}
+
+func (e GitExecError) Error() string {
+	return fmt.Sprintf("Failed to execute git command. Exit code %d: %s", e.Status, e.OriginalError)
+}
+
+func gitFail(stdout, stderr strings.Builder, err error) error {
+	status := -1
+	var e *exec.ExitError
+	if errors.As(err, &e) {
+		status = e.ExitCode()
+	}
+	return GitExecError{
+		OriginalError: err,
+		StdOut:        stdout.String(),
+		StdErr:        stderr.String(),
+		Status:        status,
+	}
+}
+
+// gitFailure returns err wrapping the resolver sentinel, such as
+// resolver.ErrAuth, that tells why the git command it reports failed, as
+// the resolver package does, or err itself when the cause is unknown.
+func gitFailure(err error) error {
+	if cause := failureCause(err); cause != nil {
+		return fmt.Errorf("%w: %w", cause, err)
+	}
+	return err
+}
+
+// failureCause returns the resolver sentinel telling why the git command
+// err reports failed, or nil when it is unknown.
+func failureCause(err error) error {
+	var e GitExecError
+	if !errors.As(err, &e) {
+		return nil
+	}
+	return resolver.CloneFailure(e.StdErr)
+}
+
+// gitRunner executes git commands, echoing them and their failures when
+// verbose output is enabled.
+type gitRunner struct {
+	path    string
+	verbose bool
+	// redact controls whether credentials are stripped from commands,
+	// environment variables and output before they are printed.
+	redact bool
+	// env holds KEY=VALUE entries added to the environment of every command.
+	env []string
+	// maxFetchSize is the maximum number of bytes a clone may transfer
+	// before being aborted. Zero means no limit.
+	maxFetchSize int64
+	// wrapper is a command prefix git is run through, used to lower its CPU
+	// and IO priority.
+	wrapper []string
+	// protocols decides whether SSH or HTTPS is attempted first for hosts
+	// without a configured clone-url.
+	protocols *protocolSelector
+	// logFile receives verbose messages and full command transcripts
+	// regardless of verbose, always with credentials redacted. Nil disables
+	// it.
+	logFile io.Writer
+	// stageTimeouts bounds the time each stage of a resolution may take,
+	// as given through --stage-timeout.
+	stageTimeouts map[string]time.Duration
+	// timings records the stages of the resolution in progress, for
+	// --explain. Nil outside of resolutions.
+	timings *stageTimings
+}
+
+func (g *gitRunner) sanitize(s string) string {
+	if !g.redact {
+		return s
+	}
+	return redactString(s)
+}
+
+func (g *gitRunner) sanitizeEnv(kv string) string {
+	if !g.redact {
+		return kv
+	}
+	return redactEnv(kv)
+}
+
+// logging reports whether verbose messages are printed or logged anywhere.
+func (g *gitRunner) logging() bool {
+	return g.verbose || g.logFile != nil
+}
+
+// emit prints terminal when verbose, and writes file to the log file.
+func (g *gitRunner) emit(terminal, file string) {
+	if g.verbose {
+		fmt.Print(terminal)
+	}
+	if g.logFile != nil {
+		_, _ = io.WriteString(g.logFile, file)
+	}
+}
+
+// logf prints a verbose message, with credentials redacted when enabled.
+func (g *gitRunner) logf(format string, args ...any) {
+	s := fmt.Sprintf("verbose: "+format, args...)
+	g.emit(g.sanitize(s), redactString(s))
+}
+
+// logEnvironment prints the environment variables that may influence how
+// repositories are resolved.
+func (g *gitRunner) logEnvironment() {
+	if !g.logging() {
+		return
+	}
+	g.logf("Environment:\n")
+	for _, kv := range relevantEnv() {
+		g.emit("        "+g.sanitizeEnv(kv)+"\n", "        "+redactEnv(kv)+"\n")
+	}
+
+	source := "process environment"
+	if goEnv().FromToolchain {
+		source = "go env"
+	}
+	g.logf("Effective Go environment (from %s):\n", source)
+	for _, kv := range goEnv().entries() {
+		g.emit("        "+g.sanitizeEnv(kv)+"\n", "        "+redactEnv(kv)+"\n")
+	}
+}
+
+// indent prefixes every line of s for inclusion in verbose output.
+func indent(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, v := range lines {
+		lines[i] = "        " + v
+	}
+	return strings.Join(lines, "\n")
+}
+
+// run executes git with the given arguments inside dir, killing it once ctx
+// is done, such as on interrupts or once the share of --deadline is used;
+// contextErr tells why. env holds additional KEY=VALUE entries appended to
+// the current environment. It returns the trimmed standard output of the
+// command.
+func (g *gitRunner) run(ctx context.Context, dir string, env []string, args ...string) (string, error) {
+	return g.runWatched(ctx, dir, env, nil, args...)
+}
+
+// progressWriter splits git's progress output into lines, which git separates
+// with carriage returns, and hands each of them to watch. The first error
+// returned by watch is kept and triggers abort.
+type progressWriter struct {
+	out   strings.Builder
+	line  []byte
+	watch func(line string) error
+	abort func()
+	err   error
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	p.out.Write(b)
+	for _, c := range b {
+		if c != '\r' && c != '\n' {
+			p.line = append(p.line, c)
+			continue
+		}
+		if len(p.line) > 0 && p.err == nil {
+			if err := p.watch(string(p.line)); err != nil {
+				p.err = err
+				p.abort()
+			}
+		}
+		p.line = p.line[:0]
+	}
+	return len(b), nil
+}
+
+// runWatched behaves like run, but feeds every line git writes to stderr to
+// watch as it is produced. If watch returns an error, the command is killed
+// and that error is returned.
+func (g *gitRunner) runWatched(ctx context.Context, dir string, env []string, watch func(line string) error, args ...string) (string, error) {
+	env = append(append([]string{}, g.env...), env...)
+	cmdArgs := append([]string{g.path}, args...)
+	if len(g.wrapper) > 0 {
+		cmdArgs = append(append([]string{}, g.wrapper...), cmdArgs...)
+	}
+	cmd := exec.CommandContext(ctx, cmdArgs[0], cmdArgs[1:]...)
+	cmd.Dir = dir
+	// git's messages are matched to tell failures apart, which needs them
+	// untranslated.
+	cmd.Env = append(append(os.Environ(), "LC_ALL=C"), env...)
+
+	if g.logging() {
+		var terminal, file []string
+		for _, kv := range env {
+			terminal = append(terminal, g.sanitizeEnv(kv))
+			file = append(file, redactEnv(kv))
+		}
+		terminal = append(terminal, cmdArgs...)
+		file = append(file, cmdArgs...)
+		g.emit(g.sanitize("verbose: Executing "+strings.Join(terminal, " ")+"\n"),
+			redactString("verbose: Executing "+strings.Join(file, " ")+"\n"))
+	}
+
+	var stdout strings.Builder
+	progress := &progressWriter{
+		watch: watch,
+		abort: func() { _ = cmd.Process.Kill() },
+	}
+	if watch == nil {
+		progress.watch = func(string) error { return nil }
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = progress
+	err := cmd.Run()
+	if progress.err != nil {
+		return "", progress.err
+	}
+	if err := contextErr(ctx); err != nil {
+		return "", err
+	}
+	stderr := progress.out
+	if err != nil {
+		if g.logging() {
+			g.logf("Error executing:\n")
+			transcript := indent(stdout.String() + "\n" + stderr.String())
+			g.emit(g.sanitize(transcript)+"\n", redactString(transcript)+"\n")
+		}
+		return "", gitFail(stdout, stderr, err)
+	}
+	if g.logFile != nil && (stdout.Len() > 0 || stderr.Len() > 0) {
+		_, _ = io.WriteString(g.logFile, redactString(indent(strings.TrimRight(stdout.String()+stderr.String(), "\n")))+"\n")
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// errFetchTooLarge is returned when a clone exceeds the --max-fetch-size
+// budget.
+var errFetchTooLarge = errors.New("clone aborted: repository exceeds the maximum fetch size")
+
+var receivedPattern = regexp.MustCompile(`Receiving objects:.*?,\s+([\d.]+)\s+(bytes|KiB|MiB|GiB|TiB)`)
+
+// fetchBudgetWatcher returns a progress watcher failing once the transfer
+// reported by git exceeds limit bytes.
+func fetchBudgetWatcher(limit int64) func(string) error {
+	return func(line string) error {
+		m := receivedPattern.FindStringSubmatch(line)
+		if m == nil {
+			return nil
+		}
+		n, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return nil
+		}
+		if int64(n*float64(byteUnits[m[2]])) > limit {
+			return fmt.Errorf("%w (received %s %s, limit is %s)", errFetchTooLarge, m[1], m[2], formatByteSize(limit))
+		}
+		return nil
+	}
+}
+
+func cloneRepo(ctx context.Context, git *gitRunner, url, into string, prompt bool) error {
+	var env []string
+	if !prompt {
+		env = append(env, "GIT_TERMINAL_PROMPT=0")
+	}
+	if git.maxFetchSize <= 0 {
+		_, err := git.run(ctx, into, env, "clone", "--depth=1", "--bare", url, "repo")
+		return err
+	}
+
+	_, err := git.runWatched(ctx, into, env, fetchBudgetWatcher(git.maxFetchSize), "clone", "--progress", "--depth=1", "--bare", url, "repo")
+	if err != nil {
+		_ = os.RemoveAll(filepath.Join(into, "repo"))
+		return err
+	}
+
+	// Small transfers may finish before git reports their size, so double
+	// check what actually landed on disk.
+	if size := dirSize(filepath.Join(into, "repo")); size > git.maxFetchSize {
+		_ = os.RemoveAll(filepath.Join(into, "repo"))
+		return fmt.Errorf("%w (cloned %s, limit is %s)", errFetchTooLarge, formatByteSize(size), formatByteSize(git.maxFetchSize))
+	}
+	return nil
+}
+
+// fetchHead shallowly fetches the default branch's HEAD into repo, a bare
+// repository with the remote as its origin, and detaches HEAD at it, leaving
+// repo as cloneRepo would.
+func fetchHead(ctx context.Context, git *gitRunner, repo string) error {
+	var err error
+	if git.maxFetchSize <= 0 {
+		_, err = git.run(ctx, repo, nil, "fetch", "--depth=1", "origin", "HEAD")
+	} else {
+		_, err = git.runWatched(ctx, repo, nil, fetchBudgetWatcher(git.maxFetchSize), "fetch", "--progress", "--depth=1", "origin", "HEAD")
+	}
+	if err != nil {
+		return err
+	}
+	_, err = git.run(ctx, repo, nil, "update-ref", "--no-deref", "HEAD", "FETCH_HEAD")
+	return err
+}
+
+// probeRepo calls attempt with each candidate repository path, trying every
+// clone URL configured for the host (SSH before HTTPS by default) for each
+// one. Credential prompts are disabled while more than one candidate is being
+// probed, so a wrong guess fails fast instead of asking for a password. It
+// returns the path and URL of the first attempt that succeeds.
+func probeRepo(git *gitRunner, cfg *Config, host string, candidates []string, attempt func(url string, prompt bool) error) (string, string, error) {
+	prompt := len(candidates) == 1
+	var cause error
+	for _, path := range candidates {
+		urls, err := cfg.cloneURLs(host, path)
+		if err != nil {
+			return "", "", err
+		}
+		if cfg.Hosts[host].CloneURL == "" {
+			urls = orderURLs(urls, git.protocols.preferred(git, host))
+		}
+		for _, url := range urls {
+			err = attempt(url, prompt)
+			if err == nil {
+				return path, url, nil
+			}
+			if errors.Is(err, errFetchTooLarge) || errors.Is(err, errTimedOut) || errors.Is(err, errInterrupted) {
+				return "", "", err
+			}
+			git.logf("Error cloning repository: %s\n", err)
+			if c := failureCause(err); c != nil {
+				cause = c
+			}
+		}
+	}
+	via := "HTTPS and SSH"
+	if cfg.Hosts[host].CloneURL != "" {
+		via = "the configured clone-url"
+	}
+	if cause != nil {
+		return "", "", fmt.Errorf("failed clonning via %s (%w). Check you have access to the repository", via, cause)
+	}
+	return "", "", fmt.Errorf("failed clonning via %s. Check you have access to the repository", via)
+}
+
+// lsRemote checks that url points to an accessible repository without
+// cloning it.
+func lsRemote(ctx context.Context, git *gitRunner, url string, prompt bool) error {
+	var env []string
+	if !prompt {
+		env = append(env, "GIT_TERMINAL_PROMPT=0")
+	}
+	_, err := git.run(ctx, "", env, "ls-remote", url, "HEAD")
+	return err
+}
+
+// pseudoVersion returns the pseudo-version the go command assigns to rev, a
+// commit of the module at path, as resolver.PseudoVersion builds it.
+// rev is either HEAD, which requires cloning, or a full SHA already fetched
+// by fetchCommit. Telling which tags are merged into rev requires the
+// complete history, which is only fetched when the module has tags it can
+// be based on.
+func pseudoVersion(ctx context.Context, s *repoSession, path, rev string) (string, error) {
+	if rev == "HEAD" {
+		if err := s.ensureClone(ctx); err != nil {
+			return "", err
+		}
+	}
+	out, err := s.git.run(ctx, s.repo(), nil, "log", "-1", "--format=%H %ct", rev)
+	if err != nil && rev == "HEAD" {
+		// Only a repository without commits lacks a HEAD to log.
+		return "", fmt.Errorf("failed obtaining information from clonned repository: %w: %w", resolver.ErrNoCommits, err)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed obtaining information from clonned repository: %w", err)
+	}
+	sha, ct, _ := strings.Cut(out, " ")
+	unix, err := strconv.ParseInt(ct, 10, 64)
+	if err != nil || len(sha) < 12 {
+		return "", fmt.Errorf("failed obtaining information from clonned repository: unexpected output %q", out)
+	}
+
+	base, err := pseudoVersionBase(ctx, s, path, sha)
+	if err != nil {
+		return "", err
+	}
+	return resolver.PseudoVersion(path, base, time.Unix(unix, 0), sha), nil
+}
+
+// pseudoVersionBase returns the version the pseudo-version of sha, a commit
+// of the module at path, is based on, as resolver.PseudoVersionBase picks
+// it among the module's tags merged into sha.
+func pseudoVersionBase(ctx context.Context, s *repoSession, path, sha string) (string, error) {
+	tags, err := s.remoteTags(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed listing tags: %w", err)
+	}
+	_, goMod, err := commitModuleFile(ctx, s, sha, s.subdir)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.Name
+	}
+	if resolver.PseudoVersionBase(path, names, goMod == nil) == "" {
+		return "", nil
+	}
+
+	if err = s.fetchHistory(ctx); err != nil {
+		return "", err
+	}
+	out, err := s.git.run(ctx, s.repo(), nil, "tag", "--list", "--merged", sha, s.tagPrefix+"v*")
+	if err != nil {
+		return "", fmt.Errorf("failed listing tags merged into %s: %w", sha, err)
+	}
+	var merged []string
+	for _, tag := range strings.Split(out, "\n") {
+		merged = append(merged, strings.TrimPrefix(strings.TrimSpace(tag), s.tagPrefix))
+	}
+	return resolver.PseudoVersionBase(path, merged, goMod == nil), nil
+}
+
+// fetchHistory turns the shallow clone at repo into a complete one, including
+// every branch and tag.
+func fetchHistory(ctx context.Context, git *gitRunner, repo string) error {
+	_, err := git.run(ctx, repo, nil, "fetch", "--unshallow", "origin", "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*")
+	if err != nil {
+		return fmt.Errorf("failed fetching repository history: %w", err)
+	}
+	return nil
+}
+
+// fetchCommit makes the commit identified by sha, which may be abbreviated,
+// available in the session's repository and returns its full SHA. Full SHAs are
+// fetched directly; abbreviated ones are first matched against the
+// advertised tags, and otherwise require fetching the complete history so
+// they can be expanded locally.
+func fetchCommit(ctx context.Context, s *repoSession, sha string) (string, error) {
+	if s.remoteOnly() {
+		return "", s.errRemoteOnly("fetching commits")
+	}
+	if err := s.ensureRepo(ctx); err != nil {
+		return "", err
+	}
+	tags, err := s.remoteTags(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed listing tags: %w", err)
+	}
+	repo := s.repo()
+	full := ""
+	if len(sha) == 40 {
+		full = sha
+	} else {
+		for _, t := range tags {
+			if strings.HasPrefix(t.Commit, sha) {
+				if full != "" && full != t.Commit {
+					return "", fmt.Errorf("commit %s is ambiguous", sha)
+				}
+				full = t.Commit
+			}
+		}
+	}
+
+	switch {
+	case s.complete:
+	case full != "":
+		if _, err := s.git.run(ctx, repo, nil, "fetch", "--depth=1", "origin", full); err != nil {
+			return "", fmt.Errorf("commit %s was not found in the repository", sha)
+		}
+	default:
+		s.git.logf("Abbreviated commit %s requires fetching the full history\n", sha)
+		if err := s.fetchHistory(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	resolved, err := s.git.run(ctx, repo, nil, "rev-parse", "--verify", "--quiet", sha+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("commit %s was not found in the repository", sha)
+	}
+	return resolved, nil
+}
+
+// remoteTag is a tag advertised by a remote repository.
+type remoteTag = resolver.Tag
+
+// listRemoteTags lists the tags available on remote, which is either a URL
+// or the name of a remote of the clone at repo, without fetching them.
+// Annotated tags are peeled to the commit they point to.
+func listRemoteTags(ctx context.Context, git *gitRunner, repo, remote string) ([]remoteTag, error) {
+	out, err := git.run(ctx, repo, nil, "ls-remote", "--tags", remote)
+	if err != nil {
+		return nil, gitFailure(err)
+	}
+	return resolver.ParseTags(out), nil
+}
+
+// signaturePrefixes lists the markers git uses for signatures embedded in tag
+// objects (OpenPGP, X.509 and SSH).
+var signaturePrefixes = []string{"-----BEGIN PGP SIGNATURE-----", "-----BEGIN SIGNED MESSAGE-----", "-----BEGIN SSH SIGNATURE-----"}
+
+// tagSigned reports whether the tag object of the module's version name
+// carries a signature. ok is false when the tag is not available, in which
+// case nothing can be said about it. Lightweight tags, which cannot be
+// signed, are recognized from the advertised tags; for annotated tags, only
+// the tag object and the commit it points to are fetched, without cloning.
+func tagSigned(ctx context.Context, s *repoSession, name string) (signed, ok bool) {
+	tags, err := s.remoteTags(ctx)
+	if err != nil {
+		return false, false
+	}
+	annotated, found := false, false
+	for _, t := range tags {
+		if t.Name == name {
+			annotated, found = t.Annotated, true
+		}
+	}
+	if !found {
+		return false, false
+	}
+	if !annotated {
+		return false, true
+	}
+
+	if err = s.ensureRepo(ctx); err != nil {
+		return false, false
+	}
+	ref := s.tagRef(ctx, name)
+	if _, err = s.git.run(ctx, s.repo(), nil, "cat-file", "-e", ref); err != nil {
+		if _, err = s.git.run(ctx, s.repo(), nil, "fetch", "--depth=1", "origin", "+"+ref+":"+ref); err != nil {
+			return false, false
+		}
+	}
+	body, err := s.git.run(ctx, s.repo(), nil, "cat-file", "tag", ref)
+	if err != nil {
+		return false, false
+	}
+	for _, p := range signaturePrefixes {
+		if strings.Contains(body, p) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// checkTagSignature warns when the tag of r.Version is unsigned. Nothing is
+// said when the tag cannot be inspected.
+func checkTagSignature(ctx context.Context, s *repoSession, r *resolution) {
+	tag := versionTag(r.Version)
+	if signed, ok := tagSigned(ctx, s, tag); ok && !signed {
+		r.warn(warnUnsignedTag, "tag %s is not signed", s.tagPrefix+tag)
+	}
+}
+
+// syncWriter serializes writes to w, so messages from concurrent commands do
+// not interleave.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(b)
+}
@@ -2,10 +2,14 @@ module github.com/heyvito/go-require-generator
 
 go 1.22.1
 
-require github.com/urfave/cli/v2 v2.27.1
+require (
+	github.com/urfave/cli/v2 v2.27.1
+	github.com/xrash/smetrics v0.0.0-20240312152122-5f08fbb34913
+	golang.org/x/mod v0.23.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/cpuguy83/go-md2man/v2 v2.0.4 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
-	github.com/xrash/smetrics v0.0.0-20240312152122-5f08fbb34913 // indirect
 )
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/heyvito/go-require-generator/pkg/resolver"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"sort"
+	"strings"
+)
+
+// goSemver turns a Go version, such as 1.20, 1.21.3 or 1.21rc1, into a
+// semver version ordered as Go releases are, or an empty string when it is
+// not a Go version. Release candidates and betas become pre-releases of the
+// release they precede.
+func goSemver(v string) string {
+	pre := ""
+	if i := strings.IndexFunc(v, func(r rune) bool { return r != '.' && (r < '0' || r > '9') }); i >= 0 {
+		v, pre = v[:i], "-"+v[i:]
+	}
+	if strings.Count(v, ".") == 1 {
+		v += ".0"
+	}
+	sv := "v" + v + pre
+	if !semver.IsValid(sv) {
+		return ""
+	}
+	return sv
+}
+
+func validateGoVersion(v string) error {
+	if v != "" && goSemver(v) == "" {
+		return fmt.Errorf("%q is not a Go version, such as 1.20 or 1.21.3", v)
+	}
+	return nil
+}
+
+// requiredGoVersion returns the go directive of the go.mod of r, or an empty
+// string when there is no go.mod or it has no go directive.
+func requiredGoVersion(ctx context.Context, s *repoSession, r resolution) (string, error) {
+	file, data, err := moduleFileAt(ctx, s, r, s.subdir)
+	if err != nil || data == nil {
+		return "", err
+	}
+	f, err := modfile.ParseLax(file, data, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed parsing %s at %s: %w", file, r.Version, err)
+	}
+	if f.Go == nil {
+		return "", nil
+	}
+	return f.Go.Version, nil
+}
+
+// goCompatible reports whether a module requiring Go release need builds
+// with Go release have. Modules declaring no go directive build with any.
+func goCompatible(have, need string) bool {
+	return need == "" || goSemver(need) == "" || semver.Compare(goSemver(need), goSemver(have)) <= 0
+}
+
+// enforceGoVersion makes sure r builds with the Go release given through
+// --compatible-with-go. When the latest version requires a newer release,
+// the newest older version that does not is selected instead, among the
+// tags the latest version could have been picked from; explicitly requested
+// versions and pseudo-versions are never replaced.
+func enforceGoVersion(ctx context.Context, s *repoSession, policy Policy, opts resolveOptions, req repoRequest, r resolution) (resolution, error) {
+	if opts.GoVersion == "" {
+		return r, nil
+	}
+	need, err := requiredGoVersion(ctx, s, r)
+	if err != nil {
+		return resolution{}, err
+	}
+	if goCompatible(opts.GoVersion, need) {
+		return r, nil
+	}
+	incompatible := fmt.Errorf("%s requires go %s, newer than %s", r.Version, need, opts.GoVersion)
+	if req.Ref != "" || opts.Channel != "" || opts.Nightly || r.Note != "" || module.IsPseudoVersion(r.Version) {
+		return resolution{}, incompatible
+	}
+
+	candidates, err := olderCandidates(ctx, s, policy, opts, r)
+	if err != nil {
+		return resolution{}, err
+	}
+	for _, t := range candidates {
+		res := resolution{Path: r.Path, Version: t.Name, Warnings: r.Warnings}
+		need, err := requiredGoVersion(ctx, s, res)
+		if err != nil {
+			return resolution{}, err
+		}
+		if goCompatible(opts.GoVersion, need) {
+			res.warn(warnGoVersionDowngrade, "%s; selected %s instead", incompatible, t.Name)
+			return res, nil
+		}
+		s.git.logf("%s %s requires go %s\n", r.Path, t.Name, need)
+	}
+	return resolution{}, fmt.Errorf("%w; no version is compatible with go %s", incompatible, opts.GoVersion)
+}
+
+// olderCandidates returns the versions older than r that could replace it,
+// newest first: those satisfying the constraint and policy, and not retracted
+// by the module. Pre-releases are only included when r is one or opts
+// includes them, as falling back to pre-releases only happens when there are
+// no releases at all.
+func olderCandidates(ctx context.Context, s *repoSession, policy Policy, opts resolveOptions, r resolution) ([]remoteTag, error) {
+	tags, err := s.remoteTags(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing tags: %w", err)
+	}
+	if opts.Constraint != nil {
+		tags = opts.Constraint.filter(tags)
+	}
+	var retractions []*modfile.Retract
+	if !s.remoteOnly() {
+		if retractions, err = moduleRetractions(ctx, s, r.Path); err != nil {
+			return nil, err
+		}
+	}
+	stable := semver.Prerelease(r.Version) == "" && opts.Prereleases != preInclude
+	var candidates []remoteTag
+	for _, t := range tags {
+		if !resolver.IsModuleVersion(t.Name) || semver.Compare(t.Name, r.Version) >= 0 || (stable && semver.Prerelease(t.Name) != "") {
+			continue
+		}
+		if !policy.allows(r.Path, t.Name) {
+			continue
+		}
+		if resolver.Retraction(retractions, t.Name) != nil {
+			s.git.logf("%s %s is retracted\n", r.Path, t.Name)
+			continue
+		}
+		candidates = append(candidates, t)
+	}
+	sort.Slice(candidates, func(i, j int) bool { return semver.Compare(candidates[i].Name, candidates[j].Name) > 0 })
+	return candidates, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"golang.org/x/mod/module"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// goEnvVars lists the Go environment variables grg takes into account.
+var goEnvVars = []string{"GOPROXY", "GONOPROXY", "GOPRIVATE", "GOSUMDB", "GONOSUMDB", "GOINSECURE", "GOFLAGS"}
+
+// goEnvironment is the effective Go configuration on this machine.
+type goEnvironment struct {
+	Proxy    string
+	NoProxy  string
+	Private  string
+	SumDB    string
+	NoSumDB  string
+	Insecure string
+	Flags    []string
+	// FromToolchain is set when the values were obtained from go env,
+	// which also accounts for settings persisted with go env -w.
+	FromToolchain bool
+}
+
+var (
+	goEnvOnce  sync.Once
+	goEnvValue goEnvironment
+)
+
+// goEnv returns the effective Go environment. When a Go toolchain is
+// available, it is queried through go env -json so grg matches what go get
+// would do on this machine; otherwise the process environment and the go
+// command's defaults are used.
+func goEnv() goEnvironment {
+	goEnvOnce.Do(func() { goEnvValue = loadGoEnv() })
+	return goEnvValue
+}
+
+func loadGoEnv() goEnvironment {
+	values := map[string]string{}
+	fromToolchain := false
+	if goPath, err := exec.LookPath("go"); err == nil {
+		out, err := exec.Command(goPath, append([]string{"env", "-json"}, goEnvVars...)...).Output()
+		if err == nil && json.Unmarshal(out, &values) == nil {
+			fromToolchain = true
+		}
+	}
+	if !fromToolchain {
+		for _, k := range goEnvVars {
+			values[k] = os.Getenv(k)
+		}
+	}
+
+	env := goEnvironment{
+		Proxy:         values["GOPROXY"],
+		NoProxy:       values["GONOPROXY"],
+		Private:       values["GOPRIVATE"],
+		SumDB:         values["GOSUMDB"],
+		NoSumDB:       values["GONOSUMDB"],
+		Insecure:      values["GOINSECURE"],
+		Flags:         strings.Fields(values["GOFLAGS"]),
+		FromToolchain: fromToolchain,
+	}
+
+	// These defaults mirror the go command's own.
+	if env.Proxy == "" {
+		env.Proxy = defaultGoProxy
+	}
+	if env.NoProxy == "" {
+		env.NoProxy = env.Private
+	}
+	if env.NoSumDB == "" {
+		env.NoSumDB = env.Private
+	}
+	if env.SumDB == "" {
+		env.SumDB = "sum.golang.org"
+	}
+	return env
+}
+
+// bypassesProxy reports whether mod is fetched directly according to
+// GONOPROXY (or GOPRIVATE).
+func (e goEnvironment) bypassesProxy(mod string) bool {
+	return module.MatchPrefixPatterns(e.NoProxy, mod)
+}
+
+// bypassesSumDB reports whether mod is left out of checksum database
+// verification according to GONOSUMDB (or GOPRIVATE), or because GOSUMDB is
+// off.
+func (e goEnvironment) bypassesSumDB(mod string) bool {
+	return e.SumDB == "off" || module.MatchPrefixPatterns(e.NoSumDB, mod)
+}
+
+// insecure reports whether mod may be fetched over plain HTTP according to
+// GOINSECURE.
+func (e goEnvironment) insecure(mod string) bool {
+	return module.MatchPrefixPatterns(e.Insecure, mod)
+}
+
+// entries returns the environment in KEY=VALUE form, for logging.
+func (e goEnvironment) entries() []string {
+	return []string{
+		"GOPROXY=" + e.Proxy,
+		"GONOPROXY=" + e.NoProxy,
+		"GOPRIVATE=" + e.Private,
+		"GOSUMDB=" + e.SumDB,
+		"GONOSUMDB=" + e.NoSumDB,
+		"GOINSECURE=" + e.Insecure,
+		"GOFLAGS=" + strings.Join(e.Flags, " "),
+	}
+}
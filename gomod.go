@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/modfile"
+	"os"
+	"path/filepath"
+)
+
+// findGoMod walks up from dir looking for the nearest go.mod file.
+func findGoMod(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		p := filepath.Join(dir, "go.mod")
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("could not find a go.mod file in the current directory or any of its parents")
+		}
+		dir = parent
+	}
+}
+
+// modfileFlag lets read-only commands analyze a go.mod file other than the
+// one governing the current directory.
+var modfileFlag = &cli.StringFlag{
+	Name:  "modfile",
+	Usage: "Analyzes the go.mod file at `PATH` instead of the one found from the current directory",
+}
+
+// changedOnlyFlag and changedBaseFlag let commands operating on several
+// modules skip those whose go.mod the current change leaves alone, keeping
+// CI runs in monorepos proportional to the change.
+var (
+	changedOnlyFlag = &cli.BoolFlag{
+		Name:  "changed-only",
+		Usage: "Only operates on modules whose go.mod differs from the one at --base",
+	}
+	changedBaseFlag = &cli.StringFlag{
+		Name:  "base",
+		Usage: "Revision --changed-only compares go.mod files against",
+		Value: "origin/main",
+	}
+)
+
+// goModPath returns the go.mod file a command operates on: the one given
+// through --modfile, or the nearest one otherwise.
+func goModPath(ctx *cli.Context) (string, error) {
+	p := ctx.String("modfile")
+	if p == "" {
+		return findGoMod(".")
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory; --modfile expects the path of a go.mod file", p)
+	}
+	return filepath.Abs(p)
+}
+
+// readGoMod parses the go.mod file at path, keeping its syntax tree so it
+// can be written back with comments and formatting preserved.
+func readGoMod(path string) (*modfile.File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := modfile.Parse(path, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// writeGoMod formats f and writes it back to path.
+func writeGoMod(path string, f *modfile.File) error {
+	f.Cleanup()
+	data, err := f.Format()
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, info.Mode().Perm())
+}
+
+// writeGoWork formats f and writes it back to path.
+func writeGoWork(path string, f *modfile.WorkFile) error {
+	f.Cleanup()
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, modfile.Format(f.Syntax), info.Mode().Perm())
+}
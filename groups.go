@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/heyvito/go-require-generator/pkg/resolver"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"path"
+	"sort"
+	"strings"
+)
+
+// groupMatches reports whether mod belongs to the alignment group whose
+// members are described by pattern. Patterns are path.Match globs, except
+// that a trailing * also matches nested paths, so go.opentelemetry.io/otel*
+// covers go.opentelemetry.io/otel/sdk.
+func groupMatches(pattern, mod string) bool {
+	if ok, _ := path.Match(pattern, mod); ok {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(pattern, "*")
+	return ok && !strings.ContainsAny(prefix, "*?[\\") && strings.HasPrefix(mod, prefix)
+}
+
+// validateGroups checks the patterns of the configured alignment groups.
+func validateGroups(groups map[string]string) error {
+	for name, pattern := range groups {
+		if name == "" {
+			return fmt.Errorf("alignment group for %q has no name", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return fmt.Errorf("alignment group %s has an invalid pattern %q", name, pattern)
+		}
+	}
+	return nil
+}
+
+// groupOf returns the name of the alignment group mod belongs to. Groups
+// are tried in name order, so a module matching several patterns always
+// lands in the same one.
+func (c *Config) groupOf(mod string) (string, bool) {
+	names := make([]string, 0, len(c.Groups))
+	for name := range c.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if groupMatches(c.Groups[name], mod) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// resolveAligned is resolveAll, except that when alignment groups are
+// configured, outcomes are held back until every repository was resolved,
+// so members of a group can be moved to a version they all share.
+func resolveAligned(ctx context.Context, b runBudget, git *gitRunner, cfg *Config, opts resolveOptions, reqs []repoRequest, jobs int, unreachable map[string]error, done func(outcome)) {
+	if len(cfg.Groups) == 0 {
+		resolveAll(ctx, b, git, cfg, opts, reqs, jobs, unreachable, done)
+		return
+	}
+	var outcomes []outcome
+	resolveAll(ctx, b, git, cfg, opts, reqs, jobs, unreachable, func(o outcome) {
+		outcomes = append(outcomes, o)
+	})
+	alignGroups(ctx, git, cfg, opts, outcomes)
+	for _, o := range outcomes {
+		done(o)
+	}
+}
+
+// alignGroups moves the members of each alignment group in outcomes to the
+// highest release every one of them has, when they were resolved to
+// different versions. Failed outcomes, explicitly requested versions,
+// pins and pseudo-versions are left alone.
+func alignGroups(ctx context.Context, git *gitRunner, cfg *Config, opts resolveOptions, outcomes []outcome) {
+	members := map[string][]int{}
+	var names []string
+	for i, o := range outcomes {
+		if o.err != nil || o.req.Ref != "" || o.r.Note != "" || module.IsPseudoVersion(o.r.Version) {
+			continue
+		}
+		name, ok := cfg.groupOf(o.r.Path)
+		if !ok {
+			continue
+		}
+		if members[name] == nil {
+			names = append(names, name)
+		}
+		members[name] = append(members[name], i)
+	}
+
+	for _, name := range names {
+		idx := members[name]
+		aligned := true
+		for _, i := range idx[1:] {
+			aligned = aligned && versionTag(outcomes[i].r.Version) == versionTag(outcomes[idx[0]].r.Version)
+		}
+		if aligned {
+			continue
+		}
+
+		target, err := sharedRelease(ctx, git, cfg, opts, outcomes, idx)
+		if err != nil {
+			for _, i := range idx {
+				outcomes[i].r.warn(warnGroupAlignment, "could not align group %s: %s", name, err)
+			}
+			continue
+		}
+		git.logf("Aligning group %s at %s\n", name, target)
+		for _, i := range idx {
+			r := &outcomes[i].r
+			if versionTag(r.Version) == target {
+				continue
+			}
+			previous := r.Version
+			r.Version = target
+			if strings.HasSuffix(previous, "+incompatible") {
+				r.Version += "+incompatible"
+			}
+			r.warn(warnGroupAlignment, "aligned with group %s at %s instead of %s", name, target, previous)
+		}
+	}
+}
+
+// sharedRelease returns the highest release, allowed by the policy, that is
+// tagged for every module of outcomes listed in idx.
+func sharedRelease(ctx context.Context, git *gitRunner, cfg *Config, opts resolveOptions, outcomes []outcome, idx []int) (string, error) {
+	var shared map[string]bool
+	for _, i := range idx {
+		versions, err := releaseVersions(ctx, git, cfg, opts, outcomes[i].req)
+		if err != nil {
+			return "", fmt.Errorf("failed listing versions of %s: %w", outcomes[i].r.Path, err)
+		}
+		found := map[string]bool{}
+		for _, v := range versions {
+			if resolver.IsModuleVersion(v) && semver.Prerelease(v) == "" && cfg.Policy.allows(outcomes[i].r.Path, v) && (shared == nil || shared[v]) {
+				found[v] = true
+			}
+		}
+		shared = found
+	}
+
+	var best string
+	for v := range shared {
+		if best == "" || semver.Compare(v, best) > 0 {
+			best = v
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("no release is shared by all of its modules")
+	}
+	return best, nil
+}
+
+// releaseVersions lists the tagged versions of the module req refers to,
+// from the module proxies when opts.Proxy is set and from the repository's
+// advertised tags otherwise.
+func releaseVersions(ctx context.Context, git *gitRunner, cfg *Config, opts resolveOptions, req repoRequest) ([]string, error) {
+	if opts.Proxy {
+		versions, _, err := proxyVersions(ctx, req.ModulePath)
+		for i, v := range versions {
+			versions[i] = versionTag(v)
+		}
+		return versions, err
+	}
+	s, err := openSession(ctx, git, cfg, req)
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+	tags, err := s.remoteTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, len(tags))
+	for i, t := range tags {
+		versions[i] = t.Name
+	}
+	return versions, nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// apiClient is shared by every HTTP-based backend, so that requests to the
+// same host reuse pooled keep-alive connections (and HTTP/2 streams where the
+// server supports it) instead of paying for a new handshake each time.
+var apiClient = &http.Client{
+	Timeout:       30 * time.Second,
+	Transport:     apiTransport,
+	CheckRedirect: checkRedirect,
+}
+
+var apiTransport = newAPITransport()
+
+// checkRedirect refuses redirects changing the scheme of a request, so no
+// endpoint can send grg to another protocol, and otherwise follows the
+// default policy of at most 10 redirects.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if req.URL.Scheme != via[0].URL.Scheme {
+		return fmt.Errorf("refusing redirect from %s to %s", via[0].URL.Scheme, req.URL.Scheme)
+	}
+	return nil
+}
+
+// newAPITransport returns the pooled transport used by apiClient.
+func newAPITransport() *http.Transport {
+	t := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   16,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	return t
+}
+
+// httpGet performs a GET request for u, cancelled along with ctx.
+func httpGet(ctx context.Context, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	return apiClient.Do(req)
+}
+
+// proxyGet performs a GET request for u, a URL of proxy, an entry of
+// GOPROXY. Entries pointing to a directory through a file:// URL are read
+// through a transport only serving that directory, which never follows
+// redirects.
+func proxyGet(ctx context.Context, proxy, u string) (*http.Response, error) {
+	if !strings.HasPrefix(proxy, "file://") {
+		return httpGet(ctx, u)
+	}
+	base, err := url.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+	if base.Host != "" && base.Host != "localhost" {
+		return nil, fmt.Errorf("GOPROXY entry %s points to a remote host", proxy)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "file:///"+strings.TrimPrefix(strings.TrimPrefix(u, proxy), "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.NewFileTransport(http.Dir(filepath.FromSlash(base.Path))).RoundTrip(req)
+}
+
+// closeBody drains and closes a response body. Connections are only returned
+// to the pool once their body has been fully read.
+func closeBody(res *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 1<<20))
+	_ = res.Body.Close()
+}
+
+// conditionalTransport caches GET responses carrying an ETag or
+// Last-Modified validator under dir, and revalidates them with conditional
+// requests. Unchanged resources are then answered with 304 Not Modified,
+// which APIs such as GitHub's do not count against rate limits, and the
+// cached body is returned as if it had been fetched again.
+type conditionalTransport struct {
+	base http.RoundTripper
+	dir  string
+}
+
+// cachedResponse is the persistent record of a response kept by
+// conditionalTransport.
+type cachedResponse struct {
+	URL          string      `json:"url"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"lastModified,omitempty"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+}
+
+// entryPath returns where the response to req is cached. Credentials are
+// part of the key, so responses are never shared across identities.
+func (t *conditionalTransport) entryPath(req *http.Request) string {
+	h := sha256.New()
+	for _, v := range []string{req.URL.String(), req.Header.Get("Accept"), req.Header.Get("Authorization")} {
+		_, _ = io.WriteString(h, v+"\x00")
+	}
+	return filepath.Join(t.dir, hex.EncodeToString(h.Sum(nil))+".json")
+}
+
+func (t *conditionalTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || (req.URL.Scheme != "http" && req.URL.Scheme != "https") {
+		return t.base.RoundTrip(req)
+	}
+
+	p := t.entryPath(req)
+	var cached *cachedResponse
+	if data, err := os.ReadFile(p); err == nil {
+		cached = &cachedResponse{}
+		if json.Unmarshal(data, cached) != nil || cached.URL != req.URL.String() {
+			cached = nil
+		}
+	}
+	if cached != nil {
+		req = req.Clone(req.Context())
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case res.StatusCode == http.StatusNotModified && cached != nil:
+		closeBody(res)
+		// Refresh the entry's modification time, which cache gc relies on.
+		now := time.Now()
+		_ = os.Chtimes(p, now, now)
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         res.Proto,
+			ProtoMajor:    res.ProtoMajor,
+			ProtoMinor:    res.ProtoMinor,
+			Header:        cached.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(cached.Body)),
+			ContentLength: int64(len(cached.Body)),
+			Request:       req,
+		}, nil
+	case res.StatusCode != http.StatusOK:
+		return res, nil
+	}
+
+	entry := cachedResponse{URL: req.URL.String(), ETag: res.Header.Get("ETag"), LastModified: res.Header.Get("Last-Modified")}
+	if entry.ETag == "" && entry.LastModified == "" {
+		return res, nil
+	}
+	body, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	entry.Header, entry.Body = res.Header.Clone(), body
+	if data, err := json.Marshal(entry); err == nil {
+		if err = writeFileAtomic(p, data); err != nil {
+			warnf(tr("could not update cache: %s"), err)
+		}
+	}
+	return res, nil
+}
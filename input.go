@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"github.com/heyvito/go-require-generator/pkg/input"
+	"strings"
+)
+
+// repoRequest describes a single repository passed to grg.
+type repoRequest struct {
+	// Input is the argument exactly as provided by the user.
+	Input string
+	// ModulePath is the module path emitted in the require line.
+	ModulePath string
+	// LocalURL is set when Input refers to a repository on the local
+	// filesystem, and holds the file:// URL used to clone it.
+	LocalURL string
+	// CloneURL is set when Input is a URL or scp-like address of a remote
+	// repository, and holds the URL it is cloned from as given, with any
+	// scheme, port or user.
+	CloneURL string
+	// Roots lists the paths the repository root of ModulePath may be at,
+	// and Subdir the directory of the repository ModulePath refers to when
+	// there is only one, as input.Input describes. Requests without them
+	// derive them from ModulePath.
+	Roots  []string
+	Subdir string
+	// Ref is the commit, tag or branch requested through the repo@ref
+	// syntax or the --commit, --tag and --branch flags.
+	Ref string
+	// RefKind tells what Ref names.
+	RefKind refKind
+}
+
+// refKind tells what kind of git ref a repoRequest's Ref names.
+type refKind string
+
+const (
+	// refAny refs are commits when they look like a SHA, and tags or,
+	// failing that, branches otherwise.
+	refAny    refKind = ""
+	refCommit refKind = "commit"
+	refTag    refKind = "tag"
+	refBranch refKind = "branch"
+)
+
+// roots returns the host of the request's module path and the paths on it
+// its repository root may be at, from the longest.
+func (r repoRequest) roots() (string, []string, error) {
+	if len(r.Roots) > 0 {
+		host, _, _ := strings.Cut(r.ModulePath, "/")
+		return host, r.Roots, nil
+	}
+	return input.RepoRoots(r.ModulePath)
+}
+
+// isCommit reports whether the request's Ref names a commit.
+func (r repoRequest) isCommit() bool {
+	return r.RefKind == refCommit || (r.RefKind == refAny && input.IsSHA(r.Ref))
+}
+
+// applyRefFlags sets the ref given through --commit, --tag or --branch, at
+// most one of which may be used, on every request without an @ref of its
+// own.
+func applyRefFlags(reqs []repoRequest, commit, tag, branch string) error {
+	var kind refKind
+	var ref string
+	for _, f := range []struct {
+		kind  refKind
+		value string
+	}{{refCommit, commit}, {refTag, tag}, {refBranch, branch}} {
+		if f.value == "" {
+			continue
+		}
+		if ref != "" {
+			return fmt.Errorf("--%s and --%s cannot be combined", kind, f.kind)
+		}
+		kind, ref = f.kind, f.value
+	}
+	if kind == refCommit {
+		if ref = strings.ToLower(ref); !input.IsSHA(ref) {
+			return fmt.Errorf("--commit %s is not a commit SHA of at least 7 characters", commit)
+		}
+	}
+	for i := range reqs {
+		if ref != "" && reqs[i].Ref == "" {
+			reqs[i].Ref, reqs[i].RefKind = ref, kind
+		}
+	}
+	return nil
+}
+
+// buildRequests turns the provided arguments into repoRequests. Local
+// repositories have no module path of their own, so each of them consumes one
+// value from modulePaths, in order.
+func buildRequests(args, modulePaths []string) ([]repoRequest, error) {
+	reqs := make([]repoRequest, 0, len(args))
+	locals := 0
+	for _, arg := range args {
+		in, err := input.Parse(arg)
+		if err != nil {
+			return nil, err
+		}
+		if in.Kind != input.KindLocal {
+			req := repoRequest{Input: arg, ModulePath: in.Path, Roots: in.Roots, Subdir: in.Subdir, Ref: in.Ref}
+			if in.Kind == input.KindURL || in.Kind == input.KindSCP {
+				req.CloneURL = in.CloneURL
+			}
+			reqs = append(reqs, req)
+			continue
+		}
+
+		if locals >= len(modulePaths) {
+			return nil, fmt.Errorf("%s is a local repository; provide its module path through --module-path", arg)
+		}
+		reqs = append(reqs, repoRequest{Input: arg, ModulePath: modulePaths[locals], LocalURL: in.CloneURL, Ref: in.Ref})
+		locals++
+	}
+
+	if locals < len(modulePaths) {
+		return nil, fmt.Errorf("%d module paths were provided for %d local repositories", len(modulePaths), locals)
+	}
+
+	return dedupeRequests(reqs)
+}
+
+// source identifies where and at which version a request is resolved from.
+// Two requests with the same module path and source are interchangeable.
+func (r repoRequest) source() string {
+	src := r.ModulePath
+	if r.LocalURL != "" {
+		src = r.LocalURL
+	}
+	if r.Ref != "" {
+		src += "@" + r.Ref
+	}
+	return src
+}
+
+// dedupeRequests removes requests that would produce the same require line,
+// keeping the first occurrence, and fails when the same module path is
+// requested from different sources, since emitting both would produce
+// contradictory require lines.
+func dedupeRequests(reqs []repoRequest) ([]repoRequest, error) {
+	seen := map[string]repoRequest{}
+	result := make([]repoRequest, 0, len(reqs))
+	for _, r := range reqs {
+		prev, ok := seen[r.ModulePath]
+		if !ok {
+			seen[r.ModulePath] = r
+			result = append(result, r)
+			continue
+		}
+		if prev.source() != r.source() {
+			return nil, fmt.Errorf("%s was requested more than once with conflicting arguments: %s and %s", r.ModulePath, prev.Input, r.Input)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// repoInfo holds metadata about a repository gathered from its clone.
+type repoInfo struct {
+	// License is the SPDX identifier of the detected license, "Unknown" when
+	// a license file exists but was not recognised, or empty when there is
+	// no license file.
+	License string
+	// LastCommit is the commit time of the default branch's HEAD.
+	LastCommit time.Time
+}
+
+var licenseFilePattern = regexp.MustCompile(`(?i)^(license|licence|copying)(\.(md|txt|rst))?$`)
+
+// licenseSignatures maps SPDX identifiers to phrases identifying them, in
+// order of precedence. Every phrase must be present for a match.
+var licenseSignatures = []struct {
+	id      string
+	phrases []string
+}{
+	{"AGPL-3.0", []string{"GNU AFFERO GENERAL PUBLIC LICENSE"}},
+	{"LGPL-3.0", []string{"GNU LESSER GENERAL PUBLIC LICENSE", "Version 3"}},
+	{"LGPL-2.1", []string{"GNU LESSER GENERAL PUBLIC LICENSE"}},
+	{"GPL-3.0", []string{"GNU GENERAL PUBLIC LICENSE", "Version 3"}},
+	{"GPL-2.0", []string{"GNU GENERAL PUBLIC LICENSE", "Version 2"}},
+	{"MPL-2.0", []string{"Mozilla Public License", "2.0"}},
+	{"Apache-2.0", []string{"Apache License", "Version 2.0"}},
+	{"BSD-3-Clause", []string{"Redistribution and use in source and binary forms", "Neither the name"}},
+	{"BSD-2-Clause", []string{"Redistribution and use in source and binary forms"}},
+	{"ISC", []string{"Permission to use, copy, modify, and/or distribute this software for any purpose"}},
+	{"MIT", []string{"Permission is hereby granted, free of charge"}},
+	{"Unlicense", []string{"This is free and unencumbered software released into the public domain"}},
+}
+
+// detectLicense identifies the license of a license file's contents.
+func detectLicense(text string) string {
+	normalized := strings.Join(strings.Fields(text), " ")
+	for _, sig := range licenseSignatures {
+		matches := true
+		for _, p := range sig.phrases {
+			if !strings.Contains(normalized, p) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return sig.id
+		}
+	}
+	return "Unknown"
+}
+
+// inspectRepo gathers repoInfo from the clone in s. Failures leave the
+// corresponding fields empty, as this information is only advisory.
+func inspectRepo(ctx context.Context, s *repoSession) repoInfo {
+	var info repoInfo
+	if err := s.ensureClone(ctx); err != nil {
+		return info
+	}
+	git, repo := s.git, s.repo()
+
+	if ts, err := git.run(ctx, repo, nil, "log", "-1", "--format=%ct"); err == nil {
+		if sec, err := strconv.ParseInt(ts, 10, 64); err == nil {
+			info.LastCommit = time.Unix(sec, 0).UTC()
+		}
+	}
+
+	files, err := git.run(ctx, repo, nil, "ls-tree", "--name-only", "HEAD")
+	if err != nil {
+		return info
+	}
+	for _, name := range strings.Split(files, "\n") {
+		if !licenseFilePattern.MatchString(name) {
+			continue
+		}
+		text, err := git.run(ctx, repo, nil, "show", "HEAD:"+name)
+		if err != nil {
+			continue
+		}
+		info.License = detectLicense(text)
+		break
+	}
+	return info
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// resolveAll resolves reqs with up to jobs repositories in flight at once,
+// calling done with each outcome in the order of reqs, as soon as that
+// outcome and every one before it are available. done is never called
+// concurrently. Requests for hosts in unreachable fail with the host's error
+// without being attempted.
+func resolveAll(ctx context.Context, b runBudget, git *gitRunner, cfg *Config, opts resolveOptions, reqs []repoRequest, jobs int, unreachable map[string]error, done func(outcome)) {
+	if jobs < 1 {
+		jobs = 1
+	}
+	results := make([]chan outcome, len(reqs))
+	for i := range results {
+		results[i] = make(chan outcome, 1)
+	}
+
+	next := make(chan int)
+	go func() {
+		for i := range reqs {
+			next <- i
+		}
+		close(next)
+	}()
+
+	var mu sync.Mutex
+	started := 0
+	for w := 0; w < jobs && w < len(reqs); w++ {
+		go func() {
+			for i := range next {
+				// Each worker gets an equal share of the time left for the
+				// requests it is still expected to process.
+				mu.Lock()
+				left := (len(reqs) - started + jobs - 1) / jobs
+				started++
+				mu.Unlock()
+
+				o := outcome{req: reqs[i]}
+				if err := unreachable[o.host()]; err != nil && reqs[i].LocalURL == "" && reqs[i].CloneURL == "" {
+					o.err = err
+				} else {
+					o.r, o.err = b.process(ctx, git, cfg, opts, reqs[i], left)
+				}
+				results[i] <- o
+			}
+		}()
+	}
+
+	for _, c := range results {
+		done(<-c)
+	}
+}
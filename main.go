@@ -1,14 +1,18 @@
 package main
 
 import (
-	"errors"
+	"context"
 	"fmt"
+	"github.com/heyvito/go-require-generator/pkg/resolver"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 func main() {
@@ -16,240 +20,555 @@ func main() {
 		Name:      "grg",
 		Usage:     "Obtains a require statement based on a git repository",
 		ArgsUsage: "repo-url [repo-url [repo-url [...]]]",
-		Flags: []cli.Flag{
+		Description: "Each repo-url is either a module-like path such as github.com/user/repo, or a\n" +
+			"local repository given as a file:// URL or filesystem path. Local repositories\n" +
+			"require their module path to be declared through --module-path, once per local\n" +
+			"repository, in the same order. A repo-url may end in @ref to resolve a commit,\n" +
+			"tag or branch instead of the latest release.\n\n" +
+			"Without a command, grg resolves the given repositories as grg resolve does. Repositories\n" +
+			"whose path matches a command name must be resolved through grg resolve.",
+		Before: func(ctx *cli.Context) error {
+			if err := setLanguage(ctx.String("lang")); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			return nil
+		},
+		Commands: []*cli.Command{
+			resolveCommand,
+			retractCommand,
+			cleanupCommand,
+			checkCommand,
+			reviewCommand,
+			timelineCommand,
+			auditCommand,
+			confusionCheckCommand,
+			replayCommand,
+			cacheCommand,
+			snippetCommand,
+			recommendCommand,
+			tagReleaseCommand,
+			nextVersionCommand,
+			tryCommand,
+			addCommand,
+			replaceCommand,
+			outdatedCommand,
+			registryCommand,
+			exportResultsCommand,
+			updateCommand,
+			capabilitiesCommand,
+			parseCommand,
+		},
+		Flags: append([]cli.Flag{
 			&cli.BoolFlag{
 				Name:    "verbose",
 				Usage:   "Prints out every command and result",
 				Aliases: []string{"v"},
 			},
-		},
-		Action: func(ctx *cli.Context) error {
-			if ctx.NArg() == 0 {
-				return cli.ShowAppHelp(ctx)
-			}
+			&cli.BoolFlag{
+				Name:  "redact",
+				Usage: "Removes credentials from URLs and environment variables printed in verbose output",
+				Value: true,
+			},
+			&cli.StringFlag{
+				Name:  "config",
+				Usage: "Path to the configuration file",
+				Value: defaultConfigPath(),
+			},
+			&cli.StringFlag{
+				Name:  "cache-dir",
+				Usage: "Directory holding grg's persistent cache; empty disables it",
+				Value: defaultCacheDir(),
+			},
+			&cli.StringFlag{
+				Name:  "max-fetch-size",
+				Usage: "Aborts clones transferring more than `SIZE` (e.g. 200M, 1G)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "stage-timeout",
+				Usage: "Bounds a stage of each resolution, given as `STAGE=DURATION` such as clone=2m; stages are locate, tags, clone, history, proxy and checksum (may be repeated)",
+			},
+			&cli.StringFlag{
+				Name:  "protocol",
+				Usage: "Protocol attempted first: ssh, https, or auto to pick the fastest one per host",
+				Value: "auto",
+			},
+			&cli.IntFlag{
+				Name:  "git-nice",
+				Usage: "Runs git with the given `NICENESS` (1-19) to reduce its CPU priority",
+			},
+			&cli.StringFlag{
+				Name:  "io-limit",
+				Usage: "Runs git with reduced IO priority: idle or low",
+			},
+			&cli.StringFlag{
+				Name:  "lang",
+				Usage: "Language of messages, such as en or pt-BR; defaults to the locale in LC_ALL, LC_MESSAGES or LANG",
+			},
+			&cli.StringFlag{
+				Name:  "log-file",
+				Usage: "Appends verbose messages and full git transcripts, with credentials redacted, to `FILE`",
+			},
+		}, resolveFlags...),
+		Action: resolveAction,
+	}
 
-			gitPath, err := exec.LookPath("git")
-			if err != nil {
-				return cli.Exit("Could not find git in your PATH", 1)
-			}
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
 
-			var results []string
-			errorList := map[string]string{}
+// resolveOptionsFromFlags builds the resolution options given through the
+// global flags, along with the pins of the nearest overrides file. Errors are
+// returned ready to be handed back to cli.
+func resolveOptionsFromFlags(ctx *cli.Context) (resolveOptions, error) {
+	scheme, err := parseVersionScheme(ctx.String("version-scheme"))
+	if err != nil {
+		return resolveOptions{}, cli.Exit(err.Error(), 1)
+	}
+	opts := resolveOptions{
+		Channel:          ctx.String("channel"),
+		Nightly:          ctx.Bool("nightly"),
+		CrossCheck:       ctx.Bool("cross-check"),
+		CacheDir:         ctx.String("cache-dir"),
+		AcceptMovedTag:   ctx.Bool("accept-moved-tag"),
+		Platforms:        ctx.StringSlice("platform"),
+		Scheme:           scheme,
+		VerifySignatures: ctx.Bool("verify-signatures"),
+		WarnUnsigned:     ctx.Bool("warn-unsigned"),
+		Proxy:            ctx.Bool("proxy"),
+		GoVersion:        ctx.String("compatible-with-go"),
+	}
+	if opts.CacheDir != "" && !ctx.Bool("no-registry") {
+		reg, err := loadRegistry(opts.CacheDir)
+		if err != nil {
+			return resolveOptions{}, cli.Exit(trf("Could not read the registry: %s", err), 1)
+		}
+		if len(reg.Modules) > 0 {
+			opts.Registry = reg
+		}
+	}
+	if c := ctx.String("constraint"); c != "" {
+		if opts.Constraint, err = parseConstraint(c); err != nil {
+			return resolveOptions{}, cli.Exit(err.Error(), 1)
+		}
+	}
+	switch {
+	case ctx.Bool("include-pre") && ctx.Bool("stable-only"):
+		return resolveOptions{}, cli.Exit(tr("--include-pre and --stable-only cannot be used together"), 1)
+	case ctx.Bool("include-pre"):
+		opts.Prereleases = preInclude
+	case ctx.Bool("stable-only"):
+		opts.Prereleases = preExclude
+	}
+	if err = opts.validate(); err != nil {
+		return resolveOptions{}, cli.Exit(err.Error(), 1)
+	}
 
-			for _, v := range ctx.Args().Slice() {
-				r, err := processRepo(ctx.IsSet("verbose"), v, gitPath)
-				if err != nil {
-					errorList[v] = err.Error()
-				} else {
-					results = append(results, r)
-				}
-			}
+	overridesPath, err := findOverrides(".")
+	if err == nil {
+		opts.Overrides, err = loadOverrides(overridesPath)
+	}
+	if err != nil {
+		return resolveOptions{}, cli.Exit(trf("Could not load %s: %s", overridesFileName, err), 1)
+	}
+	return opts, nil
+}
 
-			fmt.Println()
-			if len(errorList) > 0 {
-				fmt.Println("The following errors were found:")
-				for repo, err := range errorList {
-					fmt.Printf("  %s: %s\n", repo, err)
-				}
-				fmt.Println()
-			}
+// setup locates git and loads the configuration according to the global
+// flags. Errors are returned ready to be handed back to cli.
+func setup(ctx *cli.Context) (*gitRunner, *Config, error) {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		return nil, nil, cli.Exit(tr("Could not find git in your PATH"), 1)
+	}
 
-			if len(results) > 0 {
-				for _, v := range results {
-					fmt.Println(v)
-				}
-			}
+	cfg, err := loadConfig(ctx.String("config"), ctx.IsSet("config"))
+	if err != nil {
+		return nil, nil, cli.Exit(trf("Could not load configuration: %s", err), 1)
+	}
 
-			if len(errorList) > 0 {
-				return cli.Exit("One or more repositories could not be processed", 1)
-			}
+	git := &gitRunner{
+		path:    gitPath,
+		verbose: ctx.IsSet("verbose"),
+		redact:  ctx.Bool("redact"),
+	}
+	if p := ctx.String("log-file"); p != "" {
+		f, err := os.OpenFile(p, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, nil, cli.Exit(trf("Could not open log file: %s", err), 1)
+		}
+		git.logFile = &syncWriter{w: f}
+		_, _ = fmt.Fprintf(f, "grg started at %s: %s\n", time.Now().UTC().Format(time.RFC3339), redactString(strings.Join(os.Args, " ")))
+	}
+	git.logEnvironment()
 
-			return nil
-		},
+	git.protocols, err = newProtocolSelector(ctx.String("protocol"), ctx.String("cache-dir"))
+	if err != nil {
+		return nil, nil, cli.Exit(err.Error(), 1)
 	}
 
-	if err := app.Run(os.Args); err != nil {
-		log.Fatal(err)
+	git.wrapper, err = priorityWrapper(ctx.Int("git-nice"), ctx.String("io-limit"))
+	if err != nil {
+		return nil, nil, cli.Exit(err.Error(), 1)
 	}
+
+	git.stageTimeouts, err = parseStageTimeouts(ctx.StringSlice("stage-timeout"))
+	if err != nil {
+		return nil, nil, cli.Exit(err.Error(), 1)
+	}
+
+	if ctx.IsSet("max-fetch-size") {
+		git.maxFetchSize, err = parseByteSize(ctx.String("max-fetch-size"))
+		if err != nil {
+			return nil, nil, cli.Exit(err.Error(), 1)
+		}
+	}
+
+	if dir := ctx.String("cache-dir"); dir != "" {
+		apiClient.Transport = &conditionalTransport{base: apiTransport, dir: filepath.Join(dir, "http")}
+	}
+
+	return git, cfg, nil
 }
 
-type GitExecError struct {
-	StdOut        string
-	StdErr        string
-	Status        int
-	OriginalError error
+// resolution is the outcome of resolving a single repository.
+type resolution struct {
+	Path    string
+	Version string
+	// Note is an optional remark emitted as a comment on the require line.
+	Note string
+	// Info holds repository metadata, when requested through
+	// resolveOptions.Inspect.
+	Info *repoInfo
+	// Warnings lists the non-fatal conditions found during resolution.
+	Warnings []warning
+	// Timings holds the time spent in each stage of the resolution, for
+	// --explain.
+	Timings *stageTimings
 }
 
-func (e GitExecError) Error() string {
-	return fmt.Sprintf("Failed to execute git command. Exit code %d: %s", e.Status, e.OriginalError)
+func (r resolution) requireLine() string {
+	return resolver.Requirement{Path: r.Path, Version: r.Version, Note: r.Note}.String()
 }
 
-func gitFail(stdout, stderr strings.Builder, err error) error {
-	status := -1
-	var e *exec.ExitError
-	if errors.As(err, &e) {
-		status = e.ExitCode()
+func processRepo(ctx context.Context, git *gitRunner, cfg *Config, opts resolveOptions, req repoRequest) (resolution, error) {
+	if opts.Proxy {
+		return processProxy(ctx, git, cfg, opts, req)
 	}
-	return GitExecError{
-		OriginalError: err,
-		StdOut:        stdout.String(),
-		StdErr:        stderr.String(),
-		Status:        status,
+	s, err := openSession(ctx, git, cfg, req)
+	if err != nil {
+		return resolution{}, err
 	}
-}
+	defer s.close()
 
-func cloneRepo(verbose bool, name, into, gitExec string, ssh bool) error {
-	args := []string{"clone", "--depth=1", "--bare"}
-	hostPath := strings.SplitN(name, "/", 2)
-	host, path := hostPath[0], hostPath[1]
-	splitPath := strings.Split(path, "/")
-	if len(splitPath) > 2 {
-		path = strings.Join(splitPath[0:2], "/")
+	path := req.ModulePath
+	if len(cfg.Policy.Internal) > 0 {
+		host, err := s.sourceHost(ctx)
+		if err != nil {
+			return resolution{}, err
+		}
+		if err = cfg.Policy.checkSource(path, host); err != nil {
+			return resolution{}, err
+		}
 	}
 
-	if ssh {
-		args = append(args, fmt.Sprintf("git@%s:%s", host, path))
-	} else {
-		args = append(args, fmt.Sprintf("https://%s/%s", host, path))
+	// A version recorded in the registry stands in for selecting among the
+	// tags, but goes through the same checks as a selected one.
+	r, ok := opts.Registry.lookup(git, cfg, opts, req)
+	if !ok {
+		r, err = selectVersion(ctx, s, path, opts, req)
+	}
+	if err == nil {
+		r, err = sanitizeVersion(ctx, s, r)
+	}
+	if err != nil {
+		return resolution{}, err
 	}
+	reportDuplicateTags(s, &r)
+	if s.location.Guessed {
+		r.warn(warnPathGuessed, "repository root guessed as %s", s.location.Root)
+	}
+	if req.Ref == "" {
+		r = opts.Overrides.apply(r)
+	}
+	r, err = enforcePolicy(ctx, s, cfg.Policy, opts, req, r)
+	if err == nil {
+		r, err = enforceRetractions(ctx, s, cfg.Policy, opts, req, r)
+	}
+	if err == nil {
+		r, err = enforceGoVersion(ctx, s, cfg.Policy, opts, req, r)
+	}
+	if err != nil {
+		return resolution{}, err
+	}
+	if !s.remoteOnly() {
+		if err = applyDeclaredPath(ctx, s, &r, s.subdir); err != nil {
+			return resolution{}, err
+		}
+	}
+	if err = checkMovedTags(ctx, s, opts, &r); err != nil {
+		return resolution{}, err
+	}
+	if opts.CrossCheck {
+		if err = crossCheck(ctx, s, &r); err != nil {
+			return resolution{}, err
+		}
+	}
+	if opts.VerifySignatures {
+		if err = verifySignature(ctx, s, cfg.Policy, r); err != nil {
+			return resolution{}, err
+		}
+	}
+	checkReleaseAssets(ctx, git, &r, s.remote, s.tagPrefix, opts.Platforms)
+	if s.remoteOnly() {
+		r.warn(warnRemoteOnly, "no writable temporary directory; resolved from the advertised tags only")
+		return r, nil
+	}
+	// Checking signatures fetches the tag, which is only worth it when the
+	// user or the policy cares about them.
+	signed := opts.WarnUnsigned || len(cfg.Policy.signers(r.Path)) > 0
+	if signed && !opts.VerifySignatures && !module.IsPseudoVersion(r.Version) && r.Note == "" {
+		checkTagSignature(ctx, s, &r)
+	}
+	if opts.Inspect {
+		info := inspectRepo(ctx, s)
+		r.Info = &info
+	}
+	return r, nil
+}
 
-	args = append(args, "repo")
+// repoLocation is where the repository a request refers to was found.
+type repoLocation struct {
+	// URL is the URL the repository is queried and cloned from.
+	URL string
+	// Root is the path the repository root is imported as, when it is
+	// shorter than the requested path and was not derived from it: when it
+	// was guessed by probing, or declared by a go-import meta tag.
+	Root string
+	// Guessed is set when Root was found by probing.
+	Guessed bool
+}
 
-	if verbose {
-		fmt.Printf("verbose: Executing %s %s\n", gitExec, strings.Join(args, " "))
+// locateRequest finds the URL of the repository req refers to through
+// ls-remote, without cloning it. Paths on hosts grg does not know are first
+// looked up through go-import meta tags, as the go command does, so vanity
+// import paths resolve to the repository serving them.
+func locateRequest(ctx context.Context, git *gitRunner, cfg *Config, req repoRequest) (repoLocation, error) {
+	if req.LocalURL != "" {
+		if err := lsRemote(ctx, git, req.LocalURL, true); err != nil {
+			return repoLocation{}, fmt.Errorf("failed clonning local repository %s: %w", req.LocalURL, gitFailure(err))
+		}
+		return repoLocation{URL: req.LocalURL}, nil
 	}
 
-	cmd := exec.Command(gitExec, args...)
-	cmd.Dir = into
+	path := req.ModulePath
+	host, candidates, err := req.roots()
+	if err != nil {
+		return repoLocation{}, err
+	}
+	if req.CloneURL != "" {
+		if err := lsRemote(ctx, git, req.CloneURL, true); err != nil {
+			return repoLocation{}, fmt.Errorf("failed clonning %s: %w", req.CloneURL, gitFailure(err))
+		}
+		return repoLocation{URL: req.CloneURL, Root: host + "/" + candidates[0]}, nil
+	}
 
-	var stdout, stderr strings.Builder
+	if discoversImports(cfg, host) {
+		meta, ok, err := discoverImport(ctx, git, strings.Trim(path, "/"))
+		if err != nil {
+			return repoLocation{}, err
+		}
+		if ok {
+			if err = lsRemote(ctx, git, meta.RepoRoot, true); err != nil {
+				return repoLocation{}, fmt.Errorf("failed clonning %s, which serves %s: %w", meta.RepoRoot, meta.Prefix, gitFailure(err))
+			}
+			git.logf("Using repository %s for %s\n", meta.RepoRoot, meta.Prefix)
+			return repoLocation{URL: meta.RepoRoot, Root: meta.Prefix}, nil
+		}
+	}
 
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	err := cmd.Run()
+	repoPath, url, err := probeRepo(git, cfg, host, candidates, func(url string, prompt bool) error {
+		return lsRemote(ctx, git, url, prompt)
+	})
 	if err != nil {
-		if verbose {
-			fmt.Printf("verbose: Error executing:\n")
-			lines := strings.Split(stdout.String(), "\n")
-			lines = append(lines, strings.Split(stderr.String(), "\n")...)
-			for i, v := range lines {
-				lines[i] = "        " + v
+		if suggestions := suggestRepos(ctx, git, host, candidates[0]); len(suggestions) > 0 {
+			rest := strings.TrimPrefix(strings.Trim(path, "/"), host+"/"+candidates[0])
+			for i := range suggestions {
+				suggestions[i] += rest
 			}
-			fmt.Printf("%s\n", strings.Join(lines, "\n"))
+			return repoLocation{}, fmt.Errorf("%w. Did you mean %s?", err, strings.Join(suggestions, " or "))
 		}
-		return gitFail(stdout, stderr, err)
+		return repoLocation{}, err
 	}
-
-	return nil
+	git.logf("Using repository %s/%s\n", host, repoPath)
+	if len(candidates) > 1 && repoPath != candidates[0] {
+		return repoLocation{URL: url, Root: host + "/" + repoPath, Guessed: true}, nil
+	}
+	return repoLocation{URL: url}, nil
 }
 
-func getLastTag(verbose bool, gitExec, dir string) (bool, string) {
-	cmd := exec.Command(gitExec, "describe", "--tags", "--abbrev=0")
-	if verbose {
-		fmt.Printf("verbose: Executing %s %s\n", gitExec, strings.Join(cmd.Args, " "))
-	}
-	cmd.Dir = filepath.Join(dir, "repo")
-	var stdout, stderr strings.Builder
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	err := cmd.Run()
-	if err != nil {
-		if verbose {
-			fmt.Printf("verbose: Error executing:\n")
-			lines := strings.Split(stdout.String(), "\n")
-			lines = append(lines, strings.Split(stderr.String(), "\n")...)
-			for i, v := range lines {
-				lines[i] = "        " + v
-			}
-			fmt.Printf("%s\n", strings.Join(lines, "\n"))
-		}
-		return false, ""
+// enforcePolicy verifies r against the policy's version bounds. When the
+// latest version is above a ceiling, the newest allowed release is selected
+// instead; any other violation is an error.
+func enforcePolicy(ctx context.Context, s *repoSession, policy Policy, opts resolveOptions, req repoRequest, r resolution) (resolution, error) {
+	violations := policy.check(r.Path, r.Version)
+	if len(violations) == 0 {
+		return r, nil
 	}
 
-	return true, strings.TrimSpace(stdout.String())
+	floor := false
+	for _, v := range violations {
+		floor = floor || !v.Ceiling
+	}
+	if floor || req.Ref != "" || opts.Channel != "" || opts.Nightly || r.Note != "" {
+		return resolution{}, violations[0]
+	}
+
+	tags, err := s.remoteTags(ctx)
+	if err != nil {
+		return resolution{}, fmt.Errorf("failed listing tags: %w", err)
+	}
+	tag, ok := policy.newestAllowed(r.Path, tags)
+	if !ok {
+		return resolution{}, fmt.Errorf("%w; no release satisfies the policy", violations[0])
+	}
+	res := resolution{Path: r.Path, Version: tag.Name, Warnings: r.Warnings}
+	res.warn(warnPolicyDowngrade, "%s; selected %s instead", violations[0], tag.Name)
+	return res, nil
 }
 
-func getLastCommit(verbose bool, gitExec, dir string) (bool, string, string) {
-	cmd := exec.Command(gitExec, "log", "-1", "--date=format-local:%Y%m%d%H%M%S", "--format=%cd")
-	if verbose {
-		fmt.Printf("verbose: Executing %s %s\n", gitExec, strings.Join(cmd.Args, " "))
-	}
-	cmd.Dir = filepath.Join(dir, "repo")
-	cmd.Env = append(os.Environ(), "TZ=GMT")
-	var stdout, stderr strings.Builder
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	err := cmd.Run()
-	if err != nil {
-		if verbose {
-			fmt.Printf("verbose: Error executing:\n")
-			lines := strings.Split(stdout.String(), "\n")
-			lines = append(lines, strings.Split(stderr.String(), "\n")...)
-			for i, v := range lines {
-				lines[i] = "        " + v
-			}
-			fmt.Printf("%s\n", strings.Join(lines, "\n"))
+// selectVersion picks the version to emit for the repository cloned in s.
+func selectVersion(ctx context.Context, s *repoSession, path string, opts resolveOptions, req repoRequest) (resolution, error) {
+	if s.remoteOnly() && opts.Channel == "" && opts.Scheme == nil {
+		return selectRemoteVersion(ctx, s, path, opts, req)
+	}
+	if req.Ref != "" {
+		return resolveRef(ctx, s, path, req)
+	}
+
+	if opts.Channel != "" {
+		tags, err := s.remoteTags(ctx)
+		if err != nil {
+			return resolution{}, fmt.Errorf("failed listing tags: %w", err)
+		}
+		tag, ok := latestInChannel(tags, opts.Channel)
+		if !ok {
+			return resolution{}, fmt.Errorf("no %s pre-release tags found", opts.Channel)
 		}
-		return false, "", ""
+		return resolution{Path: path, Version: tag.Name}, nil
 	}
 
-	ts := strings.TrimSpace(stdout.String())
+	if opts.Nightly {
+		version, err := pseudoVersion(ctx, s, path, "HEAD")
+		if err != nil {
+			return resolution{}, err
+		}
+		return resolution{Path: path, Version: version, Note: "nightly: tip of the default branch"}, nil
+	}
 
-	cmd = exec.Command(gitExec, "rev-parse", "--short=12", "HEAD")
-	if verbose {
-		fmt.Printf("verbose: Executing %s %s\n", gitExec, strings.Join(cmd.Args, " "))
+	if opts.Scheme != nil {
+		return selectSchemeVersion(ctx, s, path, opts.Scheme)
 	}
-	cmd.Dir = filepath.Join(dir, "repo")
-	stdout.Reset()
-	stderr.Reset()
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	err = cmd.Run()
+
+	// The latest release is not necessarily on the default branch's
+	// history, as with release branches, so it is picked among every
+	// advertised tag rather than described from HEAD.
+	tags, err := s.remoteTags(ctx)
 	if err != nil {
-		if verbose {
-			fmt.Printf("verbose: Error executing:\n")
-			lines := strings.Split(stdout.String(), "\n")
-			lines = append(lines, strings.Split(stderr.String(), "\n")...)
-			for i, v := range lines {
-				lines[i] = "        " + v
-			}
-			fmt.Printf("%s\n", strings.Join(lines, "\n"))
-		}
-		return false, "", ""
+		return resolution{}, fmt.Errorf("failed listing tags: %w", err)
+	}
+	if tag, ok, err := latestAllowed(path, tags, opts, moduleFileOf(ctx, s)); err != nil || ok {
+		return resolution{Path: path, Version: tag.Name}, err
 	}
 
-	commit := strings.TrimSpace(stdout.String())
-
-	return true, commit, ts
+	version, err := pseudoVersion(ctx, s, path, "HEAD")
+	if err != nil {
+		return resolution{}, err
+	}
+	r := resolution{Path: path, Version: version}
+	r.warn(warnPseudoVersion, "no semver tag found; using a pseudo-version of the default branch's HEAD")
+	return r, nil
 }
 
-func processRepo(verbose bool, path string, gitPath string) (string, error) {
-	dir, err := os.MkdirTemp("", "")
+// selectRemoteVersion picks the version to emit for a remote-only session.
+// Versions are picked from the advertised tags as selectVersion does, but
+// without a clone, pseudo-versions cannot be built at all.
+func selectRemoteVersion(ctx context.Context, s *repoSession, path string, opts resolveOptions, req repoRequest) (resolution, error) {
+	if opts.Nightly {
+		return resolution{}, s.errRemoteOnly("--nightly")
+	}
+	tags, err := s.remoteTags(ctx)
 	if err != nil {
-		return "", err
+		return resolution{}, fmt.Errorf("failed listing tags: %w", err)
 	}
-	defer func(path string) { _ = os.RemoveAll(path) }(dir)
 
-	err = cloneRepo(verbose, path, dir, gitPath, true)
-	if err != nil {
-		if verbose {
-			fmt.Printf("verbose: Error cloning repository: %s\n", err)
+	if req.Ref != "" {
+		commit := req.Ref
+		if !req.isCommit() {
+			t, err := s.lookupRef(ctx, req)
+			if err != nil {
+				return resolution{}, err
+			}
+			if semver.IsValid(t.Name) {
+				return resolution{Path: path, Version: t.Name}, nil
+			}
+			commit = t.Commit
 		}
-		err = cloneRepo(verbose, path, dir, gitPath, false)
-		if err != nil {
-			return "", fmt.Errorf("failed clonning via HTTPS and SSH. Check you have access to the repository")
+		if tag, ok := tagAtCommit(tags, commit); ok {
+			return resolution{Path: path, Version: tag.Name}, nil
 		}
+		return resolution{}, s.errRemoteOnly("resolving untagged or abbreviated commits")
+	}
+
+	// go.mod files cannot be read to tell usable tags apart, so the
+	// advertised tags are taken as they are.
+	tag, ok, err := latestAllowed(path, tags, opts, nil)
+	if err != nil {
+		return resolution{}, err
+	}
+	if !ok {
+		return resolution{}, s.errRemoteOnly("building a pseudo-version for a repository without semver tags")
+	}
+	return resolution{Path: path, Version: tag.Name}, nil
+}
+
+// resolveRef resolves a version for the commit, tag or branch requested by
+// req. Semver tags are emitted as they are; other tags and branches are
+// resolved through the commit they point to.
+func resolveRef(ctx context.Context, s *repoSession, path string, req repoRequest) (resolution, error) {
+	if req.isCommit() {
+		return resolveCommit(ctx, s, path, req.Ref)
+	}
+	t, err := s.lookupRef(ctx, req)
+	if err != nil {
+		return resolution{}, err
+	}
+	if semver.IsValid(t.Name) {
+		return resolution{Path: path, Version: t.Name}, nil
 	}
+	s.git.logf("%s points at commit %s\n", req.Ref, t.Commit)
+	return resolveCommit(ctx, s, path, t.Commit)
+}
 
-	hasTag, tagName := getLastTag(verbose, gitPath, dir)
-	if hasTag && strings.HasPrefix(tagName, "v") {
-		return fmt.Sprintf("require %s %s", path, tagName), nil
+// resolveCommit resolves a version for the commit identified by sha,
+// preferring a semver tag pointing at it over a pseudo-version.
+func resolveCommit(ctx context.Context, s *repoSession, path, sha string) (resolution, error) {
+	full, err := fetchCommit(ctx, s, sha)
+	if err != nil {
+		return resolution{}, err
 	}
 
-	ok, commit, ts := getLastCommit(verbose, gitPath, dir)
-	if ok {
-		return fmt.Sprintf("require %s v0.0.0-%s-%s", path, ts, commit), nil
+	tags, _ := s.remoteTags(ctx)
+	if tag, ok := tagAtCommit(tags, full); ok {
+		s.git.logf("Commit %s is tagged as %s\n", sha, tag.Name)
+		return resolution{Path: path, Version: tag.Name}, nil
 	}
 
-	return "", fmt.Errorf("failed obtaining information from clonned repository")
+	version, err := pseudoVersion(ctx, s, path, full)
+	if err != nil {
+		return resolution{}, err
+	}
+	r := resolution{Path: path, Version: version}
+	r.warn(warnPseudoVersion, "no tag points at commit %s; using a pseudo-version", sha)
+	return r, nil
 }
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// catalogs holds translations of user-facing messages, keyed by language
+// tag and then by the English format string. Messages missing from a
+// catalog are printed in English.
+var catalogs = map[string]map[string]string{
+	"pt-BR": {
+		"warning: ":                                                 "aviso: ",
+		"warning[%s]: %s: %s\n":                                     "aviso[%s]: %s: %s\n",
+		"The following errors were found:":                          "Os seguintes erros foram encontrados:",
+		"One or more repositories could not be processed":           "Um ou mais repositórios não puderam ser processados",
+		"Interrupted":                                               "Interrompido",
+		"Could not find git in your PATH":                           "Não foi possível encontrar o git no seu PATH",
+		"Could not load configuration: %s":                          "Não foi possível carregar a configuração: %s",
+		"Could not open log file: %s":                               "Não foi possível abrir o arquivo de log: %s",
+		"Could not read batch: %s":                                  "Não foi possível ler o lote: %s",
+		"Could not load %s: %s":                                     "Não foi possível carregar %s: %s",
+		"Could not read the registry: %s":                           "Não foi possível ler o registro: %s",
+		"Could not write run manifest: %s":                          "Não foi possível gravar o manifesto da execução: %s",
+		"Batch contains %d repositories, exceeding the limit of %d": "O lote contém %d repositórios, excedendo o limite de %d",
+		"Unknown format %q; valid formats are %s":                   "Formato %q desconhecido; os formatos válidos são %s",
+		"Unknown line terminator %q; use lf or crlf":                "Terminador de linha %q desconhecido; use lf ou crlf",
+		"Unknown sort key %q; use path, version or host":            "Chave de ordenação %q desconhecida; use path, version ou host",
+		"Unknown grouping %q; only host is supported":               "Agrupamento %q desconhecido; apenas host é suportado",
+		"--jobs must be at least 1, got %d":                         "--jobs deve ser pelo menos 1, recebido %d",
+		"--with-sum is only supported with --format require":        "--with-sum só é suportado com --format require",
+		"Unknown language %q; available languages are %s":           "Idioma %q desconhecido; os idiomas disponíveis são %s",
+		"--include-pre and --stable-only cannot be used together":   "--include-pre e --stable-only não podem ser usados juntos",
+
+		// next-version and tag-release.
+		"Not inside a git repository: %s":                                     "Não está dentro de um repositório git: %s",
+		"No previous release":                                                 "Nenhuma versão anterior",
+		"Last release: %s%s\n":                                                "Última versão: %s%s\n",
+		"Commits since: %d (%d breaking, %d features, %d fixes)\n":            "Commits desde então: %d (%d incompatíveis, %d funcionalidades, %d correções)\n",
+		"No feature, fix or breaking change since the last release":           "Nenhuma funcionalidade, correção ou mudança incompatível desde a última versão",
+		"Next version: %s%s\n":                                                "Próxima versão: %s%s\n",
+		"The module path must end in /%s before this release can be tagged\n": "O caminho do módulo deve terminar em /%s antes que esta versão possa ser marcada\n",
+		"%s is not a canonical semantic version":                              "%s não é uma versão semântica canônica",
+		"Cannot tag the release:":                                             "Não é possível marcar a versão:",
+		"Would tag %s as %s\n":                                                "%s seria marcado como %s\n",
+		"Could not tag %s: %s":                                                "Não foi possível marcar %s: %s",
+		"Tagged %s as %s\n":                                                   "%s marcado como %s\n",
+		"\nPublish the release with: git push --atomic %s %s\n":               "\nPublique a versão com: git push --atomic %s %s\n",
+		"Could not push the tags to %s, which were deleted locally: %s":       "Não foi possível enviar as tags para %s, que foram removidas localmente: %s",
+		"Pushed %s to %s\n":                                                   "%s enviadas para %s\n",
+		"could not delete the tags %s: %s":                                    "não foi possível remover as tags %s: %s",
+		"%s declares no module":                                               "%s não declara nenhum módulo",
+		"cannot release %s as %s: %s versions require a module path without a major version suffix": "não é possível lançar %s como %s: versões %s exigem um caminho de módulo sem sufixo de versão principal",
+		"cannot release %s as %s: the module path must end in /%s":                                  "não é possível lançar %s como %s: o caminho do módulo deve terminar em /%s",
+		"%s is outside of the repository at %s":                                                     "%s está fora do repositório em %s",
+		"%s is listed more than once":                                                               "%s está listado mais de uma vez",
+		"%s has uncommitted changes":                                                                "%s tem alterações não confirmadas",
+		"tag %s already exists":                                                                     "a tag %s já existe",
+		"%s requires %s %s instead of %s":                                                           "%s requer %s %s em vez de %s",
+
+		// --proxy.
+		"local repositories cannot be resolved through a module proxy": "repositórios locais não podem ser resolvidos por um proxy de módulos",
+		"%w; no release satisfies the policy":                          "%w; nenhuma versão satisfaz a política",
+		"%s; selected %s instead":                                      "%s; %s selecionada em seu lugar",
+		"no %s pre-release versions found":                             "nenhuma versão de pré-lançamento %s encontrada",
+		"%w; no older version is available":                            "%w; nenhuma versão anterior está disponível",
+		"module downloads are disabled by GOPROXY=off":                 "os downloads de módulos estão desativados por GOPROXY=off",
+		"%s was not found on any proxy":                                "%s não foi encontrado em nenhum proxy",
+
+		// Module paths declared by go.mod files.
+		"%s at %s declares no module path":            "%s em %s não declara um caminho de módulo",
+		"failed reading the module path of %s %s: %w": "falha ao ler o caminho do módulo de %s %s: %w",
+		"go.mod at %s declares module %s without the /%s suffix its version requires; the go command cannot use this version": "o go.mod em %s declara o módulo %s sem o sufixo /%s que sua versão exige; o comando go não pode usar esta versão",
+		"requested as %s, but go.mod at %s declares this module path":                                                         "solicitado como %s, mas o go.mod em %s declara este caminho de módulo",
+
+		// Editing go.mod: add, update, replace, retract, cleanup and snippet.
+		"--use-local requires --workspace":                                               "--use-local requer --workspace",
+		"--smoke-test requires --write":                                                  "--smoke-test requer --write",
+		"--%s and --%s cannot be used together":                                          "--%s e --%s não podem ser usados juntos",
+		"--%s cannot be combined with --constraint":                                      "--%s não pode ser combinado com --constraint",
+		"--fix cannot be combined with --modfile, which is meant for read-only analysis": "--fix não pode ser combinado com --modfile, que é destinado a análises somente leitura",
+		"Could not read %s: %s":                                                          "Não foi possível ler %s: %s",
+		"Could not write %s: %s":                                                         "Não foi possível gravar %s: %s",
+		"Could not obtain the checksums of %s %s: %s":                                    "Não foi possível obter os checksums de %s %s: %s",
+		"Wrote %s\n":                     "%s gravado\n",
+		"\nUpdated %s\n":                 "\n%s atualizado\n",
+		"Added %s %s\n":                  "%s %s adicionado\n",
+		"%s is already required at %s\n": "%s já é exigido em %s\n",
+		"Updated %s from %s to %s\n":     "%s atualizado de %s para %s\n",
+		"go mod tidy removed %s, as no package imports it yet\n":         "go mod tidy removeu %s, pois nenhum pacote o importa ainda\n",
+		"%s is already used from %s\n":                                   "%s já é usado a partir de %s\n",
+		"Using %s from %s instead of requiring %s\n":                     "Usando %s a partir de %s em vez de exigir %s\n",
+		"%s %s is available as %s, which requires changing imports\n":    "%s %s está disponível como %s, o que exige alterar as importações\n",
+		"%s was aligned at %s, which a %s update does not allow\n":       "%s foi alinhado em %s, o que uma atualização %s não permite\n",
+		"All requirements are up to date":                                "Todas as dependências estão atualizadas",
+		"No changes were written":                                        "Nenhuma alteração foi gravada",
+		"Replaced %s with %s %s in %s\n":                                 "%s substituído por %s %s em %s\n",
+		"%s does not require %s, so the replacement has no effect yet\n": "%s não exige %s, então a substituição ainda não tem efeito\n",
+		"%s is already retracted\n":                                      "%s já está retirada\n",
+		"Retracted %s\n":                                                 "%s retirada\n",
+		"Duplicate requires:":                                            "Requires duplicados:",
+		"Obsolete excludes:":                                             "Excludes obsoletos:",
+		"Stale replaces:":                                                "Replaces desatualizados:",
+		"Nothing to clean up":                                            "Nada a limpar",
+		"Run grg cleanup --fix to remove them.":                          "Execute grg cleanup --fix para removê-los.",
+		"Unknown snippet %q":                                             "Trecho %q desconhecido",
+		"Added snippet %s to %s\n":                                       "Trecho %s adicionado a %s\n",
+		"No snippets are configured":                                     "Nenhum trecho está configurado",
+
+		// Reports: audit, outdated, check, review, timeline, recommend and
+		// confusion.
+		"--workspace cannot be combined with --modfile":            "--workspace não pode ser combinado com --modfile",
+		"A go.mod path cannot be combined with --modfile":          "Um caminho de go.mod não pode ser combinado com --modfile",
+		"No go.mod changed since %s\n":                             "Nenhum go.mod foi alterado desde %s\n",
+		"No requirements found":                                    "Nenhuma dependência encontrada",
+		"MODULE\tREQUIRED\tLATEST\tVULNERABILITIES\tUSED BY":       "MÓDULO\tEXIGIDA\tMAIS RECENTE\tVULNERABILIDADES\tUSADO POR",
+		"MODULE\tCURRENT\tWANTED\tLATEST\tSEVERITY":                "MÓDULO\tATUAL\tDESEJADA\tMAIS RECENTE\tSEVERIDADE",
+		"Unknown format %q":                                        "Formato %q desconhecido",
+		"Could not query vulnerabilities: %s":                      "Não foi possível consultar vulnerabilidades: %s",
+		"No issues found":                                          "Nenhum problema encontrado",
+		"The following issues were found:":                         "Os seguintes problemas foram encontrados:",
+		"No dependency changes.":                                   "Nenhuma alteração de dependências.",
+		"## Dependency review\n\nComparing `%s` against `%s`.\n\n": "## Revisão de dependências\n\nComparando `%s` com `%s`.\n\n",
+		"| Module | Change | Version | Latest | License | Vulnerabilities | Last activity |": "| Módulo | Alteração | Versão | Mais recente | Licença | Vulnerabilidades | Última atividade |",
+		"\n### Removed\n\n":                                   "\n### Removidos\n\n",
+		"No releases found":                                   "Nenhuma versão encontrada",
+		"TAG\tDATE\tINTERVAL\tCOMMITS":                        "TAG\tDATA\tINTERVALO\tCOMMITS",
+		"Could not read the project's imports: %s":            "Não foi possível ler as importações do projeto: %s",
+		"No recommendations":                                  "Nenhuma recomendação",
+		"// %s (%s): could not be resolved: %s\n":             "// %s (%s): não pôde ser resolvido: %s\n",
+		"Could not check %s: %s":                              "Não foi possível verificar %s: %s",
+		"%s: not found publicly\n":                            "%s: não encontrado publicamente\n",
+		"One or more internal modules are available publicly": "Um ou mais módulos internos estão disponíveis publicamente",
+		"One or more repositories could not be resolved":      "Um ou mais repositórios não puderam ser resolvidos",
+
+		// The cache, the registry and run manifests.
+		"The cache is disabled":                               "O cache está desativado",
+		"Could not read the cache: %s":                        "Não foi possível ler o cache: %s",
+		"could not read cache: %s":                            "não foi possível ler o cache: %s",
+		"could not update cache: %s":                          "não foi possível atualizar o cache: %s",
+		"skipping corrupted cache entry %s":                   "ignorando a entrada corrompida do cache %s",
+		"MODULE\tTAGS\tHITS\tLAST USED\tSIZE":                 "MÓDULO\tTAGS\tACERTOS\tÚLTIMO USO\tTAMANHO",
+		"HOST\tPROTOCOL\tMEASURED":                            "HOST\tPROTOCOLO\tMEDIDO EM",
+		"Directory:  %s\n":                                    "Diretório:  %s\n",
+		"Size:       %s in %d files\n":                        "Tamanho:    %s em %d arquivos\n",
+		"Modules:    %d, recording %d tags\n":                 "Módulos:    %d, registrando %d tags\n",
+		"Hosts:      %d\n":                                    "Hosts:      %d\n",
+		"Hit rate:   %.1f%% (%d of %d lookups)\n":             "Acertos:    %.1f%% (%d de %d consultas)\n",
+		"No modules given":                                    "Nenhum módulo informado",
+		"%s is not cached":                                    "%s não está em cache",
+		"could not remove %s: %s":                             "não foi possível remover %s: %s",
+		"removed %s\n":                                        "%s removido\n",
+		"At least one of --max-size or --max-age is required": "É necessário pelo menos um entre --max-size e --max-age",
+		"evicted %s (last used %s)\n":                         "%s descartado (último uso em %s)\n",
+		"Freed %s; %d entries remain, using %s\n":             "%s liberados; restam %d entradas, usando %s\n",
+		"Would free %s; %d entries remain, using %s\n":        "%s seriam liberados; restariam %d entradas, usando %s\n",
+		"Could not list the projects of %s: %s":               "Não foi possível listar os projetos de %s: %s",
+		"could not read go.mod of %s: %s":                     "não foi possível ler o go.mod de %s: %s",
+		"could not list tags of %s: %s":                       "não foi possível listar as tags de %s: %s",
+		"no release":                                          "nenhuma versão",
+		"Could not write the registry: %s":                    "Não foi possível gravar o registro: %s",
+		"Synced %s: %d modules updated, %d recorded\n":        "%s sincronizado: %d módulos atualizados, %d registrados\n",
+		"Replay did not complete: %s":                         "A repetição não foi concluída: %s",
+		"environment differs: %s":                             "o ambiente difere: %s",
+		"Replay of %s matches the recorded results\n":         "A repetição de %s corresponde aos resultados gravados\n",
+		"The replay differs from the recorded run:":           "A repetição difere da execução gravada:",
+
+		// Other commands and flags.
+		"--%s must be given after %s":                                   "--%s deve ser informado depois de %s",
+		"ionice is not available on this system; --io-limit is ignored": "ionice não está disponível neste sistema; --io-limit é ignorado",
+		"nice is not available on this system; --git-nice is ignored":   "nice não está disponível neste sistema; --git-nice é ignorado",
+		"explain: %s took %s\n":                                         "explain: %s levou %s\n",
+		"The module was kept at %s\n":                                   "O módulo foi mantido em %s\n",
+		"Could not create the module: %s":                               "Não foi possível criar o módulo: %s",
+		"exit it to discard the module":                                 "saia dele para descartar o módulo",
+		"exit it when done":                                             "saia dele ao terminar",
+		"Opening %s in a module requiring %s %s; %s\n":                  "Abrindo %s em um módulo que exige %s %s; %s\n",
+	},
+}
+
+// language is the language tag messages are printed in. Empty means
+// English.
+var language string
+
+// setLanguage selects the catalog used by tr. An empty tag is taken from the
+// environment, falling back to English for unknown locales; an explicitly
+// requested language must exist.
+func setLanguage(tag string) error {
+	explicit := tag != ""
+	if !explicit {
+		for _, k := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+			if tag = os.Getenv(k); tag != "" {
+				break
+			}
+		}
+	}
+
+	lang, ok := matchLanguage(tag)
+	if !ok && explicit {
+		available := []string{"en"}
+		for l := range catalogs {
+			available = append(available, l)
+		}
+		return fmt.Errorf(tr("Unknown language %q; available languages are %s"), tag, strings.Join(available, ", "))
+	}
+	language = lang
+	return nil
+}
+
+// matchLanguage finds the catalog for a language tag or POSIX locale such as
+// pt_BR.UTF-8, preferring an exact match and then one on the base language.
+func matchLanguage(tag string) (string, bool) {
+	tag, _, _ = strings.Cut(tag, ".")
+	tag, _, _ = strings.Cut(tag, "@")
+	tag = strings.ReplaceAll(tag, "_", "-")
+	base, _, _ := strings.Cut(tag, "-")
+	if strings.EqualFold(base, "en") || tag == "C" || tag == "POSIX" {
+		return "", true
+	}
+	for l := range catalogs {
+		if strings.EqualFold(l, tag) {
+			return l, true
+		}
+	}
+	for l := range catalogs {
+		if lb, _, _ := strings.Cut(l, "-"); strings.EqualFold(lb, base) {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+// tr returns the translation of the English message format in the selected
+// language.
+func tr(format string) string {
+	if t, ok := catalogs[language][format]; ok {
+		return t
+	}
+	return format
+}
+
+// trf formats the translation of format with args.
+func trf(format string, args ...any) string {
+	return fmt.Sprintf(tr(format), args...)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// parseSources parses the sources of the package, leaving tests out.
+func parseSources(t *testing.T) (*token.FileSet, []*ast.File) {
+	t.Helper()
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	var result []*ast.File
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, f)
+	}
+	return fset, result
+}
+
+// messageKeys returns the string literals passed to tr and trf in the
+// sources of the package, mapped to where they are used.
+func messageKeys(t *testing.T) map[string]string {
+	t.Helper()
+	fset, files := parseSources(t)
+	keys := map[string]string{}
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if fn, ok := n.(*ast.FuncDecl); ok && (fn.Name.Name == "tr" || fn.Name.Name == "trf") {
+				// trf translates its own format through tr.
+				return false
+			}
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			if fn, ok := call.Fun.(*ast.Ident); !ok || (fn.Name != "tr" && fn.Name != "trf") {
+				return true
+			}
+			lit, ok := call.Args[0].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				t.Errorf("%s: message is not a string literal", fset.Position(call.Pos()))
+				return true
+			}
+			key, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys[key] = fset.Position(lit.Pos()).String()
+			return true
+		})
+	}
+	return keys
+}
+
+// formatVerb matches the verbs of a format string.
+var formatVerb = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestCatalogs(t *testing.T) {
+	keys := messageKeys(t)
+	if len(keys) == 0 {
+		t.Fatal("no message found")
+	}
+	for lang, catalog := range catalogs {
+		for key, pos := range keys {
+			translation, ok := catalog[key]
+			if !ok {
+				t.Errorf("%s: %q has no %s translation", pos, key, lang)
+				continue
+			}
+			want, got := formatVerb.FindAllString(key, -1), formatVerb.FindAllString(translation, -1)
+			if !slices.Equal(got, want) {
+				t.Errorf("%s translation of %q has verbs %v, want %v", lang, key, got, want)
+			}
+		}
+		for key := range catalog {
+			if _, ok := keys[key]; !ok {
+				t.Errorf("%s catalog translates %q, which is never printed", lang, key)
+			}
+		}
+	}
+}
+
+// untranslated lists output meant for programs rather than people, which is
+// printed in English regardless of the language.
+var untranslated = map[string]bool{
+	// grg capabilities, whose keys match the JSON output.
+	"version\t%s\n":         true,
+	"backends\t%s\n":        true,
+	"formats\t%s\n":         true,
+	"version schemes\t%s\n": true,
+	"commands\t%s\n":        true,
+	"schemas\t%s\n":         true,
+	// The header of --log-file, like the rest of the log.
+	"grg started at %s: %s\n": true,
+}
+
+// printedArgs returns the arguments of call holding text printed to the
+// user: messages given to cli.Exit and warnf, and what fmt prints.
+func printedArgs(call *ast.CallExpr) []ast.Expr {
+	if len(call.Args) == 0 {
+		return nil
+	}
+	switch fn := call.Fun.(type) {
+	case *ast.Ident:
+		if fn.Name == "warnf" {
+			return call.Args[:1]
+		}
+	case *ast.SelectorExpr:
+		pkg, ok := fn.X.(*ast.Ident)
+		switch {
+		case !ok:
+		case pkg.Name == "cli" && fn.Sel.Name == "Exit":
+			return call.Args[:1]
+		case pkg.Name == "fmt" && strings.HasPrefix(fn.Sel.Name, "Fprint"):
+			return call.Args[1:]
+		case pkg.Name == "fmt" && strings.HasPrefix(fn.Sel.Name, "Print"):
+			return call.Args
+		}
+	}
+	return nil
+}
+
+func TestMessagesTranslated(t *testing.T) {
+	fset, files := parseSources(t)
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			for _, arg := range printedArgs(call) {
+				if c, ok := arg.(*ast.CallExpr); ok && len(c.Args) > 0 {
+					if fn, ok := c.Fun.(*ast.SelectorExpr); ok && fn.Sel.Name == "Sprintf" {
+						arg = c.Args[0]
+					}
+				}
+				lit, ok := arg.(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					continue
+				}
+				text, err := strconv.Unquote(lit.Value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// Formats without words, such as table rows, need no
+				// translation.
+				words := strings.IndexFunc(formatVerb.ReplaceAllString(text, ""), unicode.IsLetter) >= 0
+				if words && !untranslated[text] {
+					t.Errorf("%s: %q is printed without going through tr or trf", fset.Position(lit.Pos()), text)
+				}
+			}
+			return true
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"path"
+	"strings"
+)
+
+// moduleSubdir returns the directory of the repository req refers to that
+// holds the requested module, given the path of the repository root when it
+// was not derived from the requested path, as described by repoLocation.
+func moduleSubdir(req repoRequest, root string) string {
+	if req.LocalURL != "" {
+		return ""
+	}
+	host, candidates, err := req.roots()
+	if err != nil {
+		return ""
+	}
+	if root == "" {
+		root = host + "/" + candidates[0]
+	}
+	return strings.Trim(strings.TrimPrefix(strings.Trim(req.ModulePath, "/"), root), "/")
+}
+
+// declaredModulePath reads the module path declared by the nearest go.mod
+// from subdir up to the repository root, at the commit r.Version refers to.
+// Requested paths may name a package inside a module, and like the go
+// command, a module in a major version subdirectory such as v2 may also be
+// declared by the go.mod at the parent directory, on a major version branch.
+// An empty path is returned when there is no go.mod.
+func declaredModulePath(ctx context.Context, s *repoSession, r resolution, subdir string) (string, error) {
+	file, data, err := moduleFileAt(ctx, s, r, subdir)
+	if err != nil || data == nil {
+		return "", err
+	}
+	mod := modfile.ModulePath(data)
+	if mod == "" {
+		return "", fmt.Errorf(tr("%s at %s declares no module path"), file, r.Version)
+	}
+	return mod, nil
+}
+
+// moduleFileAt returns the name and contents of the nearest go.mod from
+// subdir up to the repository root, at the commit r.Version refers to. The
+// contents are nil when there is no go.mod.
+func moduleFileAt(ctx context.Context, s *repoSession, r resolution, subdir string) (string, []byte, error) {
+	if s.remoteOnly() {
+		return "", nil, s.errRemoteOnly("reading go.mod")
+	}
+	commit, err := versionCommit(ctx, s, r)
+	if err != nil {
+		return "", nil, err
+	}
+	return commitModuleFile(ctx, s, commit, subdir)
+}
+
+// commitModuleFile returns the name and contents of the nearest go.mod from
+// subdir up to the repository root at commit, a full SHA, fetching it when
+// the clone lacks it. The contents are nil when there is no go.mod.
+func commitModuleFile(ctx context.Context, s *repoSession, commit, subdir string) (string, []byte, error) {
+	if err := s.ensureRepo(ctx); err != nil {
+		return "", nil, err
+	}
+	if _, err := s.git.run(ctx, s.repo(), nil, "cat-file", "-e", commit+"^{commit}"); err != nil {
+		if _, err = fetchCommit(ctx, s, commit); err != nil {
+			return "", nil, err
+		}
+	}
+
+	for dir := subdir; ; dir = path.Dir(dir) {
+		if dir == "." {
+			dir = ""
+		}
+		file := path.Join(dir, "go.mod")
+		if _, err := s.git.run(ctx, s.repo(), nil, "cat-file", "-e", commit+":"+file); err != nil {
+			if dir == "" {
+				return "", nil, nil
+			}
+			continue
+		}
+		data, err := s.git.run(ctx, s.repo(), nil, "cat-file", "blob", commit+":"+file)
+		if err != nil {
+			return "", nil, err
+		}
+		return file, []byte(data), nil
+	}
+}
+
+// moduleFileOf returns a function reading the go.mod of the module in s at
+// a tag, or nil when there is none, as resolver.LatestModuleVersion expects.
+func moduleFileOf(ctx context.Context, s *repoSession) func(remoteTag) ([]byte, error) {
+	return func(t remoteTag) ([]byte, error) {
+		_, data, err := commitModuleFile(ctx, s, t.Commit, s.subdir)
+		return data, err
+	}
+}
+
+// applyDeclaredPath replaces r.Path with the module path declared by the
+// repository's go.mod, warning when it differs from the requested path.
+// Versions from v2 on of modules without a go.mod are marked +incompatible,
+// as the go command requires. Selection skips such versions of modules
+// whose go.mod lacks the major version suffix, so only explicitly requested
+// ones fail here.
+func applyDeclaredPath(ctx context.Context, s *repoSession, r *resolution, subdir string) error {
+	declared, err := declaredModulePath(ctx, s, *r, subdir)
+	if err != nil {
+		return fmt.Errorf(tr("failed reading the module path of %s %s: %w"), r.Path, r.Version, err)
+	}
+
+	major := semver.Major(r.Version)
+	if major != "v0" && major != "v1" && semver.Build(r.Version) == "" {
+		switch _, suffix, _ := module.SplitPathVersion(declared); {
+		case declared == "":
+			s.git.logf("%s %s has no go.mod; marking it +incompatible\n", r.Path, r.Version)
+			r.Version += "+incompatible"
+			return nil
+		case suffix == "":
+			return fmt.Errorf(tr("go.mod at %s declares module %s without the /%s suffix its version requires; the go command cannot use this version"), r.Version, declared, major)
+		}
+	}
+
+	if declared == "" || declared == r.Path {
+		return nil
+	}
+	// Modules at v2 and above carry their major version as a path suffix,
+	// which users rarely type; adding it is expected rather than suspicious.
+	if prefix, suffix, ok := module.SplitPathVersion(declared); ok && prefix == r.Path && suffix != "" && suffix == "/"+major {
+		s.git.logf("Module %s %s is declared as %s\n", r.Path, r.Version, declared)
+		r.Path = declared
+		return nil
+	}
+	r.warn(warnModulePathMismatch, tr("requested as %s, but go.mod at %s declares this module path"), r.Path, r.Version)
+	r.Path = declared
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/mod/module"
+)
+
+// movedTagError is returned when a tag points to a different commit than
+// the one recorded when it was first seen.
+type movedTagError struct {
+	Module   string
+	Tag      string
+	Previous string
+	Current  string
+}
+
+func (e movedTagError) Error() string {
+	return fmt.Sprintf("tag %s of %s moved from %s to %s since it was last seen; use --accept-moved-tag if this is expected",
+		e.Tag, e.Module, e.Previous, e.Current)
+}
+
+// checkMovedTags compares the tags advertised for r.Path against those
+// recorded in the cache, failing when the emitted version's tag was moved,
+// and records newly seen tags. Other moved tags are only reported, and keep
+// their original record so they continue to be flagged.
+func checkMovedTags(ctx context.Context, s *repoSession, opts resolveOptions, r *resolution) error {
+	if opts.CacheDir == "" {
+		return nil
+	}
+	cache := moduleCache{dir: opts.CacheDir}
+	entry, err := cache.load(r.Path)
+	if err != nil {
+		warnf(tr("could not read cache: %s"), err)
+		return nil
+	}
+
+	tags, err := s.remoteTags(ctx)
+	if err != nil {
+		return fmt.Errorf("failed listing tags: %w", err)
+	}
+
+	var moved *movedTagError
+	for _, t := range tags {
+		prev, ok := entry.Tags[t.Name]
+		if !ok || prev == t.Commit {
+			entry.Tags[t.Name] = t.Commit
+			continue
+		}
+
+		if t.Name == versionTag(r.Version) && !module.IsPseudoVersion(r.Version) {
+			if opts.AcceptMovedTag {
+				r.warn(warnMovedTagAccepted, "accepted moved tag %s (%s → %s)", t.Name, prev, t.Commit)
+				entry.Tags[t.Name] = t.Commit
+				continue
+			}
+			moved = &movedTagError{Module: r.Path, Tag: t.Name, Previous: prev, Current: t.Commit}
+			continue
+		}
+		s.git.logf("Tag %s of %s moved from %s to %s\n", t.Name, r.Path, prev, t.Commit)
+	}
+
+	if err = cache.save(entry); err != nil {
+		warnf(tr("could not update cache: %s"), err)
+	}
+	if moved != nil {
+		return *moved
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/semver"
+	"regexp"
+	"strings"
+)
+
+var nextVersionCommand = &cli.Command{
+	Name:  "next-version",
+	Usage: "Suggests the next release of the current repository from the conventional commits since the last one",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "module",
+			Usage: "Directory of the module to release; nested modules are tagged with their directory as prefix",
+			Value: "./",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		git, _, err := setup(ctx)
+		if err != nil {
+			return err
+		}
+		root, err := git.run(ctx.Context, "", nil, "rev-parse", "--show-toplevel")
+		if err != nil {
+			return cli.Exit(trf("Not inside a git repository: %s", err), 1)
+		}
+		dir, err := repoRelativeDir(root, ctx.String("module"))
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		prefix := releaseTagPrefix(dir)
+
+		last, err := lastRelease(ctx.Context, git, root, prefix)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		since := ""
+		if last != "" {
+			since = prefix + last
+		}
+		commits, err := commitsSince(ctx.Context, git, root, since, dir)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+
+		var bump releaseBump
+		counts := map[releaseBump]int{}
+		for _, c := range commits {
+			b := c.bump()
+			counts[b]++
+			if b > bump {
+				bump = b
+			}
+		}
+
+		if last == "" {
+			fmt.Println(tr("No previous release"))
+		} else {
+			fmt.Printf(tr("Last release: %s%s\n"), prefix, last)
+		}
+		fmt.Printf(tr("Commits since: %d (%d breaking, %d features, %d fixes)\n"), len(commits), counts[bumpMajor], counts[bumpMinor], counts[bumpPatch])
+		for _, c := range commits {
+			if b := c.bump(); b != bumpNone {
+				fmt.Printf("  %-8s %s\n", b, c.Subject)
+			}
+		}
+		if bump == bumpNone && last != "" {
+			fmt.Println(tr("No feature, fix or breaking change since the last release"))
+			return nil
+		}
+
+		next := nextVersion(last, bump)
+		fmt.Printf(tr("Next version: %s%s\n"), prefix, next)
+		if last != "" && semver.Major(next) != semver.Major(last) && semver.Major(next) != "v1" {
+			fmt.Printf(tr("The module path must end in /%s before this release can be tagged\n"), semver.Major(next))
+		}
+		return nil
+	},
+}
+
+// releaseBump is the part of a version a change requires bumping, ordered
+// by significance.
+type releaseBump int
+
+const (
+	bumpNone releaseBump = iota
+	bumpPatch
+	bumpMinor
+	bumpMajor
+)
+
+func (b releaseBump) String() string {
+	return [...]string{"none", "fix", "feature", "breaking"}[b]
+}
+
+// conventionalHeader matches the header of a conventional commit, such as
+// "feat(api)!: add streaming".
+var conventionalHeader = regexp.MustCompile(`^([a-zA-Z]+)(\([^)]*\))?(!)?: \S`)
+
+// conventionalCommit is a commit message, split as conventional commits
+// are.
+type conventionalCommit struct {
+	Subject string
+	// Type is the lowercased type of the header, empty for commits not
+	// following the convention.
+	Type     string
+	Breaking bool
+}
+
+// parseConventionalCommit parses message, the full message of a commit.
+// Breaking changes are marked with ! after the type or scope, or with a
+// BREAKING CHANGE footer.
+func parseConventionalCommit(message string) conventionalCommit {
+	subject, body, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	c := conventionalCommit{Subject: strings.TrimSpace(subject)}
+	m := conventionalHeader.FindStringSubmatch(c.Subject)
+	if m == nil {
+		return c
+	}
+	c.Type = strings.ToLower(m[1])
+	c.Breaking = m[3] == "!"
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "BREAKING CHANGE:") || strings.HasPrefix(line, "BREAKING-CHANGE:") {
+			c.Breaking = true
+		}
+	}
+	return c
+}
+
+// bump returns the release bump the commit calls for: breaking changes
+// call for a major release, features for a minor one, and fixes and
+// performance improvements for a patch.
+func (c conventionalCommit) bump() releaseBump {
+	switch {
+	case c.Breaking:
+		return bumpMajor
+	case c.Type == "feat":
+		return bumpMinor
+	case c.Type == "fix" || c.Type == "perf":
+		return bumpPatch
+	}
+	return bumpNone
+}
+
+// nextVersion returns the release following last for bump. Before v1,
+// breaking changes only bump the minor version, as v0 makes no
+// compatibility promise. Modules without releases start at v0.1.0.
+func nextVersion(last string, bump releaseBump) string {
+	if last == "" {
+		return "v0.1.0"
+	}
+	var major, minor, patch int
+	_, _ = fmt.Sscanf(semver.Canonical(last), "v%d.%d.%d", &major, &minor, &patch)
+	if bump == bumpMajor && major == 0 {
+		bump = bumpMinor
+	}
+	switch bump {
+	case bumpMajor:
+		return fmt.Sprintf("v%d.0.0", major+1)
+	case bumpMinor:
+		return fmt.Sprintf("v%d.%d.0", major, minor+1)
+	}
+	return fmt.Sprintf("v%d.%d.%d", major, minor, patch+1)
+}
+
+// lastRelease returns the highest release version among the tags carrying
+// prefix that are reachable from HEAD, without the prefix. It returns an
+// empty string when there is none.
+func lastRelease(ctx context.Context, git *gitRunner, root, prefix string) (string, error) {
+	out, err := git.run(ctx, root, nil, "tag", "--list", "--merged", "HEAD", prefix+"v*")
+	if err != nil {
+		return "", fmt.Errorf("failed listing tags: %w", err)
+	}
+	var last string
+	for _, tag := range strings.Split(out, "\n") {
+		v := strings.TrimPrefix(strings.TrimSpace(tag), prefix)
+		if semver.IsValid(v) && semver.Prerelease(v) == "" && (last == "" || semver.Compare(v, last) > 0) {
+			last = v
+		}
+	}
+	return last, nil
+}
+
+// commitsSince parses the commits reachable from HEAD but not from tag that
+// touch dir, or every commit touching dir when tag is empty.
+func commitsSince(ctx context.Context, git *gitRunner, root, tag, dir string) ([]conventionalCommit, error) {
+	args := []string{"log", "--format=%B%x00"}
+	if tag != "" {
+		args = append(args, tag+"..HEAD")
+	}
+	out, err := git.run(ctx, root, nil, append(args, "--", dir)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing commits: %w", err)
+	}
+	var commits []conventionalCommit
+	for _, message := range strings.Split(out, "\x00") {
+		if strings.TrimSpace(message) != "" {
+			commits = append(commits, parseConventionalCommit(message))
+		}
+	}
+	return commits, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const osvQueryURL = "https://api.osv.dev/v1/query"
+
+// vulnerability is a known vulnerability affecting a module version.
+type vulnerability struct {
+	ID      string   `json:"id"`
+	Summary string   `json:"summary"`
+	Aliases []string `json:"aliases"`
+}
+
+// queryVulnerabilities asks OSV for vulnerabilities affecting module at
+// version, cancelled along with ctx. OSV records Go versions without the
+// leading "v".
+func queryVulnerabilities(ctx context.Context, module, version string) ([]vulnerability, error) {
+	body, err := json.Marshal(map[string]any{
+		"version": strings.TrimPrefix(version, "v"),
+		"package": map[string]string{
+			"name":      module,
+			"ecosystem": "Go",
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, osvQueryURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := apiClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(res)
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s querying vulnerabilities for %s", res.Status, module)
+	}
+
+	var result struct {
+		Vulns []vulnerability `json:"vulns"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Vulns, nil
+}
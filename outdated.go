@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+var outdatedCommand = &cli.Command{
+	Name:      "outdated",
+	Usage:     "Lists the requirements of a go.mod with newer versions available",
+	ArgsUsage: "[go.mod]",
+	Description: "For each direct requirement, WANTED is the version grg would select with the global flags,\n" +
+		"pins and policy in effect, kept within the required major version unless --constraint is\n" +
+		"given, while LATEST ignores all of them. SEVERITY tells how far LATEST is from the current\n" +
+		"version: major, minor, patch or pre-release. The go.mod of the current module is used unless\n" +
+		"a path is given. grg exits with status 1 when any requirement is outdated.",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "indirect",
+			Usage: "Also lists requirements marked // indirect",
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "Prints the outdated requirements as a JSON array",
+		},
+		modfileFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() > 1 {
+			return cli.ShowSubcommandHelp(ctx)
+		}
+		if ctx.NArg() == 1 && ctx.IsSet("modfile") {
+			return cli.Exit(tr("A go.mod path cannot be combined with --modfile"), 1)
+		}
+		path, err := outdatedGoModPath(ctx)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		f, err := readGoMod(path)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		git, cfg, err := setup(ctx)
+		if err != nil {
+			return err
+		}
+		opts, err := resolveOptionsFromFlags(ctx)
+		if err != nil {
+			return err
+		}
+
+		var rows []outdatedRow
+		for _, r := range f.Require {
+			if r.Indirect && !ctx.Bool("indirect") {
+				continue
+			}
+			if row := checkOutdated(ctx.Context, git, cfg, opts, r.Mod); row.outdated() {
+				rows = append(rows, row)
+			}
+		}
+		if ctx.Bool("json") {
+			if rows == nil {
+				rows = []outdatedRow{}
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err = enc.Encode(rows); err != nil {
+				return err
+			}
+		} else if len(rows) == 0 {
+			fmt.Println(tr("All requirements are up to date"))
+		} else {
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, tr("MODULE\tCURRENT\tWANTED\tLATEST\tSEVERITY"))
+			for _, r := range rows {
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Path, r.Current, r.Wanted, r.Latest, r.Severity)
+			}
+			if err = w.Flush(); err != nil {
+				return err
+			}
+		}
+		if len(rows) > 0 {
+			return cli.Exit("", 1)
+		}
+		return nil
+	},
+}
+
+// outdatedGoModPath returns the path of the go.mod given as argument, which
+// may also name the directory holding it, falling back to --modfile and the
+// go.mod of the current module.
+func outdatedGoModPath(ctx *cli.Context) (string, error) {
+	p := ctx.Args().First()
+	if p == "" {
+		return goModPath(ctx)
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		p = filepath.Join(p, "go.mod")
+		if _, err = os.Stat(p); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Abs(p)
+}
+
+// outdatedRow compares a requirement with the versions available for it.
+// Versions that could not be resolved are reported as "?".
+type outdatedRow struct {
+	Path    string `json:"path"`
+	Current string `json:"current"`
+	Wanted  string `json:"wanted"`
+	Latest  string `json:"latest"`
+	// Severity classifies the update from Current to Latest, empty when
+	// there is none.
+	Severity string `json:"severity,omitempty"`
+}
+
+func (r outdatedRow) outdated() bool {
+	return r.Latest == "?" || r.Wanted == "?" || r.Severity != "" || semver.Compare(r.Wanted, r.Current) > 0
+}
+
+// checkOutdated resolves the wanted and latest versions of m. The wanted
+// version follows opts and the configured policy, restricted to the major
+// version of m when opts has no constraint, as other major versions are
+// other modules; the latest one follows neither.
+func checkOutdated(ctx context.Context, git *gitRunner, cfg *Config, opts resolveOptions, m module.Version) outdatedRow {
+	row := outdatedRow{Path: m.Path, Current: m.Version, Wanted: "?", Latest: "?"}
+	repoPath, _, _ := module.SplitPathVersion(m.Path)
+	req := repoRequest{Input: m.Path, ModulePath: repoPath}
+
+	if wanted, err := processRepo(ctx, git, cfg, updateOptions(opts, m.Version, "minor"), req); err != nil {
+		git.logf("Could not resolve %s: %s\n", m.Path, err)
+	} else {
+		row.Wanted = wanted.Version
+	}
+
+	unrestricted := *cfg
+	unrestricted.Policy = Policy{}
+	if latest, err := processRepo(ctx, git, &unrestricted, resolveOptions{CacheDir: opts.CacheDir}, req); err != nil {
+		git.logf("Could not resolve %s: %s\n", m.Path, err)
+	} else {
+		row.Latest = latest.Version
+		row.Severity = updateSeverity(m.Version, latest.Version)
+	}
+	return row
+}
+
+// updateSeverity classifies the update from current to target by the most
+// significant part of the version that changes, returning an empty string
+// when target is not newer.
+func updateSeverity(current, target string) string {
+	if semver.Compare(target, current) <= 0 {
+		return ""
+	}
+	switch {
+	case semver.Major(target) != semver.Major(current):
+		return "major"
+	case semver.MajorMinor(target) != semver.MajorMinor(current):
+		return "minor"
+	case versionCore(target) != versionCore(current):
+		return "patch"
+	}
+	return "pre-release"
+}
+
+// versionCore returns v without its pre-release and build metadata.
+func versionCore(v string) string {
+	v = semver.Canonical(v)
+	return strings.TrimSuffix(v, semver.Prerelease(v))
+}
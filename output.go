@@ -0,0 +1,312 @@
+package main
+
+import (
+	"fmt"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// outputOptions holds the flags that tune output formats.
+type outputOptions struct {
+	// Shell is the shell scripts are generated for.
+	Shell string
+	// EnvPrefix is prepended to the variable names of the env format.
+	EnvPrefix string
+	// EnvKey selects which part of the module path the env format derives
+	// variable names from: base or full.
+	EnvKey string
+}
+
+// outputFormat renders the successful resolutions of an invocation as
+// output lines.
+type outputFormat func(rs []resolution, o outputOptions) ([]string, error)
+
+// outputFormats maps --format values to their outputFormat.
+var outputFormats = map[string]outputFormat{
+	"require":    formatRequire,
+	"go-get":     formatGoGet,
+	"go-install": formatGoInstall,
+	"script":     formatScript,
+	"env":        formatEnv,
+	"brew":       formatBrew,
+	"scoop":      formatScoop,
+}
+
+// machineFormats lists formats whose output is consumed as a whole by other
+// tools, and must therefore not be mixed with error reports.
+var machineFormats = map[string]bool{
+	"script": true,
+	"env":    true,
+	"brew":   true,
+	"scoop":  true,
+}
+
+// streamedFormats lists formats rendering each resolution independently,
+// whose lines can therefore be printed as soon as a repository is resolved.
+var streamedFormats = map[string]bool{
+	"require":    true,
+	"go-install": true,
+}
+
+// outputFormatNames returns the valid --format values, sorted.
+func outputFormatNames() []string {
+	names := make([]string, 0, len(outputFormats))
+	for n := range outputFormats {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func formatRequire(rs []resolution, _ outputOptions) ([]string, error) {
+	lines := make([]string, len(rs))
+	for i, r := range rs {
+		lines[i] = r.requireLine()
+	}
+	return lines, nil
+}
+
+// formatGoGet renders a single go get command adding every resolution.
+func formatGoGet(rs []resolution, _ outputOptions) ([]string, error) {
+	args := []string{"go", "get"}
+	for _, r := range rs {
+		q, err := quoteArg(r.Path + "@" + r.Version)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, q)
+	}
+	return []string{strings.Join(args, " ")}, nil
+}
+
+// formatGoInstall renders one go install command per resolution, as go
+// install requires all of its arguments to belong to the same module.
+func formatGoInstall(rs []resolution, _ outputOptions) ([]string, error) {
+	lines := make([]string, len(rs))
+	for i, r := range rs {
+		q, err := quoteArg(r.Path + "@" + r.Version)
+		if err != nil {
+			return nil, err
+		}
+		lines[i] = "go install " + q
+	}
+	return lines, nil
+}
+
+var (
+	// safeArg matches arguments every supported shell passes through
+	// unchanged without quoting.
+	safeArg = regexp.MustCompile(`^[A-Za-z0-9_./:@+~=-]+$`)
+	// unquotableArg matches characters that keep a special meaning inside
+	// double quotes in at least one of POSIX shells, PowerShell or cmd.
+	unquotableArg = regexp.MustCompile("[$`\"\\\\%!\\r\\n]")
+)
+
+// quoteArg quotes v so it is passed as a single argument by POSIX shells,
+// PowerShell and cmd alike. Double quotes are the only quoting those shells
+// share, so values containing characters that are special inside them in
+// any of the shells are rejected.
+func quoteArg(v string) (string, error) {
+	if safeArg.MatchString(v) {
+		return v, nil
+	}
+	if unquotableArg.MatchString(v) {
+		return "", fmt.Errorf("%q cannot be quoted portably across shells", v)
+	}
+	return `"` + v + `"`, nil
+}
+
+// scriptShells maps --shell values to the preamble and the per-module line of
+// the scripts generated for them. Scripts only run go get for modules not
+// already at the resolved version, so running them again is harmless.
+var scriptShells = map[string]struct {
+	preamble []string
+	line     func(path, version string) string
+}{
+	"bash": {
+		preamble: []string{
+			"#!/usr/bin/env bash",
+			"# Generated by grg.",
+			"set -euo pipefail",
+			"",
+			"command -v go >/dev/null 2>&1 || { echo 'go is not installed' >&2; exit 1; }",
+			"[ -f go.mod ] || { echo 'run this script from the module root' >&2; exit 1; }",
+			"",
+			"require() {",
+			"  if [ \"$(go list -m -f '{{.Version}}' \"$1\" 2>/dev/null)\" = \"$2\" ]; then",
+			"    echo \"$1 is already at $2\"",
+			"    return",
+			"  fi",
+			"  go get \"$1@$2\"",
+			"}",
+			"",
+		},
+		line: func(path, version string) string {
+			return fmt.Sprintf("require %s %s", quotePOSIX(path), quotePOSIX(version))
+		},
+	},
+	"pwsh": {
+		preamble: []string{
+			"# Generated by grg.",
+			"$ErrorActionPreference = 'Stop'",
+			"",
+			"if (-not (Get-Command go -ErrorAction SilentlyContinue)) { throw 'go is not installed' }",
+			"if (-not (Test-Path go.mod)) { throw 'run this script from the module root' }",
+			"",
+			"function Set-ModuleVersion([string]$Path, [string]$Version) {",
+			"    $current = go list -m -f '{{.Version}}' $Path 2>$null",
+			"    if ($current -eq $Version) {",
+			"        Write-Host \"$Path is already at $Version\"",
+			"        return",
+			"    }",
+			"    go get \"$Path@$Version\"",
+			"    if ($LASTEXITCODE -ne 0) { throw \"go get $Path@$Version failed\" }",
+			"}",
+			"",
+		},
+		line: func(path, version string) string {
+			return fmt.Sprintf("Set-ModuleVersion %s %s", quotePowerShell(path), quotePowerShell(version))
+		},
+	},
+}
+
+// formatScript renders a script for o.Shell that brings every resolution
+// into the go.mod of the directory it runs from.
+func formatScript(rs []resolution, o outputOptions) ([]string, error) {
+	sh, ok := scriptShells[o.Shell]
+	if !ok {
+		return nil, fmt.Errorf("unknown shell %q; use bash or pwsh", o.Shell)
+	}
+	lines := append([]string{}, sh.preamble...)
+	for _, r := range rs {
+		lines = append(lines, sh.line(r.Path, r.Version))
+	}
+	return lines, nil
+}
+
+// quotePOSIX single-quotes v for POSIX shells.
+func quotePOSIX(v string) string {
+	return "'" + strings.ReplaceAll(v, "'", `'\''`) + "'"
+}
+
+// quotePowerShell single-quotes v for PowerShell.
+func quotePowerShell(v string) string {
+	return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+}
+
+// envKeyInvalid matches runs of characters not allowed in environment
+// variable names.
+var envKeyInvalid = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// envKey derives the variable name holding the version of the module at
+// path, such as MODULE_FOO_VERSION for example.com/foo.
+func envKey(modPath string, o outputOptions) (string, error) {
+	name := modPath
+	switch o.EnvKey {
+	case "full":
+	case "base":
+		// Major version suffixes are not significant enough to be the whole
+		// name; keep them along with the preceding element.
+		prefix, major, _ := module.SplitPathVersion(modPath)
+		name = path.Base(prefix) + major
+	default:
+		return "", fmt.Errorf("unknown env key %q; use base or full", o.EnvKey)
+	}
+	name = strings.Trim(envKeyInvalid.ReplaceAllString(strings.ToUpper(name), "_"), "_")
+	return o.EnvPrefix + name + "_VERSION", nil
+}
+
+// formatEnv renders KEY=VERSION lines for consumption by configuration
+// management tools.
+func formatEnv(rs []resolution, o outputOptions) ([]string, error) {
+	seen := map[string]string{}
+	lines := make([]string, len(rs))
+	for i, r := range rs {
+		key, err := envKey(r.Path, o)
+		if err != nil {
+			return nil, err
+		}
+		if prev, ok := seen[key]; ok {
+			return nil, fmt.Errorf("%s and %s both map to %s; use --env-key full", prev, r.Path, key)
+		}
+		seen[key] = r.Path
+		lines[i] = key + "=" + r.Version
+	}
+	return lines, nil
+}
+
+// outcome is the result of resolving a single request.
+type outcome struct {
+	req repoRequest
+	r   resolution
+	err error
+}
+
+// path returns the module path of the outcome, even when it failed.
+func (o outcome) path() string {
+	if o.r.Path != "" {
+		return o.r.Path
+	}
+	return o.req.ModulePath
+}
+
+func (o outcome) host() string {
+	host, _, _ := strings.Cut(o.path(), "/")
+	return host
+}
+
+// sortKeys maps --sort values to functions ordering two outcomes.
+var sortKeys = map[string]func(a, b outcome) int{
+	"path": func(a, b outcome) int { return strings.Compare(a.path(), b.path()) },
+	"host": func(a, b outcome) int { return strings.Compare(a.host(), b.host()) },
+	"version": func(a, b outcome) int {
+		if c := semver.Compare(a.r.Version, b.r.Version); c != 0 {
+			return c
+		}
+		return strings.Compare(a.path(), b.path())
+	},
+}
+
+// sortOutcomes orders outcomes by the sortKeys entry by, if any, keeping
+// outcomes of the same host together first when groupByHost is set.
+// Outcomes comparing equal keep their completion order.
+func sortOutcomes(outcomes []outcome, by string, groupByHost bool) {
+	cmp := sortKeys[by]
+	sort.SliceStable(outcomes, func(i, j int) bool {
+		a, b := outcomes[i], outcomes[j]
+		if groupByHost {
+			if c := strings.Compare(a.host(), b.host()); c != 0 {
+				return c < 0
+			}
+		}
+		return cmp != nil && cmp(a, b) < 0
+	})
+}
+
+// eols maps --eol values to line terminators.
+var eols = map[string]string{
+	"lf":   "\n",
+	"crlf": "\r\n",
+}
+
+// eolWriter rewrites the newlines written to it with a configured line
+// terminator.
+type eolWriter struct {
+	w   io.Writer
+	eol string
+}
+
+func (e eolWriter) Write(b []byte) (int, error) {
+	if e.eol == "\n" {
+		return e.w.Write(b)
+	}
+	if _, err := io.WriteString(e.w, strings.ReplaceAll(string(b), "\n", e.eol)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
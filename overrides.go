@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"golang.org/x/mod/semver"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const overridesFileName = ".grg-overrides"
+
+// overrides maps module paths to the versions they are pinned to by the
+// project's .grg-overrides file.
+type overrides map[string]string
+
+// findOverrides walks up from dir looking for a .grg-overrides file. It
+// returns an empty path when none is found.
+func findOverrides(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		p := filepath.Join(dir, overridesFileName)
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// loadOverrides parses a .grg-overrides file. Each non-empty line holds a
+// module path followed by the version it is pinned to; everything after a #
+// is a comment.
+func loadOverrides(path string) (overrides, error) {
+	o := overrides{}
+	if path == "" {
+		return o, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected a module path followed by a version", path, n)
+		}
+		if !semver.IsValid(fields[1]) {
+			return nil, fmt.Errorf("%s:%d: %s is not a valid version", path, n, fields[1])
+		}
+		o[fields[0]] = fields[1]
+	}
+	return o, scanner.Err()
+}
+
+// apply replaces the resolved version of r with its pinned version, if any,
+// warning when the resolution found a newer version than the pin.
+func (o overrides) apply(r resolution) resolution {
+	pin, ok := o[r.Path]
+	if !ok || pin == r.Version {
+		return r
+	}
+	pinned := resolution{Path: r.Path, Version: pin, Note: "pinned by " + overridesFileName}
+	if semver.Compare(r.Version, pin) > 0 {
+		pinned.warn(warnPinnedOutdated, "pinned to %s by %s, but %s is available", pin, overridesFileName, r.Version)
+	}
+	return pinned
+}
+
+// warnf prints a warning to stderr, keeping stdout limited to results.
+func warnf(format string, args ...any) {
+	_, _ = fmt.Fprintf(os.Stderr, tr("warning: ")+format+"\n", args...)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"golang.org/x/mod/module"
+	"path"
+	"strings"
+)
+
+// toolName returns the name of the binary go install builds for the module
+// at modPath, which is its last path element ignoring major version
+// suffixes.
+func toolName(modPath string) string {
+	prefix, _, _ := module.SplitPathVersion(modPath)
+	return path.Base(prefix)
+}
+
+// formulaClass converts a tool name to the class name Homebrew expects for
+// its formula, such as GoRequireGenerator for go-require-generator.
+func formulaClass(name string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// formatBrew renders a Homebrew formula per resolution, building the tool
+// from source at the resolved version.
+func formatBrew(rs []resolution, _ outputOptions) ([]string, error) {
+	var lines []string
+	for i, r := range rs {
+		ref := fmt.Sprintf("tag: %q", r.Version)
+		if module.IsPseudoVersion(r.Version) {
+			rev, err := module.PseudoVersionRev(r.Version)
+			if err != nil {
+				return nil, err
+			}
+			ref = fmt.Sprintf("revision: %q", rev)
+		}
+		if i > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines,
+			"# Generated by grg.",
+			fmt.Sprintf("class %s < Formula", formulaClass(toolName(r.Path))),
+			fmt.Sprintf("  homepage %q", "https://"+r.Path),
+			fmt.Sprintf("  url %q, %s", "https://"+r.Path+".git", ref),
+			fmt.Sprintf("  version %q", strings.TrimPrefix(r.Version, "v")),
+			"",
+			`  depends_on "go" => :build`,
+			"",
+			"  def install",
+			`    system "go", "build", *std_go_args(ldflags: "-s -w")`,
+			"  end",
+			"end",
+		)
+	}
+	return lines, nil
+}
+
+// scoopManifest is the subset of a Scoop app manifest grg generates.
+type scoopManifest struct {
+	Version   string         `json:"version"`
+	Homepage  string         `json:"homepage"`
+	Depends   string         `json:"depends"`
+	Installer scoopInstaller `json:"installer"`
+	Bin       string         `json:"bin"`
+}
+
+type scoopInstaller struct {
+	Script []string `json:"script"`
+}
+
+// formatScoop renders a Scoop manifest installing the tool with go install
+// at the resolved version. A manifest describes a single app, so exactly one
+// resolution is accepted.
+func formatScoop(rs []resolution, _ outputOptions) ([]string, error) {
+	if len(rs) != 1 {
+		return nil, fmt.Errorf("a Scoop manifest describes a single tool, but %d were resolved", len(rs))
+	}
+	r := rs[0]
+	target, err := quoteArg(r.Path + "@" + r.Version)
+	if err != nil {
+		return nil, err
+	}
+	m := scoopManifest{
+		Version:  strings.TrimPrefix(r.Version, "v"),
+		Homepage: "https://" + r.Path,
+		Depends:  "go",
+		Installer: scoopInstaller{Script: []string{
+			"$env:GOBIN = $dir",
+			"go install " + target,
+			"Remove-Item Env:GOBIN",
+		}},
+		Bin: toolName(r.Path) + ".exe",
+	}
+	data, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(string(data), "\n"), nil
+}
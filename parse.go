@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/heyvito/go-require-generator/pkg/input"
+	"github.com/urfave/cli/v2"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+var parseCommand = &cli.Command{
+	Name:      "parse",
+	Usage:     "Shows how grg parses a repository argument, without resolving it",
+	ArgsUsage: "input",
+	Description: "Module paths, clone URLs, scp-like URLs, tree URLs and local paths are accepted, followed by\n" +
+		"an optional @ref. ROOTS lists where the repository root may be within the path, which is only\n" +
+		"known for some hosts; the others are probed in order when resolving.",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "Prints the parsed argument as a JSON object",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() != 1 {
+			return cli.ShowSubcommandHelp(ctx)
+		}
+		in, err := input.Parse(ctx.Args().First())
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		if ctx.Bool("json") {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(in)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, f := range [][2]string{
+			{"kind", string(in.Kind)},
+			{"host", in.Host},
+			{"path", in.Path},
+			{"roots", strings.Join(in.Roots, ", ")},
+			{"subdir", in.Subdir},
+			{"clone url", in.CloneURL},
+			{"ref", in.Ref},
+			{"canonical", in.String()},
+		} {
+			if f[1] != "" {
+				_, _ = fmt.Fprintf(w, "%s\t%s\n", f[0], f[1])
+			}
+		}
+		return w.Flush()
+	},
+}
@@ -0,0 +1,432 @@
+// Package input parses the repository arguments grg accepts: module paths
+// such as github.com/org/repo/sub, clone URLs in URL or scp-like syntax,
+// web URLs of a directory of a repository's tree, and local paths, each
+// optionally followed by @ref.
+//
+// Parsing is purely syntactic. Where the repository root lies within a
+// module path can only be told for hosts serving repositories at a fixed
+// depth, and for paths marking it with a .git suffix; other paths list every
+// candidate root, to be probed.
+package input
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Kind tells which syntax an argument was written in.
+type Kind string
+
+const (
+	// KindModule arguments are module or package paths, such as
+	// github.com/org/repo/sub.
+	KindModule Kind = "module"
+	// KindURL arguments are URLs, such as https://github.com/org/repo.git or
+	// https://github.com/org/repo/tree/main/sub.
+	KindURL Kind = "url"
+	// KindSCP arguments use git's scp-like syntax, [user@]host:path.
+	KindSCP Kind = "scp"
+	// KindLocal arguments are repositories on the local filesystem, given as
+	// paths starting with /, ./ or ../, or as file:// URLs.
+	KindLocal Kind = "local"
+)
+
+// Input is a parsed repository argument.
+type Input struct {
+	// Raw is the argument as given.
+	Raw  string `json:"raw"`
+	Kind Kind   `json:"kind"`
+	// Host is the lowercased host of remote arguments, without any port.
+	Host string `json:"host,omitempty"`
+	// Path is the module path the argument refers to, starting with Host.
+	// Local arguments have none.
+	Path string `json:"path,omitempty"`
+	// Roots lists the paths on Host the repository root may be at, from the
+	// longest. There is a single one when it is known, and none for local
+	// arguments.
+	Roots []string `json:"roots,omitempty"`
+	// Subdir is the directory of the repository Path refers to, when the
+	// repository root is known.
+	Subdir string `json:"subdir,omitempty"`
+	// CloneURL is the URL the repository was given as, for URL, scp-like and
+	// local arguments. Local paths are turned into absolute file:// URLs.
+	CloneURL string `json:"cloneURL,omitempty"`
+	// Ref is the commit, tag, branch or version query following @, or
+	// named by the URL of a tree.
+	Ref string `json:"ref,omitempty"`
+}
+
+// String returns the argument in its canonical form: the module path, or the
+// clone URL of local arguments, followed by @ref when there is one.
+func (in Input) String() string {
+	s := in.Path
+	if in.Kind == KindLocal {
+		s = in.CloneURL
+	}
+	if in.Ref != "" {
+		s += "@" + in.Ref
+	}
+	return s
+}
+
+// fixedDepthHosts lists hosts known to serve repositories at a fixed number of
+// path segments (usually owner/repo). Paths on any other host are probed from
+// the longest candidate to the shortest, since hosts like GitLab allow nested
+// subgroups.
+var fixedDepthHosts = map[string]int{
+	"github.com":    2,
+	"bitbucket.org": 2,
+	"codeberg.org":  2,
+	"gitea.com":     2,
+}
+
+// FixedDepth returns the number of path segments repositories on host are
+// served at, and whether host is known to use a fixed number.
+func FixedDepth(host string) (int, bool) {
+	depth, ok := fixedDepthHosts[host]
+	return depth, ok
+}
+
+// shaPattern matches full or abbreviated commit SHAs accepted as versions.
+var shaPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// IsSHA reports whether ref looks like a full or abbreviated commit SHA, of at
+// least 7 lowercase hexadecimal digits.
+func IsSHA(ref string) bool {
+	return shaPattern.MatchString(ref)
+}
+
+// Parse parses a repository argument, normalizing it: hosts are lowercased,
+// repeated and surrounding slashes are dropped, as is the .git suffix of
+// clone URLs, and commit SHAs are lowercased.
+func Parse(s string) (Input, error) {
+	raw := s
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Input{}, fmt.Errorf("empty repository argument")
+	}
+	if err := checkChars(s, s); err != nil {
+		return Input{}, err
+	}
+	v, ref, err := SplitRef(s)
+	if err != nil {
+		return Input{}, err
+	}
+
+	var in Input
+	switch {
+	case IsLocal(v):
+		in, err = parseLocal(v)
+	case strings.Contains(v, "://"):
+		in, err = parseURL(v)
+	case isSCP(v):
+		in, err = parseSCP(v)
+	default:
+		in, err = parseModulePath(v)
+	}
+	if err != nil {
+		return Input{}, err
+	}
+	if ref != "" {
+		if in.Ref != "" && in.Ref != ref {
+			return Input{}, fmt.Errorf("%s names both %s and %s", s, in.Ref, ref)
+		}
+		in.Ref = ref
+	}
+	in.Raw = raw
+	return in, nil
+}
+
+// SplitRef separates a trailing @ref from an argument, accepting what go get
+// does after the @: commits, tags, branches and version queries. SHAs are
+// lowercased; other refs are kept as given, since tag and branch names are
+// case sensitive. The user@ part of URLs and scp-like addresses is not
+// taken for one.
+func SplitRef(v string) (string, string, error) {
+	start := 0
+	if scheme := strings.Index(v, "://"); scheme >= 0 {
+		start = scheme + len("://")
+		if slash := strings.Index(v[start:], "/"); slash >= 0 {
+			start += slash
+		} else {
+			start = len(v)
+		}
+	} else if isSCP(v) {
+		start = strings.Index(v, ":")
+	}
+	i := strings.LastIndex(v[start:], "@")
+	if i < 0 || strings.Contains(v[start+i:], "/") {
+		return v, "", nil
+	}
+	i += start
+	ref, err := checkRef(v, v[i+1:])
+	return v[:i], ref, err
+}
+
+// checkRef validates the ref given in the argument v, returning it
+// normalized.
+func checkRef(v, ref string) (string, error) {
+	if ref == "" || strings.ContainsAny(ref, " ~^:?*[\\") {
+		return "", fmt.Errorf("%s: %q is not a valid commit, tag or branch name", v, ref)
+	}
+	switch {
+	case ref == "latest":
+		// Resolving the latest version is what grg does without a ref.
+		return "", nil
+	case ref == "upgrade" || ref == "patch":
+		return "", fmt.Errorf("%s: @%s is relative to a current version, which grg does not have; use @latest or a version query", v, ref)
+	case shaPattern.MatchString(strings.ToLower(ref)):
+		return strings.ToLower(ref), nil
+	}
+	return ref, nil
+}
+
+// IsLocal reports whether v refers to a repository on the local filesystem
+// rather than a remote host.
+func IsLocal(v string) bool {
+	return strings.HasPrefix(v, "file://") ||
+		filepath.IsAbs(v) ||
+		strings.HasPrefix(v, "./") ||
+		strings.HasPrefix(v, "../")
+}
+
+// LocalURL converts a local path or file:// URL into an absolute file:// URL.
+// git only honours --depth for local repositories when they are given as
+// URLs.
+func LocalURL(v string) (string, error) {
+	if strings.HasPrefix(v, "file://") {
+		u, err := url.Parse(v)
+		if err != nil {
+			return "", fmt.Errorf("invalid repository URL %s: %w", v, err)
+		}
+		if u.Host != "" && u.Host != "localhost" {
+			return "", fmt.Errorf("file URLs pointing to remote hosts are not supported")
+		}
+		return v, nil
+	}
+
+	abs, err := filepath.Abs(v)
+	if err != nil {
+		return "", err
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String(), nil
+}
+
+// RepoRoots splits a module-like path into its host and the paths on it the
+// repository root may be at, in order of preference.
+func RepoRoots(name string) (string, []string, error) {
+	hostPath := strings.SplitN(strings.Trim(name, "/"), "/", 2)
+	if len(hostPath) != 2 || hostPath[0] == "" || hostPath[1] == "" {
+		return "", nil, fmt.Errorf("%s does not look like a repository path", name)
+	}
+	host, path := hostPath[0], hostPath[1]
+	segments := strings.Split(path, "/")
+
+	// A segment ending in .git explicitly marks the repository root, the same
+	// way the go command treats it.
+	for i, s := range segments {
+		if strings.HasSuffix(s, ".git") {
+			return host, []string{strings.Join(segments[:i+1], "/")}, nil
+		}
+	}
+
+	if depth, ok := fixedDepthHosts[host]; ok {
+		if len(segments) < depth {
+			return "", nil, fmt.Errorf("%s repositories require at least %d path segments", host, depth)
+		}
+		return host, []string{strings.Join(segments[:depth], "/")}, nil
+	}
+
+	candidates := make([]string, 0, len(segments))
+	for i := len(segments); i > 0; i-- {
+		candidates = append(candidates, strings.Join(segments[:i], "/"))
+	}
+	return host, candidates, nil
+}
+
+func parseLocal(v string) (Input, error) {
+	u, err := LocalURL(v)
+	if err != nil {
+		return Input{}, err
+	}
+	// A directory named with @ would be taken for a ref once canonical.
+	if i := strings.LastIndex(u, "@"); i >= 0 && !strings.Contains(u[i:], "/") {
+		return Input{}, fmt.Errorf("%s: @ can only precede a commit, tag or branch name", v)
+	}
+	return Input{Kind: KindLocal, CloneURL: u}, nil
+}
+
+// parseModulePath parses a module or package path.
+func parseModulePath(v string) (Input, error) {
+	segments, err := cleanSegments(v, v)
+	if err != nil {
+		return Input{}, err
+	}
+	segments[0] = strings.ToLower(segments[0])
+	if err = checkHost(v, segments[0]); err != nil {
+		return Input{}, err
+	}
+	return withRoots(Input{Kind: KindModule, Path: strings.Join(segments, "/")})
+}
+
+// isSCP reports whether v uses git's scp-like syntax, which has a colon before
+// any slash.
+func isSCP(v string) bool {
+	colon := strings.Index(v, ":")
+	return colon > 0 && !strings.Contains(v[:colon], "/")
+}
+
+// parseSCP parses a [user@]host:path argument.
+func parseSCP(v string) (Input, error) {
+	host, p, _ := strings.Cut(v, ":")
+	if _, h, ok := strings.Cut(host, "@"); ok {
+		host = h
+	}
+	if host == "" {
+		return Input{}, fmt.Errorf("%s does not look like a repository URL", v)
+	}
+	segments, err := cleanSegments(v, p)
+	if err != nil {
+		return Input{}, err
+	}
+	return remoteRepo(Input{Kind: KindSCP, CloneURL: v}, host, segments, nil)
+}
+
+// parseURL parses a clone URL or the web URL of a directory of a repository,
+// such as https://github.com/org/repo/tree/main/sub, or its GitLab form with
+// /-/tree/.
+func parseURL(v string) (Input, error) {
+	u, err := url.Parse(v)
+	if err != nil {
+		return Input{}, fmt.Errorf("invalid repository URL %s: %w", v, err)
+	}
+	switch u.Scheme {
+	case "https", "http", "ssh", "git", "git+ssh":
+	default:
+		return Input{}, fmt.Errorf("%s: unsupported URL scheme %q", v, u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return Input{}, fmt.Errorf("%s does not look like a repository URL", v)
+	}
+	segments, err := cleanSegments(v, u.Path)
+	if err != nil {
+		return Input{}, err
+	}
+
+	in := Input{Kind: KindURL, CloneURL: v}
+	var subdir []string
+	for i := 1; i < len(segments)-1; i++ {
+		if segments[i] != "tree" {
+			continue
+		}
+		repo := segments[:i]
+		if repo[len(repo)-1] == "-" {
+			repo = repo[:len(repo)-1]
+		}
+		if len(repo) == 0 {
+			break
+		}
+		if in.Ref, err = checkRef(v, segments[i+1]); err != nil {
+			return Input{}, err
+		}
+		subdir = segments[i+2:]
+		segments = repo
+		web := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/" + strings.Join(repo, "/")}
+		in.CloneURL = web.String()
+		break
+	}
+	return remoteRepo(in, u.Hostname(), segments, subdir)
+}
+
+// remoteRepo completes in for the repository at the path segments on host,
+// and the directory subdir of it.
+func remoteRepo(in Input, host string, segments, subdir []string) (Input, error) {
+	in.Host = strings.ToLower(host)
+	if err := checkHost(in.CloneURL, in.Host); err != nil {
+		return Input{}, err
+	}
+	last := len(segments) - 1
+	segments[last] = strings.TrimSuffix(segments[last], ".git")
+	if segments[last] == "" || segments[last] == "." || segments[last] == ".." {
+		return Input{}, fmt.Errorf("%s does not look like a repository URL", in.CloneURL)
+	}
+	if depth, ok := fixedDepthHosts[in.Host]; ok && len(segments) != depth {
+		return Input{}, fmt.Errorf("%s repositories are at %d path segments", in.Host, depth)
+	}
+	root := strings.Join(segments, "/")
+	in.Roots = []string{root}
+	in.Subdir = strings.Join(subdir, "/")
+	in.Path = in.Host + "/" + root
+	if in.Subdir != "" {
+		in.Path += "/" + in.Subdir
+	}
+	return in, nil
+}
+
+// withRoots sets the candidate repository roots of in, and the subdirectory
+// when the root is known.
+func withRoots(in Input) (Input, error) {
+	host, roots, err := RepoRoots(in.Path)
+	if err != nil {
+		return Input{}, err
+	}
+	in.Host, in.Roots = host, roots
+	if len(roots) == 1 {
+		in.Subdir = strings.TrimPrefix(strings.TrimPrefix(in.Path, host+"/"+roots[0]), "/")
+	}
+	return in, nil
+}
+
+// cleanSegments splits p into its non-empty segments, rejecting relative
+// ones and @, which do not belong in module paths. arg is the argument p is
+// part of, for errors.
+func cleanSegments(arg, p string) ([]string, error) {
+	if err := checkChars(arg, p); err != nil {
+		return nil, err
+	}
+	var segments []string
+	for _, s := range strings.Split(p, "/") {
+		switch {
+		case s == "":
+			continue
+		case s == "." || s == "..":
+			return nil, fmt.Errorf("%s: paths cannot contain %s segments", arg, s)
+		case strings.Contains(s, "@"):
+			return nil, fmt.Errorf("%s: @ can only precede a commit, tag or branch name", arg)
+		}
+		segments = append(segments, s)
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("%s does not look like a repository path", arg)
+	}
+	return segments, nil
+}
+
+// checkChars rejects whitespace, control characters and backslashes in s,
+// part of the argument arg, which are never valid in repository arguments.
+func checkChars(arg, s string) error {
+	if i := strings.IndexFunc(s, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) || r == '\\' }); i >= 0 {
+		r, _ := utf8.DecodeRuneInString(s[i:])
+		return fmt.Errorf("%s: invalid character %q", arg, r)
+	}
+	return nil
+}
+
+// checkHost rejects hosts other than lowercase DNS names and IPv4 addresses,
+// which are the only ones module paths can start with. arg is the argument
+// host is part of, for errors.
+func checkHost(arg, host string) error {
+	valid := host != "" && strings.Trim(host, ".-") == host
+	for _, r := range host {
+		valid = valid && (r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_')
+	}
+	if !valid {
+		return fmt.Errorf("%s: %q is not a valid host", arg, host)
+	}
+	return nil
+}
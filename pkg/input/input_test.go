@@ -0,0 +1,135 @@
+package input
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		arg  string
+		want Input
+	}{
+		{"github.com/org/repo/sub", Input{Kind: KindModule, Host: "github.com", Path: "github.com/org/repo/sub", Roots: []string{"org/repo"}, Subdir: "sub"}},
+		{"github.com/org/repo/sub@v1.2.3", Input{Kind: KindModule, Host: "github.com", Path: "github.com/org/repo/sub", Roots: []string{"org/repo"}, Subdir: "sub", Ref: "v1.2.3"}},
+		{"gitlab.com/group/repo", Input{Kind: KindModule, Host: "gitlab.com", Path: "gitlab.com/group/repo", Roots: []string{"group/repo", "group"}}},
+		{"gitlab.com/group/repo@main", Input{Kind: KindModule, Host: "gitlab.com", Path: "gitlab.com/group/repo", Roots: []string{"group/repo", "group"}, Ref: "main"}},
+		{"https://github.com/org/repo.git", Input{Kind: KindURL, Host: "github.com", Path: "github.com/org/repo", Roots: []string{"org/repo"}, CloneURL: "https://github.com/org/repo.git"}},
+		{"https://github.com/org/repo.git@ABCDEF1", Input{Kind: KindURL, Host: "github.com", Path: "github.com/org/repo", Roots: []string{"org/repo"}, CloneURL: "https://github.com/org/repo.git", Ref: "abcdef1"}},
+		{"ssh://git@example.com:2222/org/repo.git", Input{Kind: KindURL, Host: "example.com", Path: "example.com/org/repo", Roots: []string{"org/repo"}, CloneURL: "ssh://git@example.com:2222/org/repo.git"}},
+		{"ssh://git@example.com:2222/org/repo.git@v2.0.0", Input{Kind: KindURL, Host: "example.com", Path: "example.com/org/repo", Roots: []string{"org/repo"}, CloneURL: "ssh://git@example.com:2222/org/repo.git", Ref: "v2.0.0"}},
+		{"git@example.com:repo.git", Input{Kind: KindSCP, Host: "example.com", Path: "example.com/repo", Roots: []string{"repo"}, CloneURL: "git@example.com:repo.git"}},
+		{"git@example.com:repo.git@v1.0.0", Input{Kind: KindSCP, Host: "example.com", Path: "example.com/repo", Roots: []string{"repo"}, CloneURL: "git@example.com:repo.git", Ref: "v1.0.0"}},
+		{"example.com:org/repo", Input{Kind: KindSCP, Host: "example.com", Path: "example.com/org/repo", Roots: []string{"org/repo"}, CloneURL: "example.com:org/repo"}},
+		{"example.com:org/repo@main", Input{Kind: KindSCP, Host: "example.com", Path: "example.com/org/repo", Roots: []string{"org/repo"}, CloneURL: "example.com:org/repo", Ref: "main"}},
+		{"https://github.com/org/repo/tree/main/sub", Input{Kind: KindURL, Host: "github.com", Path: "github.com/org/repo/sub", Roots: []string{"org/repo"}, Subdir: "sub", CloneURL: "https://github.com/org/repo", Ref: "main"}},
+		{"https://github.com/org/repo/tree/main/sub@main", Input{Kind: KindURL, Host: "github.com", Path: "github.com/org/repo/sub", Roots: []string{"org/repo"}, Subdir: "sub", CloneURL: "https://github.com/org/repo", Ref: "main"}},
+		{"https://gitlab.com/group/repo/-/tree/v1.0.0/pkg", Input{Kind: KindURL, Host: "gitlab.com", Path: "gitlab.com/group/repo/pkg", Roots: []string{"group/repo"}, Subdir: "pkg", CloneURL: "https://gitlab.com/group/repo", Ref: "v1.0.0"}},
+		{"/abs/repo", Input{Kind: KindLocal, CloneURL: "file:///abs/repo"}},
+		{"/abs/repo@abc1234", Input{Kind: KindLocal, CloneURL: "file:///abs/repo", Ref: "abc1234"}},
+		{"file:///abs/repo.git", Input{Kind: KindLocal, CloneURL: "file:///abs/repo.git"}},
+		{"file:///abs/repo.git@v1.0.0", Input{Kind: KindLocal, CloneURL: "file:///abs/repo.git", Ref: "v1.0.0"}},
+	} {
+		got, err := Parse(tt.arg)
+		if err != nil {
+			t.Errorf("Parse(%q): %s", tt.arg, err)
+			continue
+		}
+		tt.want.Raw = tt.arg
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.arg, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, arg := range []string{
+		"",
+		"github.com/org",
+		"github.com/org/repo@",
+		"github.com/org/repo@upgrade",
+		"github.com/../repo",
+		"https://github.com/org/repo/extra.git",
+		"ftp://example.com/repo",
+		"https://github.com/org/repo/tree/main/sub@other",
+	} {
+		if in, err := Parse(arg); err == nil {
+			t.Errorf("Parse(%q) = %+v, want an error", arg, in)
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, s := range []string{
+		"github.com/org/repo",
+		"github.com/org/repo/sub@v1.2.3",
+		"GitHub.com//org/repo/",
+		"gitlab.com/group/sub/repo@main",
+		"example.com/repo.git/sub",
+		"go.uber.org/zap@latest",
+		"https://github.com/org/repo.git",
+		"https://github.com/org/repo/tree/main/sub",
+		"https://gitlab.com/group/repo/-/tree/v1.0.0/pkg",
+		"ssh://git@example.com:2222/org/repo.git@ABCDEF1234",
+		"git@github.com:org/repo.git",
+		"git@github.com:org/repo@v2",
+		"git@example.com:repo.git@v1.0.0",
+		"./repo",
+		"/abs/repo@abc1234",
+		"file:///abs/repo",
+		"github.com/org/repo@upgrade",
+		"github.com/../repo",
+		"https://host",
+		"",
+	} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		in, err := Parse(s)
+		if err != nil {
+			return
+		}
+		if in.Raw != s {
+			t.Fatalf("Parse(%q).Raw = %q", s, in.Raw)
+		}
+		if in.Ref != "" {
+			if _, err := checkRef(s, in.Ref); err != nil {
+				t.Fatalf("Parse(%q) accepted ref %q: %s", s, in.Ref, err)
+			}
+		}
+
+		if in.Kind == KindLocal {
+			if !strings.HasPrefix(in.CloneURL, "file://") || in.Path != "" || len(in.Roots) != 0 {
+				t.Fatalf("Parse(%q) = %+v, want a file:// URL and no module path", s, in)
+			}
+		} else {
+			if in.Host == "" || in.Host != strings.ToLower(in.Host) || !strings.HasPrefix(in.Path, in.Host+"/") {
+				t.Fatalf("Parse(%q) = %+v, want a lowercase host prefixing the path", s, in)
+			}
+			if strings.Contains(in.Path, "//") || strings.HasSuffix(in.Path, "/") || strings.Contains(in.Path, "@") {
+				t.Fatalf("Parse(%q).Path = %q is not normalized", s, in.Path)
+			}
+			if len(in.Roots) == 0 {
+				t.Fatalf("Parse(%q) has no candidate roots", s)
+			}
+			for _, r := range in.Roots {
+				if root := in.Host + "/" + r; in.Path != root && !strings.HasPrefix(in.Path, root+"/") {
+					t.Fatalf("Parse(%q): root %q does not prefix %q", s, r, in.Path)
+				}
+			}
+			if len(in.Roots) == 1 && strings.Trim(in.Host+"/"+in.Roots[0]+"/"+in.Subdir, "/") != in.Path {
+				t.Fatalf("Parse(%q): root %q and subdir %q do not make up %q", s, in.Roots[0], in.Subdir, in.Path)
+			}
+		}
+
+		// The canonical form parses back to the same module or repository.
+		again, err := Parse(in.String())
+		if err != nil {
+			t.Fatalf("Parse(%q) = %q, which fails to parse: %s", s, in.String(), err)
+		}
+		if again.Path != in.Path || again.Ref != in.Ref || (in.Kind == KindLocal && again.CloneURL != in.CloneURL) {
+			t.Fatalf("Parse(%q) = %q, which parses as %+v", s, in.String(), again)
+		}
+	})
+}
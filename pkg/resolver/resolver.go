@@ -0,0 +1,381 @@
+// Package resolver resolves the version of a Go module hosted in a git
+// repository the way grg does, so the result can be added to a go.mod
+// without running the grg binary.
+//
+// The package holds the core of grg, which the grg command builds on: the
+// selection of the latest version among a repository's tags, as go get
+// makes it, and the pseudo-versions of untagged commits. Resolver applies
+// them to a shallow clone of a repository. Features configured through
+// grg's flags and configuration file, such as policies, channels and
+// caches, are not available.
+//
+// Failures with a known cause wrap one of the package's sentinel errors,
+// such as ErrRepoNotFound or ErrAuth, to be checked with errors.Is.
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/heyvito/go-require-generator/pkg/input"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Requirement is a module version resolved from a repository.
+type Requirement struct {
+	// Path is the module path.
+	Path string
+	// Version is the resolved version, either a tag or a pseudo-version.
+	Version string
+	// Note is an optional remark, emitted as a comment on the require line.
+	Note string
+}
+
+// String formats r as a go.mod require directive.
+func (r Requirement) String() string {
+	line := fmt.Sprintf("require %s %s", r.Path, r.Version)
+	if r.Note != "" {
+		line += " // " + r.Note
+	}
+	return line
+}
+
+// Errors returned by Resolve wrap one of these when the cause of a failure
+// is known, so it can be told apart with errors.Is.
+var (
+	// ErrRepoNotFound is returned when the repository does not exist.
+	ErrRepoNotFound = errors.New("repository not found")
+	// ErrAuth is returned when the repository requires credentials that
+	// git could not provide, or rejected them. Hosts such as GitHub ask for
+	// credentials for repositories that do not exist as well, which are
+	// then reported with ErrAuth.
+	ErrAuth = errors.New("authentication required")
+	// ErrNoVersions is returned when no version can be resolved from the
+	// repository.
+	ErrNoVersions = errors.New("no version available")
+	// ErrNotAGoModule is returned when the repository holds neither a go.mod
+	// nor any Go source file.
+	ErrNotAGoModule = errors.New("not a Go module")
+	// ErrNoCommits is returned when the repository has no commits to
+	// resolve. It wraps ErrNoVersions.
+	ErrNoCommits = fmt.Errorf("repository has no commits: %w", ErrNoVersions)
+)
+
+// Resolver resolves module versions by running git. The zero value is ready
+// to use, running git from PATH.
+type Resolver struct {
+	// Git is the path of the git executable. Empty looks git up in PATH.
+	Git string
+	// Env holds KEY=VALUE entries added to git's environment, on top of the
+	// current process environment.
+	Env []string
+	// Log receives every git command run, when not nil.
+	Log io.Writer
+}
+
+// Resolve resolves the version of the module in the repository at repoURL,
+// the way grg does: the latest version among the module's tags the go
+// command can use, skipping those its latest version retracts, or a
+// pseudo-version of the default branch's HEAD when there is none. repoURL
+// is either a clone URL, such as https://github.com/org/repo.git or
+// git@github.com:org/repo.git, or a module path such as
+// gitlab.com/group/repo/sub, whose repository is looked up over HTTPS from
+// the longest candidate root. Modules in a subdirectory of their repository
+// are resolved from the tags carrying the directory as a prefix.
+func (r *Resolver) Resolve(ctx context.Context, repoURL string) (Requirement, error) {
+	in, err := input.Parse(repoURL)
+	if err != nil {
+		return Requirement{}, err
+	}
+	if in.Ref != "" {
+		return Requirement{}, fmt.Errorf("%s: resolving a given ref is not supported", repoURL)
+	}
+	repo, err := r.locate(ctx, in)
+	if err != nil {
+		return Requirement{}, err
+	}
+
+	dir, err := os.MkdirTemp("", "grg-")
+	if err != nil {
+		return Requirement{}, err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	repo.dir = filepath.Join(dir, "repo")
+	if _, err = r.run(ctx, dir, "clone", "--depth=1", "--bare", repo.url, "repo"); err != nil {
+		if cause := CloneFailure(err.Error()); cause != nil {
+			return Requirement{}, fmt.Errorf("failed cloning %s: %w: %w", repo.url, cause, err)
+		}
+		return Requirement{}, fmt.Errorf("failed cloning %s: %w", repo.url, err)
+	}
+	if err = r.checkGoModule(ctx, repo.dir); err != nil {
+		return Requirement{}, fmt.Errorf("%s: %w", repo.path, err)
+	}
+
+	goMod := func(t Tag) ([]byte, error) { return r.goMod(ctx, repo, t.Commit) }
+	tag, version, ok, err := LatestModuleVersion(repo.path, repo.tags, PreFallback, goMod)
+	if err != nil {
+		return Requirement{}, err
+	}
+	if ok {
+		retractions, err := r.retractions(ctx, repo, tag)
+		if err != nil {
+			return Requirement{}, err
+		}
+		// Like go get, the latest version is kept when every version is
+		// retracted.
+		tags, selected := repo.tags, version
+		for Retraction(retractions, selected) != nil {
+			tags = withoutTag(tags, tag.Name)
+			var found bool
+			if tag, selected, found, err = LatestModuleVersion(repo.path, tags, PreFallback, goMod); err != nil {
+				return Requirement{}, err
+			}
+			if !found {
+				selected = version
+				break
+			}
+		}
+		return Requirement{Path: modulePath(repo.path, selected), Version: selected}, nil
+	}
+
+	version, err = r.pseudoVersion(ctx, repo)
+	if err != nil {
+		return Requirement{}, err
+	}
+	return Requirement{Path: repo.path, Version: version}, nil
+}
+
+// repository is a repository located by Resolver.locate.
+type repository struct {
+	// url is the URL the repository is cloned from.
+	url string
+	// path is the module path requested.
+	path string
+	// subdir is the directory of the repository holding the module, empty
+	// for the repository root.
+	subdir string
+	// prefix is the prefix of the module's tags, as TagPrefix returns.
+	prefix string
+	// tags are the module's tags, named after the version they stand for.
+	tags []Tag
+	// dir is the bare clone of the repository, once cloned.
+	dir string
+}
+
+// locate finds the repository of in and lists the tags of the module. Module
+// paths whose repository root is unknown are probed from the longest
+// candidate root, skipping those found missing or asking for credentials,
+// since hosts such as GitHub do so for missing repositories.
+func (r *Resolver) locate(ctx context.Context, in input.Input) (*repository, error) {
+	var candidates []*repository
+	switch in.Kind {
+	case input.KindLocal:
+		// Local repositories are named after their location.
+		path := strings.Trim(strings.TrimPrefix(in.CloneURL, "file://"), "/")
+		path = strings.TrimSuffix(path, ".git")
+		if host, p, ok := strings.Cut(path, "/"); !ok || host == "" || p == "" {
+			return nil, fmt.Errorf("%s does not look like a repository URL", in.Raw)
+		}
+		candidates = append(candidates, &repository{url: in.CloneURL, path: path})
+	case input.KindModule:
+		for _, root := range in.Roots {
+			subdir := strings.Trim(strings.TrimPrefix(in.Path, in.Host+"/"+root), "/")
+			url := "https://" + in.Host + "/" + strings.TrimSuffix(root, ".git") + ".git"
+			candidates = append(candidates, &repository{url: url, path: in.Path, subdir: subdir})
+		}
+	default:
+		candidates = append(candidates, &repository{url: in.CloneURL, path: in.Path, subdir: in.Subdir})
+	}
+
+	var err error
+	for _, repo := range candidates {
+		var out string
+		if out, err = r.run(ctx, "", "ls-remote", "--tags", repo.url); err != nil {
+			if cause := CloneFailure(err.Error()); cause != nil {
+				err = fmt.Errorf("failed listing the tags of %s: %w: %w", repo.url, cause, err)
+				continue
+			}
+			return nil, fmt.Errorf("failed listing the tags of %s: %w", repo.url, err)
+		}
+		tags := ParseTags(out)
+		repo.prefix = TagPrefix(tags, repo.subdir)
+		for _, t := range tags {
+			if name, ok := strings.CutPrefix(t.Name, repo.prefix); ok {
+				t.Name = name
+				repo.tags = append(repo.tags, t)
+			}
+		}
+		return repo, nil
+	}
+	return nil, err
+}
+
+// goMod returns the nearest go.mod from the module's directory up to the
+// repository root at commit, fetching the commit into the clone when
+// needed, or nil when there is none.
+func (r *Resolver) goMod(ctx context.Context, repo *repository, commit string) ([]byte, error) {
+	if _, err := r.run(ctx, repo.dir, "cat-file", "-e", commit+"^{commit}"); err != nil {
+		if _, err = r.run(ctx, repo.dir, "fetch", "--depth=1", "origin", commit); err != nil {
+			return nil, fmt.Errorf("failed fetching commit %s: %w", commit, err)
+		}
+	}
+	for dir := repo.subdir; ; dir = path.Dir(dir) {
+		if dir == "." {
+			dir = ""
+		}
+		object := commit + ":" + path.Join(dir, "go.mod")
+		if _, err := r.run(ctx, repo.dir, "cat-file", "-e", object); err == nil {
+			data, err := r.run(ctx, repo.dir, "cat-file", "blob", object)
+			if err != nil {
+				return nil, err
+			}
+			return []byte(data), nil
+		}
+		if dir == "" {
+			return nil, nil
+		}
+	}
+}
+
+// retractions returns the retract directives of the go.mod at latest, the
+// latest version of the module, which the go command reads them from. A
+// go.mod that cannot be parsed retracts nothing.
+func (r *Resolver) retractions(ctx context.Context, repo *repository, latest Tag) ([]*modfile.Retract, error) {
+	data, err := r.goMod(ctx, repo, latest.Commit)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	f, err := modfile.ParseLax("go.mod", data, nil)
+	if err != nil {
+		r.logf("Ignoring retractions: failed parsing go.mod at %s: %s\n", latest.Name, err)
+		return nil, nil
+	}
+	return f.Retract, nil
+}
+
+// pseudoVersion builds the pseudo-version of the default branch's HEAD.
+// Finding its base requires the complete history, which is only fetched
+// when the module has tags it can be based on.
+func (r *Resolver) pseudoVersion(ctx context.Context, repo *repository) (string, error) {
+	out, err := r.run(ctx, repo.dir, "log", "-1", "--format=%H %ct", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrNoCommits, err)
+	}
+	sha, ts, _ := strings.Cut(out, " ")
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("unexpected git log output %q", out)
+	}
+
+	goMod, err := r.goMod(ctx, repo, sha)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, len(repo.tags))
+	for i, t := range repo.tags {
+		names[i] = t.Name
+	}
+	var base string
+	if PseudoVersionBase(repo.path, names, goMod == nil) != "" {
+		if _, err = r.run(ctx, repo.dir, "fetch", "--unshallow", "origin", "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"); err != nil {
+			return "", fmt.Errorf("failed fetching repository history: %w", err)
+		}
+		out, err = r.run(ctx, repo.dir, "tag", "--list", "--merged", sha, repo.prefix+"v*")
+		if err != nil {
+			return "", fmt.Errorf("failed listing tags merged into %s: %w", sha, err)
+		}
+		var merged []string
+		for _, name := range strings.Split(out, "\n") {
+			merged = append(merged, strings.TrimPrefix(strings.TrimSpace(name), repo.prefix))
+		}
+		base = PseudoVersionBase(repo.path, merged, goMod == nil)
+	}
+	return PseudoVersion(repo.path, base, time.Unix(unix, 0), sha), nil
+}
+
+// modulePath returns the path of the module at path for version, which has
+// the major version suffix the module's go.mod declares from v2 on, as
+// LatestModuleVersion describes. Paths already carrying a major version
+// suffix, gopkg.in ones included, and paths that cannot carry one are
+// returned as is.
+func modulePath(path, version string) string {
+	major := semver.Major(version)
+	if _, pathMajor, ok := module.SplitPathVersion(path); !ok || pathMajor != "" || major == "v0" || major == "v1" || strings.HasSuffix(version, "+incompatible") {
+		return path
+	}
+	return path + "/" + major
+}
+
+// logf writes a message to r.Log, when set.
+func (r *Resolver) logf(format string, args ...interface{}) {
+	if r.Log != nil {
+		_, _ = fmt.Fprintf(r.Log, format, args...)
+	}
+}
+
+// run executes git with args in dir, returning its trimmed standard output.
+func (r *Resolver) run(ctx context.Context, dir string, args ...string) (string, error) {
+	git := r.Git
+	if git == "" {
+		git = "git"
+	}
+	r.logf("exec: git %s\n", strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, git, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "LC_ALL=C")
+	cmd.Env = append(cmd.Env, r.Env...)
+	var stdout, stderr strings.Builder
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// CloneFailure returns the sentinel describing why git failed to reach a
+// repository, as told by msg, what git wrote to its standard error, or nil
+// when the cause is not recognised. git must run in the C locale for its
+// messages to be recognised.
+func CloneFailure(msg string) error {
+	msg = strings.ToLower(msg)
+	for _, s := range []string{"authentication failed", "could not read username", "could not read password", "terminal prompts disabled", "permission denied", "access denied", "error: 403"} {
+		if strings.Contains(msg, s) {
+			return ErrAuth
+		}
+	}
+	for _, s := range []string{"not found", "does not appear to be a git repository", "does not exist", "error: 404"} {
+		if strings.Contains(msg, s) {
+			return ErrRepoNotFound
+		}
+	}
+	return nil
+}
+
+// checkGoModule fails with ErrNotAGoModule when HEAD of the repository
+// cloned at repo has neither a go.mod nor a Go source file. Empty
+// repositories are left to fail with ErrNoCommits.
+func (r *Resolver) checkGoModule(ctx context.Context, repo string) error {
+	out, err := r.run(ctx, repo, "ls-tree", "-r", "--name-only", "HEAD")
+	if err != nil {
+		return nil
+	}
+	for _, name := range strings.Split(out, "\n") {
+		if filepath.Base(name) == "go.mod" || strings.HasSuffix(name, ".go") {
+			return nil
+		}
+	}
+	return ErrNotAGoModule
+}
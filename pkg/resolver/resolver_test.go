@@ -0,0 +1,218 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// testRepo is a git repository created for a test.
+type testRepo struct {
+	t   *testing.T
+	dir string
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	r := &testRepo{t: t, dir: filepath.Join(t.TempDir(), "repo")}
+	r.git("init", "--quiet", "--initial-branch=main", r.dir)
+	return r
+}
+
+// path returns the module path Resolve derives from the repository.
+func (r *testRepo) path() string {
+	return strings.Trim(filepath.ToSlash(r.dir), "/")
+}
+
+func (r *testRepo) git(args ...string) {
+	r.t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "tag.gpgSign=false", "-c", "commit.gpgSign=false"}, args...)...)
+	cmd.Dir = r.dir
+	if _, err := os.Stat(r.dir); err != nil {
+		cmd.Dir = ""
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		r.t.Fatalf("git %s: %s: %s", strings.Join(args, " "), err, out)
+	}
+}
+
+// commit commits files, mapping names to contents, with empty contents
+// deleting a file.
+func (r *testRepo) commit(files map[string]string) {
+	r.t.Helper()
+	for name, data := range files {
+		file := filepath.Join(r.dir, name)
+		if data == "" {
+			r.git("rm", "--quiet", name)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+			r.t.Fatal(err)
+		}
+		r.git("add", name)
+	}
+	r.git("commit", "--quiet", "--allow-empty", "-m", "commit")
+}
+
+func (r *testRepo) resolve() (Requirement, error) {
+	return (&Resolver{}).Resolve(context.Background(), r.dir)
+}
+
+func TestResolveLatestTag(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit(map[string]string{"go.mod": "module " + r.path() + "\n", "a.go": "package a\n"})
+	r.git("tag", "v1.0.0")
+	r.commit(map[string]string{"b.go": "package a\n"})
+	r.git("tag", "-a", "-m", "release", "v1.1.0")
+	r.git("tag", "v1.2.0-rc.1")
+	// Tags off the default branch's history are candidates as well.
+	r.git("checkout", "--quiet", "-b", "release", "v1.0.0")
+	r.commit(nil)
+	r.git("tag", "v1.0.1")
+	r.git("checkout", "--quiet", "main")
+
+	got, err := r.resolve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Requirement{Path: r.path(), Version: "v1.1.0"}); got != want {
+		t.Fatalf("Resolve() = %+v, want %+v", got, want)
+	}
+}
+
+func TestResolveRetracted(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit(map[string]string{"go.mod": "module " + r.path() + "\n", "a.go": "package a\n"})
+	r.git("tag", "v1.0.0")
+	r.commit(nil)
+	r.git("tag", "v1.1.0")
+	// Retractions are read from the latest version, here retracting
+	// itself along with v1.1.0.
+	r.commit(map[string]string{"go.mod": "module " + r.path() + "\n\nretract [v1.1.0, v1.2.0] // Broken.\n"})
+	r.git("tag", "v1.2.0")
+
+	got, err := r.resolve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != "v1.0.0" {
+		t.Fatalf("Resolve() = %+v, want v1.0.0", got)
+	}
+}
+
+func TestResolveMajorVersions(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit(map[string]string{"a.go": "package a\n"})
+	r.git("tag", "v1.0.0")
+	r.git("tag", "v2.0.0")
+
+	got, err := r.resolve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Requirement{Path: r.path(), Version: "v2.0.0+incompatible"}); got != want {
+		t.Fatalf("without a go.mod, Resolve() = %+v, want %+v", got, want)
+	}
+
+	// A go.mod without the major version suffix makes v3 unusable.
+	r.commit(map[string]string{"go.mod": "module " + r.path() + "\n"})
+	r.git("tag", "v3.0.0")
+	if got, err = r.resolve(); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Requirement{Path: r.path(), Version: "v2.0.0+incompatible"}); got != want {
+		t.Fatalf("with a go.mod lacking /v3, Resolve() = %+v, want %+v", got, want)
+	}
+
+	r.commit(map[string]string{"go.mod": "module " + r.path() + "/v4\n"})
+	r.git("tag", "v4.1.0")
+	if got, err = r.resolve(); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Requirement{Path: r.path() + "/v4", Version: "v4.1.0"}); got != want {
+		t.Fatalf("with a go.mod declaring /v4, Resolve() = %+v, want %+v", got, want)
+	}
+}
+
+func TestResolvePseudoVersion(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit(map[string]string{"go.mod": "module " + r.path() + "\n"})
+	r.commit(nil)
+
+	got, err := r.resolve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^v0\.0\.0-\d{14}-[0-9a-f]{12}$`).MatchString(got.Version) {
+		t.Fatalf("Resolve() = %+v, want a v0.0.0 pseudo-version", got)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	empty := newTestRepo(t)
+	if _, err := empty.resolve(); !errors.Is(err, ErrNoCommits) || !errors.Is(err, ErrNoVersions) {
+		t.Errorf("Resolve() of an empty repository = %v, want ErrNoCommits", err)
+	}
+
+	docs := newTestRepo(t)
+	docs.commit(map[string]string{"README.md": "docs\n"})
+	if _, err := docs.resolve(); !errors.Is(err, ErrNotAGoModule) {
+		t.Errorf("Resolve() of a repository without Go code = %v, want ErrNotAGoModule", err)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	if _, err := (&Resolver{}).Resolve(context.Background(), missing); !errors.Is(err, ErrRepoNotFound) {
+		t.Errorf("Resolve() of a missing repository = %v, want ErrRepoNotFound", err)
+	}
+
+	if _, err := (&Resolver{}).Resolve(context.Background(), "github.com/org/repo@v1.0.0"); err == nil {
+		t.Errorf("Resolve() with a ref succeeded")
+	}
+}
+
+func TestCloneFailure(t *testing.T) {
+	for _, tt := range []struct {
+		msg  string
+		want error
+	}{
+		{"fatal: Authentication failed for 'https://example.com/repo.git/'", ErrAuth},
+		{"fatal: could not read Username for 'https://example.com': terminal prompts disabled", ErrAuth},
+		{"git@example.com: Permission denied (publickey).", ErrAuth},
+		{"remote: Repository not found.\nfatal: repository 'https://example.com/repo.git/' not found", ErrRepoNotFound},
+		{"fatal: '/tmp/missing' does not appear to be a git repository", ErrRepoNotFound},
+		{"fatal: unable to access 'https://example.com/repo.git/': Could not resolve host: example.com", nil},
+	} {
+		if got := CloneFailure(tt.msg); got != tt.want {
+			t.Errorf("CloneFailure(%q) = %v, want %v", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestModulePath(t *testing.T) {
+	for _, tt := range []struct {
+		path, version, want string
+	}{
+		{"example.com/m", "v1.2.0", "example.com/m"},
+		{"example.com/m", "v2.1.0", "example.com/m/v2"},
+		{"example.com/m", "v2.1.0+incompatible", "example.com/m"},
+		{"example.com/m/v2", "v2.1.0", "example.com/m/v2"},
+		{"example.com/m/v3", "v2.1.0", "example.com/m/v3"},
+		{"gopkg.in/yaml.v2", "v2.4.0", "gopkg.in/yaml.v2"},
+		{"gopkg.in/yaml", "v2.4.0", "gopkg.in/yaml"},
+	} {
+		if got := modulePath(tt.path, tt.version); got != tt.want {
+			t.Errorf("modulePath(%s, %s) = %s, want %s", tt.path, tt.version, got, tt.want)
+		}
+	}
+}
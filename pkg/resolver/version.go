@@ -0,0 +1,234 @@
+package resolver
+
+import (
+	"fmt"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"path"
+	"strings"
+	"time"
+)
+
+// Tag is a tag advertised by a repository.
+type Tag struct {
+	Name string
+	// Commit is the SHA of the commit the tag ultimately points to.
+	Commit string
+	// Annotated is set for tags pointing to a tag object rather than
+	// directly to a commit. Only annotated tags can be signed.
+	Annotated bool
+}
+
+// ParseTags parses the output of git ls-remote --tags into the tags it
+// lists, in order. Annotated tags are peeled to the commit they point to.
+func ParseTags(out string) []Tag {
+	var names []string
+	commits := map[string]string{}
+	annotated := map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		sha, ref, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if !ok || !strings.HasPrefix(ref, "refs/tags/") {
+			continue
+		}
+		name := strings.TrimPrefix(ref, "refs/tags/")
+		if peeled, ok := strings.CutSuffix(name, "^{}"); ok {
+			commits[peeled] = sha
+			annotated[peeled] = true
+			continue
+		}
+		if _, ok := commits[name]; !ok {
+			names = append(names, name)
+			commits[name] = sha
+		}
+	}
+
+	tags := make([]Tag, len(names))
+	for i, n := range names {
+		tags[i] = Tag{Name: n, Commit: commits[n], Annotated: annotated[n]}
+	}
+	return tags
+}
+
+// TagPrefix returns the prefix of the tags of the module in subdir, as the go
+// command expects them: the module's directory followed by a slash, without
+// any major version suffix. Since the requested path may point to a package
+// rather than a module, the nearest directory from subdir up whose prefix is
+// found on semver tags is used, falling back to the root.
+func TagPrefix(tags []Tag, subdir string) string {
+	for dir := subdir; dir != "" && dir != "."; dir = path.Dir(dir) {
+		prefix := dir
+		if p, major, ok := module.SplitPathVersion(dir); ok && major != "" {
+			prefix = strings.TrimSuffix(p, "/")
+		}
+		if prefix == "" {
+			break
+		}
+		prefix += "/"
+		for _, t := range tags {
+			if name, ok := strings.CutPrefix(t.Name, prefix); ok && semver.IsValid(name) {
+				return prefix
+			}
+		}
+	}
+	return ""
+}
+
+// IsModuleVersion reports whether tag is usable as a module version as it
+// is. The go command only accepts canonical semantic versions, rejecting
+// build metadata such as v1.2.3+hotfix and shortened forms such as v1.2.
+func IsModuleVersion(tag string) bool {
+	return semver.IsValid(tag) && semver.Canonical(tag) == tag
+}
+
+// PrereleaseMode tells whether pre-release tags are eligible as the latest
+// version.
+type PrereleaseMode int
+
+const (
+	// PreFallback picks pre-releases only when there is no release, as go
+	// get does.
+	PreFallback PrereleaseMode = iota
+	// PreInclude ranks pre-releases along with releases.
+	PreInclude
+	// PreExclude never picks pre-releases.
+	PreExclude
+)
+
+// LatestVersion returns the latest version among tags, according to mode.
+// ok is false when there is no semver tag at all; with PreExclude, an error
+// is returned when there are pre-releases but no release.
+func LatestVersion(tags []Tag, mode PrereleaseMode) (tag Tag, ok bool, err error) {
+	var release, pre Tag
+	for _, t := range tags {
+		if !semver.IsValid(t.Name) {
+			continue
+		}
+		best := &release
+		if mode != PreInclude && semver.Prerelease(t.Name) != "" {
+			best = &pre
+		}
+		// Tags only differing in build metadata compare equal; the one
+		// usable as a module version is preferred.
+		c := semver.Compare(t.Name, best.Name)
+		if best.Name == "" || c > 0 || (c == 0 && IsModuleVersion(t.Name)) {
+			*best = t
+		}
+	}
+	switch {
+	case release.Name != "":
+		return release, true, nil
+	case pre.Name != "" && mode == PreExclude:
+		return Tag{}, false, fmt.Errorf("no stable release found, only pre-releases such as %s", pre.Name)
+	}
+	return pre, pre.Name != "", nil
+}
+
+// LatestModuleVersion returns, like LatestVersion, the latest of tags the go
+// command can use as a version of the module at path, along with that
+// version. Tags of another major version than the one path ends with are
+// skipped. For paths without a major version suffix, tags from v2 on are
+// usable when the module has no go.mod at them, as +incompatible versions,
+// or when its go.mod declares the suffix of their major version, which the
+// module path then has to be given; others are skipped, as go get does.
+// goMod is only called for such tags, returning the go.mod of the module
+// at the tag's commit, or nil when there is none.
+func LatestModuleVersion(path string, tags []Tag, mode PrereleaseMode, goMod func(Tag) ([]byte, error)) (tag Tag, version string, ok bool, err error) {
+	for {
+		tag, ok, err = LatestVersion(tags, mode)
+		if err != nil || !ok {
+			return Tag{}, "", false, err
+		}
+		version, ok, err = moduleVersion(path, tag, goMod)
+		if err != nil || ok {
+			return tag, version, ok, err
+		}
+		tags = withoutTag(tags, tag.Name)
+	}
+}
+
+// moduleVersion returns the version of the module at path tag stands for, as
+// LatestModuleVersion describes. ok is false when the go command cannot use
+// it.
+func moduleVersion(path string, tag Tag, goMod func(Tag) ([]byte, error)) (version string, ok bool, err error) {
+	if _, pathMajor, _ := module.SplitPathVersion(path); pathMajor != "" {
+		return tag.Name, module.CheckPathMajor(tag.Name, pathMajor) == nil, nil
+	}
+	major := semver.Major(tag.Name)
+	if major == "v0" || major == "v1" || semver.Build(tag.Name) != "" {
+		return tag.Name, true, nil
+	}
+	data, err := goMod(tag)
+	if err != nil {
+		return "", false, err
+	}
+	if data == nil {
+		return tag.Name + "+incompatible", true, nil
+	}
+	_, suffix, _ := module.SplitPathVersion(modfile.ModulePath(data))
+	return tag.Name, suffix == "/"+major, nil
+}
+
+// withoutTag returns tags without the one named name.
+func withoutTag(tags []Tag, name string) []Tag {
+	var result []Tag
+	for _, t := range tags {
+		if t.Name != name {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// Retraction returns the directive among retractions retracting version, or
+// nil when it is not retracted. A +incompatible suffix is ignored.
+func Retraction(retractions []*modfile.Retract, version string) *modfile.Retract {
+	version = strings.TrimSuffix(version, "+incompatible")
+	for _, r := range retractions {
+		if semver.Compare(r.Low, version) <= 0 && semver.Compare(version, r.High) <= 0 {
+			return r
+		}
+	}
+	return nil
+}
+
+// PseudoVersion builds the pseudo-version the go command assigns to the
+// commit rev of the module at path, committed at t. base is the highest
+// version of the module tagged at an ancestor of rev, as PseudoVersionBase
+// returns, so a commit following v1.2.3 gets v1.2.4-0.yyyymmddhhmmss-rev
+// and one following v1.3.0-rc.1 gets v1.3.0-rc.1.0.yyyymmddhhmmss-rev.
+// Without a base, it is vN.0.0-yyyymmddhhmmss-rev, N being the major
+// version of path.
+func PseudoVersion(path, base string, t time.Time, rev string) string {
+	if len(rev) > 12 {
+		rev = rev[:12]
+	}
+	_, pathMajor, _ := module.SplitPathVersion(path)
+	return module.PseudoVersion(module.PathMajorPrefix(pathMajor), base, t, rev)
+}
+
+// PseudoVersionBase returns the highest version of the module at path among
+// the names of tags, or an empty string when there is none. Only tags of
+// the major version of path are considered when it has one. Otherwise, tags
+// from v2 on are only considered when incompatible is set, as the go
+// command only bases pseudo-versions on them, as +incompatible versions,
+// for commits without a go.mod. Given the tags merged into a commit, it
+// returns the base of the commit's pseudo-version; given every tag of the
+// module, it tells whether the commit can have one.
+func PseudoVersionBase(path string, tags []string, incompatible bool) string {
+	_, pathMajor, _ := module.SplitPathVersion(path)
+	major := module.PathMajorPrefix(pathMajor)
+	var base string
+	for _, v := range tags {
+		if !IsModuleVersion(v) || (major != "" && semver.Major(v) != major) {
+			continue
+		}
+		if major == "" && !incompatible && semver.Major(v) != "v0" && semver.Major(v) != "v1" {
+			continue
+		}
+		if base == "" || semver.Compare(v, base) > 0 {
+			base = v
+		}
+	}
+	return base
+}
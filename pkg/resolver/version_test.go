@@ -0,0 +1,176 @@
+package resolver
+
+import (
+	"fmt"
+	"golang.org/x/mod/modfile"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func tags(names ...string) []Tag {
+	result := make([]Tag, len(names))
+	for i, n := range names {
+		result[i] = Tag{Name: n, Commit: "commit-" + n}
+	}
+	return result
+}
+
+func TestParseTags(t *testing.T) {
+	out := "aaa\trefs/tags/v1.0.0\n" +
+		"bbb\trefs/tags/v1.1.0\n" +
+		"ccc\trefs/tags/v1.1.0^{}\n" +
+		"ddd\trefs/heads/main\n"
+	want := []Tag{
+		{Name: "v1.0.0", Commit: "aaa"},
+		{Name: "v1.1.0", Commit: "ccc", Annotated: true},
+	}
+	if got := ParseTags(out); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseTags() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTagPrefix(t *testing.T) {
+	for _, tt := range []struct {
+		tags   []Tag
+		subdir string
+		want   string
+	}{
+		{tags("v1.0.0", "sub/v1.2.0"), "", ""},
+		{tags("v1.0.0", "sub/v1.2.0"), "sub", "sub/"},
+		{tags("v1.0.0", "sub/v1.2.0"), "sub/pkg", "sub/"},
+		{tags("v1.0.0", "sub/v2.0.0"), "sub/v2", "sub/"},
+		{tags("v1.0.0"), "sub", ""},
+		{tags("sub/latest"), "sub", ""},
+	} {
+		if got := TagPrefix(tt.tags, tt.subdir); got != tt.want {
+			t.Errorf("TagPrefix(%v, %q) = %q, want %q", tt.tags, tt.subdir, got, tt.want)
+		}
+	}
+}
+
+func TestLatestVersion(t *testing.T) {
+	for _, tt := range []struct {
+		tags    []Tag
+		mode    PrereleaseMode
+		want    string
+		wantErr bool
+	}{
+		{tags("v1.0.0", "v1.2.0", "v1.10.0", "latest"), PreFallback, "v1.10.0", false},
+		{tags("v1.0.0", "v1.1.0-rc.1"), PreFallback, "v1.0.0", false},
+		{tags("v1.0.0-rc.1", "v1.1.0-rc.1"), PreFallback, "v1.1.0-rc.1", false},
+		{tags("v1.0.0", "v1.1.0-rc.1"), PreInclude, "v1.1.0-rc.1", false},
+		{tags("v1.0.0-rc.1"), PreExclude, "", true},
+		{tags("v1.2.0+build", "v1.2.0"), PreFallback, "v1.2.0", false},
+		{tags("latest", "1.2.0"), PreFallback, "", false},
+	} {
+		got, ok, err := LatestVersion(tt.tags, tt.mode)
+		if (err != nil) != tt.wantErr || got.Name != tt.want || ok != (tt.want != "") {
+			t.Errorf("LatestVersion(%v, %d) = %q, %t, %v, want %q", tt.tags, tt.mode, got.Name, ok, err, tt.want)
+		}
+	}
+}
+
+func TestLatestModuleVersion(t *testing.T) {
+	goMods := map[string]string{
+		"commit-v2.0.0": "module example.com/repo/v2\n",
+		"commit-v3.0.0": "module example.com/repo\n",
+		"commit-v5.0.0": "module example.com/repo/v4\n",
+	}
+	goMod := func(tag Tag) ([]byte, error) {
+		if tag.Commit == "commit-v6.0.0" {
+			return nil, fmt.Errorf("unreachable")
+		}
+		if data, ok := goMods[tag.Commit]; ok {
+			return []byte(data), nil
+		}
+		return nil, nil
+	}
+
+	for _, tt := range []struct {
+		path    string
+		tags    []Tag
+		want    string
+		wantErr bool
+	}{
+		{"example.com/repo", tags("v1.0.0", "v1.2.0"), "v1.2.0", false},
+		{"example.com/repo", tags("v1.2.0", "v2.0.0"), "v2.0.0", false},
+		// A v2+ go.mod without the major version suffix is unusable.
+		{"example.com/repo", tags("v1.2.0", "v3.0.0"), "v1.2.0", false},
+		{"example.com/repo", tags("v1.2.0", "v5.0.0"), "v1.2.0", false},
+		// Without a go.mod, v2+ versions are +incompatible.
+		{"example.com/repo", tags("v1.2.0", "v4.0.0"), "v4.0.0+incompatible", false},
+		{"example.com/repo", tags("v3.0.0"), "", false},
+		{"example.com/repo", tags("v1.0.0", "v6.0.0"), "", true},
+		// Paths with a suffix only take versions of their major version.
+		{"example.com/repo/v2", tags("v1.2.0", "v2.0.0", "v2.1.0-rc.1", "v3.0.0"), "v2.0.0", false},
+		{"example.com/repo/v2", tags("v1.2.0"), "", false},
+		{"gopkg.in/yaml.v2", tags("v2.4.0", "v3.0.1"), "v2.4.0", false},
+	} {
+		_, got, ok, err := LatestModuleVersion(tt.path, tt.tags, PreFallback, goMod)
+		if (err != nil) != tt.wantErr || got != tt.want || ok != (tt.want != "") {
+			t.Errorf("LatestModuleVersion(%q, %v) = %q, %t, %v, want %q", tt.path, tt.tags, got, ok, err, tt.want)
+		}
+	}
+}
+
+func TestRetraction(t *testing.T) {
+	f, err := modfile.ParseLax("go.mod", []byte("module example.com/repo\nretract v1.1.0 // Broken.\nretract [v2.0.0, v2.1.0]\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		version string
+		want    bool
+	}{
+		{"v1.0.0", false},
+		{"v1.1.0", true},
+		{"v1.1.1", false},
+		{"v2.0.5+incompatible", true},
+		{"v2.1.0", true},
+		{"v2.1.1", false},
+	} {
+		if got := Retraction(f.Retract, tt.version) != nil; got != tt.want {
+			t.Errorf("Retraction(%s) = %t, want %t", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestPseudoVersion(t *testing.T) {
+	at := time.Date(2024, 1, 2, 15, 4, 5, 0, time.FixedZone("", 3600))
+	rev := "abcdefabcdef0123456789"
+	for _, tt := range []struct {
+		path, base, want string
+	}{
+		{"example.com/repo", "", "v0.0.0-20240102140405-abcdefabcdef"},
+		{"example.com/repo/v3", "", "v3.0.0-20240102140405-abcdefabcdef"},
+		{"example.com/repo", "v1.2.3", "v1.2.4-0.20240102140405-abcdefabcdef"},
+		{"example.com/repo", "v1.3.0-rc.1", "v1.3.0-rc.1.0.20240102140405-abcdefabcdef"},
+	} {
+		if got := PseudoVersion(tt.path, tt.base, at, rev); got != tt.want {
+			t.Errorf("PseudoVersion(%q, %q) = %q, want %q", tt.path, tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestPseudoVersionBase(t *testing.T) {
+	for _, tt := range []struct {
+		path         string
+		tags         []string
+		incompatible bool
+		want         string
+	}{
+		{"example.com/repo", nil, true, ""},
+		{"example.com/repo", []string{"v1.0.0", "v1.2.0", "v1.10.0-rc.1", "latest"}, false, "v1.10.0-rc.1"},
+		{"example.com/repo", []string{"v1.2", "v1.2.0+build"}, true, ""},
+		{"example.com/repo", []string{"v1.5.0", "v2.1.0"}, true, "v2.1.0"},
+		{"example.com/repo", []string{"v1.5.0", "v2.1.0"}, false, "v1.5.0"},
+		{"example.com/repo", []string{"v2.1.0"}, false, ""},
+		{"example.com/repo/v2", []string{"v1.5.0", "v2.1.0", "v3.0.0"}, false, "v2.1.0"},
+		{"example.com/repo/v2", []string{"v1.5.0"}, false, ""},
+	} {
+		if got := PseudoVersionBase(tt.path, tt.tags, tt.incompatible); got != tt.want {
+			t.Errorf("PseudoVersionBase(%q, %v, %t) = %q, want %q", tt.path, tt.tags, tt.incompatible, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"github.com/heyvito/go-require-generator/pkg/resolver"
+	"golang.org/x/mod/semver"
+	"path"
+)
+
+// Policy holds organisational rules enforced during resolution and by grg
+// check.
+type Policy struct {
+	Versions   []VersionBound `yaml:"versions"`
+	Signatures []SignerRule   `yaml:"signatures"`
+	Internal   []InternalRule `yaml:"internal"`
+}
+
+// VersionBound restricts the versions allowed for modules matching a glob.
+type VersionBound struct {
+	// Match is a path.Match glob matched against module paths.
+	Match string `yaml:"match"`
+	// Min is the lowest allowed version, inclusive.
+	Min string `yaml:"min"`
+	// Max is the highest allowed version, inclusive.
+	Max string `yaml:"max"`
+	// Reason documents why the bound exists, and is included in violations.
+	Reason string `yaml:"reason"`
+}
+
+// SignerRule restricts the keys allowed to sign releases of modules matching
+// a glob. It is enforced by --verify-signatures.
+type SignerRule struct {
+	// Match is a path.Match glob matched against module paths.
+	Match string `yaml:"match"`
+	// Fingerprints lists the accepted keys, as OpenPGP fingerprints or long
+	// key IDs, or SSH fingerprints in their SHA256:... form.
+	Fingerprints []string `yaml:"fingerprints"`
+	// Reason documents why the rule exists, and is included in violations.
+	Reason string `yaml:"reason"`
+}
+
+// InternalRule requires modules matching a glob to be fetched from approved
+// hosts only, so a public repository cannot shadow an internal module.
+type InternalRule struct {
+	// Match is a path.Match glob matched against module paths, except that
+	// a trailing * also matches nested paths, as in alignment groups:
+	// corp.example.com/* covers corp.example.com/team/lib.
+	Match string `yaml:"match"`
+	// Hosts lists path.Match globs of the hosts the modules may be fetched
+	// from. Repositories on the local filesystem are fetched from localhost.
+	Hosts []string `yaml:"hosts"`
+	// Reason documents why the rule exists, and is included in violations.
+	Reason string `yaml:"reason"`
+}
+
+func (p Policy) validate() error {
+	for i, r := range p.Internal {
+		if _, err := path.Match(r.Match, ""); err != nil || r.Match == "" {
+			return fmt.Errorf("policy internal rule #%d has an invalid match pattern %q", i+1, r.Match)
+		}
+		if len(r.Hosts) == 0 {
+			return fmt.Errorf("policy internal rule for %s lists no hosts", r.Match)
+		}
+		for _, h := range r.Hosts {
+			if _, err := path.Match(h, ""); err != nil {
+				return fmt.Errorf("policy internal rule for %s has an invalid host pattern %q", r.Match, h)
+			}
+		}
+	}
+	for i, r := range p.Signatures {
+		if _, err := path.Match(r.Match, ""); err != nil || r.Match == "" {
+			return fmt.Errorf("policy signature rule #%d has an invalid match pattern %q", i+1, r.Match)
+		}
+		if len(r.Fingerprints) == 0 {
+			return fmt.Errorf("policy signature rule for %s lists no fingerprints", r.Match)
+		}
+	}
+	for i, b := range p.Versions {
+		if _, err := path.Match(b.Match, ""); err != nil || b.Match == "" {
+			return fmt.Errorf("policy version bound #%d has an invalid match pattern %q", i+1, b.Match)
+		}
+		for _, v := range []string{b.Min, b.Max} {
+			if v != "" && !semver.IsValid(v) {
+				return fmt.Errorf("policy version bound for %s has an invalid version %q", b.Match, v)
+			}
+		}
+		if b.Min != "" && b.Max != "" && semver.Compare(b.Min, b.Max) > 0 {
+			return fmt.Errorf("policy version bound for %s has min %s greater than max %s", b.Match, b.Min, b.Max)
+		}
+	}
+	return nil
+}
+
+// bounds returns the version bounds applying to module.
+func (p Policy) bounds(module string) []VersionBound {
+	var result []VersionBound
+	for _, b := range p.Versions {
+		if ok, _ := path.Match(b.Match, module); ok {
+			result = append(result, b)
+		}
+	}
+	return result
+}
+
+// signers returns the signature rules applying to module.
+func (p Policy) signers(module string) []SignerRule {
+	var result []SignerRule
+	for _, r := range p.Signatures {
+		if ok, _ := path.Match(r.Match, module); ok {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// checkSource verifies that module, fetched from host, comes from a host
+// approved by every internal rule applying to it.
+func (p Policy) checkSource(module, host string) error {
+	for _, r := range p.Internal {
+		if !groupMatches(r.Match, module) {
+			continue
+		}
+		allowed := false
+		for _, h := range r.Hosts {
+			if ok, _ := path.Match(h, host); ok {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			msg := fmt.Sprintf("%s is an internal module, but was fetched from %s, which is not an approved host", module, host)
+			if r.Reason != "" {
+				msg += " (" + r.Reason + ")"
+			}
+			return fmt.Errorf("%s", msg)
+		}
+	}
+	return nil
+}
+
+// policyViolation describes a version falling outside a VersionBound.
+type policyViolation struct {
+	Module  string
+	Version string
+	Bound   VersionBound
+	// Ceiling is set when the version is above the bound's maximum, as
+	// opposed to below its minimum.
+	Ceiling bool
+}
+
+func (v policyViolation) Error() string {
+	msg := fmt.Sprintf("%s %s is below the policy floor %s", v.Module, v.Version, v.Bound.Min)
+	if v.Ceiling {
+		msg = fmt.Sprintf("%s %s is above the policy ceiling %s", v.Module, v.Version, v.Bound.Max)
+	}
+	if v.Bound.Reason != "" {
+		msg += " (" + v.Bound.Reason + ")"
+	}
+	return msg
+}
+
+// check returns the violations of version against the bounds applying to
+// module.
+func (p Policy) check(module, version string) []policyViolation {
+	var result []policyViolation
+	for _, b := range p.bounds(module) {
+		if b.Min != "" && semver.Compare(version, b.Min) < 0 {
+			result = append(result, policyViolation{Module: module, Version: version, Bound: b})
+		}
+		if b.Max != "" && semver.Compare(version, b.Max) > 0 {
+			result = append(result, policyViolation{Module: module, Version: version, Bound: b, Ceiling: true})
+		}
+	}
+	return result
+}
+
+// allows reports whether version satisfies every bound applying to module.
+func (p Policy) allows(module, version string) bool {
+	return len(p.check(module, version)) == 0
+}
+
+// newestAllowed returns the highest release tag of module satisfying the
+// policy.
+func (p Policy) newestAllowed(module string, tags []remoteTag) (remoteTag, bool) {
+	var best remoteTag
+	found := false
+	for _, t := range tags {
+		if !resolver.IsModuleVersion(t.Name) || semver.Prerelease(t.Name) != "" || !p.allows(module, t.Name) {
+			continue
+		}
+		if !found || semver.Compare(t.Name, best.Name) > 0 {
+			best, found = t, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestCheckSource(t *testing.T) {
+	p := Policy{Internal: []InternalRule{{Match: "corp.example.com/*", Hosts: []string{"git.corp.example.com"}}}}
+	for _, tt := range []struct {
+		module, host string
+		allowed      bool
+	}{
+		{"corp.example.com/lib", "git.corp.example.com", true},
+		{"corp.example.com/lib", "github.com", false},
+		{"corp.example.com/team/lib", "git.corp.example.com", true},
+		{"corp.example.com/team/lib", "github.com", false},
+		{"corp.example.com", "github.com", true},
+		{"github.com/corp/lib", "github.com", true},
+	} {
+		if err := p.checkSource(tt.module, tt.host); (err == nil) != tt.allowed {
+			t.Errorf("checkSource(%s, %s) = %v, want allowed %v", tt.module, tt.host, err, tt.allowed)
+		}
+	}
+}
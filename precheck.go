@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// defaultPorts maps URL schemes git clones through to their default port.
+var defaultPorts = map[string]string{
+	"https": "443",
+	"http":  "80",
+	"ssh":   "22",
+	"git":   "9418",
+}
+
+// dialTarget returns the host:port git connects to for the clone URL u, after
+// url.<base>.insteadOf rewrites. ok is false for local URLs, and for URLs
+// reached through an HTTP proxy, which cannot be checked directly.
+func dialTarget(ctx context.Context, git *gitRunner, u string) (target string, ok bool) {
+	if rewritten, err := git.run(ctx, "", nil, "ls-remote", "--get-url", u); err == nil {
+		u = rewritten
+	}
+	if !strings.Contains(u, "://") {
+		host, _, found := strings.Cut(u, ":")
+		if !found || strings.ContainsAny(host, "/\\") {
+			return "", false
+		}
+		if _, h, ok := strings.Cut(host, "@"); ok {
+			host = h
+		}
+		return net.JoinHostPort(host, "22"), true
+	}
+
+	parsed, err := url.Parse(u)
+	if err != nil || defaultPorts[parsed.Scheme] == "" {
+		return "", false
+	}
+	if parsed.Scheme == "http" || parsed.Scheme == "https" {
+		if proxy, err := http.ProxyFromEnvironment(&http.Request{URL: parsed}); err != nil || proxy != nil {
+			return "", false
+		}
+		if p, err := git.run(ctx, "", nil, "config", "--get", "http.proxy"); err == nil && p != "" {
+			return "", false
+		}
+	}
+	port := parsed.Port()
+	if port == "" {
+		port = defaultPorts[parsed.Scheme]
+	}
+	return net.JoinHostPort(parsed.Hostname(), port), true
+}
+
+// precheckHosts resolves and connects to every distinct host among reqs
+// concurrently, so repositories on unreachable hosts can fail immediately
+// instead of each waiting for its own connection timeout. A host is
+// unreachable when none of the addresses its clone URLs lead to accepts a
+// connection. The returned map holds the error of each unreachable host.
+func precheckHosts(ctx context.Context, git *gitRunner, cfg *Config, reqs []repoRequest) map[string]error {
+	targets := map[string][]string{}
+	for _, req := range reqs {
+		if req.LocalURL != "" || req.CloneURL != "" {
+			continue
+		}
+		host, candidates, err := req.roots()
+		if err != nil {
+			continue
+		}
+		if _, ok := targets[host]; ok {
+			continue
+		}
+		urls, err := cfg.cloneURLs(host, candidates[0])
+		if err != nil {
+			continue
+		}
+		var hostTargets []string
+		for _, u := range urls {
+			t, ok := dialTarget(ctx, git, u)
+			if !ok {
+				// Any target that cannot be checked may work.
+				hostTargets = nil
+				break
+			}
+			hostTargets = append(hostTargets, t)
+		}
+		targets[host] = hostTargets
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	unreachable := map[string]error{}
+	for host, hostTargets := range targets {
+		if len(hostTargets) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(host string, hostTargets []string) {
+			defer wg.Done()
+			if err := reachAny(ctx, git, hostTargets); err != nil {
+				git.logf("%s is unreachable: %s\n", host, err)
+				mu.Lock()
+				unreachable[host] = fmt.Errorf("%s is unreachable: %w", host, err)
+				mu.Unlock()
+			}
+		}(host, hostTargets)
+	}
+	wg.Wait()
+	return unreachable
+}
+
+// reachAny connects to each of targets concurrently, succeeding as soon as
+// one of them accepts the connection.
+func reachAny(ctx context.Context, git *gitRunner, targets []string) error {
+	ctx, cancel := context.WithTimeout(ctx, protocolDialTimeout)
+	defer cancel()
+
+	errs := make(chan error, len(targets))
+	dialer := net.Dialer{}
+	for _, t := range targets {
+		go func(t string) {
+			conn, err := dialer.DialContext(ctx, "tcp", t)
+			if err == nil {
+				_ = conn.Close()
+			}
+			errs <- err
+		}(t)
+	}
+	var last error
+	for range targets {
+		if err := <-errs; err == nil {
+			return nil
+		} else {
+			last = err
+		}
+	}
+	return last
+}
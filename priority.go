@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// ioLimits maps --io-limit values to ionice arguments.
+var ioLimits = map[string][]string{
+	// idle only gets disk time when no other process needs it.
+	"idle": {"-c", "3"},
+	// low keeps the best-effort class at its lowest priority.
+	"low": {"-c", "2", "-n", "7"},
+}
+
+// priorityWrapper returns the command prefix that runs git under the given
+// niceness and IO limit, built from the nice and ionice utilities. Platforms
+// lacking either utility, such as Windows and macOS for ionice, run git at
+// normal priority for that resource, with a warning.
+func priorityWrapper(nice int, ioLimit string) ([]string, error) {
+	if nice < 0 || nice > 19 {
+		return nil, fmt.Errorf("--git-nice must be between 0 and 19, got %d", nice)
+	}
+	var ionice []string
+	if ioLimit != "" {
+		var ok bool
+		if ionice, ok = ioLimits[ioLimit]; !ok {
+			return nil, fmt.Errorf("unknown --io-limit %q; valid values are idle and low", ioLimit)
+		}
+	}
+
+	var wrapper []string
+	if len(ionice) > 0 {
+		if p, err := exec.LookPath("ionice"); err == nil {
+			wrapper = append(append(wrapper, p), ionice...)
+		} else {
+			warnf(tr("ionice is not available on this system; --io-limit is ignored"))
+		}
+	}
+	if nice > 0 {
+		if p, err := exec.LookPath("nice"); err == nil {
+			wrapper = append(wrapper, p, "-n", strconv.Itoa(nice))
+		} else {
+			warnf(tr("nice is not available on this system; --git-nice is ignored"))
+		}
+	}
+	return wrapper, nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// protocolMeasureTTL is how long a measured protocol preference is reused
+// before the host is measured again.
+const protocolMeasureTTL = 24 * time.Hour
+
+// protocolDialTimeout bounds each latency measurement.
+const protocolDialTimeout = 3 * time.Second
+
+// protocolPorts maps the protocols grg clones through to the port measured
+// for each.
+var protocolPorts = map[string]string{
+	"ssh":   "22",
+	"https": "443",
+}
+
+// hostProtocol is the persistent record of the protocol preferred for a
+// host.
+type hostProtocol struct {
+	Host      string    `json:"host"`
+	Preferred string    `json:"preferred"`
+	Measured  time.Time `json:"measured"`
+}
+
+// protocolSelector decides whether SSH or HTTPS is attempted first for each
+// host. In auto mode, the protocol whose port answers fastest is preferred,
+// and the decision is kept in memory and in the cache.
+type protocolSelector struct {
+	// mode is auto, ssh or https.
+	mode     string
+	cacheDir string
+
+	// mu only guards hosts; each host is measured once, through its own
+	// hostPreference, so measuring one host does not hold up the others.
+	mu    sync.Mutex
+	hosts map[string]*hostPreference
+}
+
+// hostPreference is the protocol preferred for a host, decided once.
+type hostPreference struct {
+	once  sync.Once
+	proto string
+}
+
+func newProtocolSelector(mode, cacheDir string) (*protocolSelector, error) {
+	if mode != "auto" && protocolPorts[mode] == "" {
+		return nil, fmt.Errorf("unknown protocol %q; use auto, ssh or https", mode)
+	}
+	return &protocolSelector{mode: mode, cacheDir: cacheDir, hosts: map[string]*hostPreference{}}, nil
+}
+
+// preferred returns the protocol to attempt first for host.
+func (p *protocolSelector) preferred(git *gitRunner, host string) string {
+	if p == nil {
+		return "ssh"
+	}
+	if p.mode != "auto" {
+		return p.mode
+	}
+
+	p.mu.Lock()
+	h, ok := p.hosts[host]
+	if !ok {
+		h = &hostPreference{}
+		p.hosts[host] = h
+	}
+	p.mu.Unlock()
+
+	h.once.Do(func() {
+		proto, ok := p.load(host)
+		if !ok {
+			if proto, ok = measureProtocols(git, host); ok {
+				p.save(host, proto)
+			}
+		}
+		h.proto = proto
+	})
+	return h.proto
+}
+
+func (p *protocolSelector) entryPath(host string) string {
+	return filepath.Join(p.cacheDir, "hosts", host+".json")
+}
+
+func (p *protocolSelector) load(host string) (string, bool) {
+	if p.cacheDir == "" {
+		return "", false
+	}
+	data, err := os.ReadFile(p.entryPath(host))
+	if err != nil {
+		return "", false
+	}
+	var entry hostProtocol
+	if json.Unmarshal(data, &entry) != nil || protocolPorts[entry.Preferred] == "" || time.Since(entry.Measured) > protocolMeasureTTL {
+		return "", false
+	}
+	return entry.Preferred, true
+}
+
+func (p *protocolSelector) save(host, proto string) {
+	if p.cacheDir == "" {
+		return
+	}
+	data, err := json.MarshalIndent(hostProtocol{Host: host, Preferred: proto, Measured: time.Now().UTC()}, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(p.entryPath(host), data)
+	}
+	if err != nil {
+		warnf(tr("could not update cache: %s"), err)
+	}
+}
+
+// measureProtocols times a TCP connection to the SSH and HTTPS ports of
+// host concurrently, returning the protocol that answered first. When
+// neither answers, ok is false and SSH is returned, keeping grg's historical
+// order.
+func measureProtocols(git *gitRunner, host string) (proto string, ok bool) {
+	type result struct {
+		proto   string
+		latency time.Duration
+		err     error
+	}
+	results := make(chan result, len(protocolPorts))
+	for proto, port := range protocolPorts {
+		go func(proto, port string) {
+			start := time.Now()
+			conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), protocolDialTimeout)
+			if err == nil {
+				_ = conn.Close()
+			}
+			results <- result{proto, time.Since(start), err}
+		}(proto, port)
+	}
+
+	best := result{proto: "ssh"}
+	found := false
+	for range protocolPorts {
+		r := <-results
+		if r.err != nil {
+			git.logf("Could not reach %s over %s: %s\n", host, r.proto, r.err)
+			continue
+		}
+		git.logf("%s answers over %s in %s\n", host, r.proto, r.latency.Round(time.Millisecond))
+		if !found || r.latency < best.latency {
+			best, found = r, true
+		}
+	}
+	return best.proto, found
+}
+
+// orderURLs moves the URLs of the preferred protocol first, keeping the
+// relative order of the rest.
+func orderURLs(urls []string, preferred string) []string {
+	isHTTPS := func(u string) bool { return strings.HasPrefix(u, "https://") }
+	var first, rest []string
+	for _, u := range urls {
+		if isHTTPS(u) == (preferred == "https") {
+			first = append(first, u)
+		} else {
+			rest = append(rest, u)
+		}
+	}
+	return append(first, rest...)
+}
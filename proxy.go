@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/mod/module"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultGoProxy = "https://proxy.golang.org,direct"
+
+var (
+	// errProxyNotFound is returned when a proxy has no record of the
+	// requested module or version.
+	errProxyNotFound = errors.New("not found on proxy")
+	// errProxyDirect is returned when GOPROXY falls back to fetching
+	// directly from version control.
+	errProxyDirect = errors.New("GOPROXY requests a direct fetch")
+	// errProxyOff is returned when GOPROXY disallows module downloads.
+	errProxyOff = errors.New("module lookup disabled by GOPROXY=off")
+)
+
+// proxyInfo is the response to a module proxy's .info and @latest
+// endpoints.
+type proxyInfo struct {
+	Version string
+	Time    time.Time
+	Origin  *struct {
+		VCS  string
+		URL  string
+		Hash string
+		Ref  string
+	}
+}
+
+// goProxy returns the effective value of GOPROXY.
+func goProxy() string {
+	return goEnv().Proxy
+}
+
+// proxyEntry is a single element of GOPROXY.
+type proxyEntry struct {
+	// URL is the proxy URL, or one of the keywords "direct" and "off".
+	URL string
+	// FallbackOnError is set when the entry is followed by a pipe, meaning
+	// any error moves on to the next entry. Entries followed by a comma only
+	// fall back when the module or version is not found.
+	FallbackOnError bool
+}
+
+// parseGoProxy splits a GOPROXY value into its entries, following the same
+// rules as the go command.
+func parseGoProxy(v string) ([]proxyEntry, error) {
+	var entries []proxyEntry
+	for v != "" {
+		i := strings.IndexAny(v, ",|")
+		url, sep := v, byte(0)
+		if i >= 0 {
+			url, sep, v = v[:i], v[i], v[i+1:]
+		} else {
+			v = ""
+		}
+		url = strings.TrimSpace(url)
+		if url == "" {
+			continue
+		}
+		if url != "direct" && url != "off" && !strings.Contains(url, "://") {
+			// The go command assumes HTTPS for scheme-less entries.
+			url = "https://" + url
+		}
+		entries = append(entries, proxyEntry{URL: strings.TrimSuffix(url, "/"), FallbackOnError: sep == '|'})
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("GOPROXY is empty")
+	}
+	return entries, nil
+}
+
+// proxyLookup queries the proxies listed in GOPROXY for the metadata of mod
+// at version (or @latest when version is empty), falling back between them
+// the same way the go command does. It returns the info along with the proxy
+// that served it. errProxyDirect and errProxyOff are returned when the chain
+// reaches the direct or off keywords.
+func proxyLookup(ctx context.Context, mod, version string) (*proxyInfo, string, error) {
+	var info *proxyInfo
+	proxy, err := walkProxies(mod, func(proxy string) (err error) {
+		info, err = fetchProxyInfo(ctx, proxy, mod, version)
+		return err
+	})
+	return info, proxy, err
+}
+
+// proxyVersions lists the versions of mod known to the proxies in GOPROXY,
+// through the @v/list endpoint, returning them with the proxy that served
+// them. Fallback follows proxyLookup.
+func proxyVersions(ctx context.Context, mod string) ([]string, string, error) {
+	var versions []string
+	proxy, err := walkProxies(mod, func(proxy string) (err error) {
+		versions, err = fetchProxyList(ctx, proxy, mod)
+		return err
+	})
+	return versions, proxy, err
+}
+
+// proxyGoMod returns the go.mod of mod at version from the proxies in
+// GOPROXY, through the @v/<version>.mod endpoint. Fallback follows
+// proxyLookup.
+func proxyGoMod(ctx context.Context, mod, version string) ([]byte, error) {
+	var data []byte
+	_, err := walkProxies(mod, func(proxy string) (err error) {
+		data, err = fetchProxyMod(ctx, proxy, mod, version)
+		return err
+	})
+	return data, err
+}
+
+// walkProxies calls query with each proxy of GOPROXY applying to mod until
+// one succeeds, as described in proxyLookup, and returns that proxy.
+func walkProxies(mod string, query func(proxy string) error) (string, error) {
+	if goEnv().bypassesProxy(mod) {
+		return "", errProxyDirect
+	}
+
+	entries, err := parseGoProxy(goProxy())
+	if err != nil {
+		return "", err
+	}
+
+	var lastErr error
+	for _, e := range entries {
+		switch e.URL {
+		case "direct":
+			return "", errProxyDirect
+		case "off":
+			return "", errProxyOff
+		}
+
+		err := query(e.URL)
+		if err == nil {
+			return e.URL, nil
+		}
+		lastErr = err
+		if !e.FallbackOnError && !errors.Is(err, errProxyNotFound) {
+			return e.URL, err
+		}
+	}
+	return "", lastErr
+}
+
+// fetchProxyInfo queries proxy for the metadata of mod at version. An empty
+// version queries @latest.
+func fetchProxyInfo(ctx context.Context, proxy, mod, version string) (*proxyInfo, error) {
+	path, err := module.EscapePath(mod)
+	if err != nil {
+		return nil, err
+	}
+	u := fmt.Sprintf("%s/%s/@latest", proxy, path)
+	if version != "" {
+		v, err := module.EscapeVersion(version)
+		if err != nil {
+			return nil, err
+		}
+		u = fmt.Sprintf("%s/%s/@v/%s.info", proxy, path, v)
+	}
+
+	res, err := proxyGet(ctx, proxy, u)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(res)
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return nil, errProxyNotFound
+	default:
+		return nil, fmt.Errorf("unexpected status %s from %s", res.Status, proxy)
+	}
+
+	info := &proxyInfo{}
+	if err = json.NewDecoder(res.Body).Decode(info); err != nil {
+		return nil, fmt.Errorf("invalid response from %s: %w", proxy, err)
+	}
+	return info, nil
+}
+
+// fetchProxyList queries proxy for the list of known versions of mod.
+func fetchProxyList(ctx context.Context, proxy, mod string) ([]string, error) {
+	path, err := module.EscapePath(mod)
+	if err != nil {
+		return nil, err
+	}
+	res, err := proxyGet(ctx, proxy, fmt.Sprintf("%s/%s/@v/list", proxy, path))
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(res)
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return nil, errProxyNotFound
+	default:
+		return nil, fmt.Errorf("unexpected status %s from %s", res.Status, proxy)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(body)), nil
+}
+
+// fetchProxyMod queries proxy for the go.mod of mod at version.
+func fetchProxyMod(ctx context.Context, proxy, mod, version string) ([]byte, error) {
+	path, err := module.EscapePath(mod)
+	if err != nil {
+		return nil, err
+	}
+	v, err := module.EscapeVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	res, err := proxyGet(ctx, proxy, fmt.Sprintf("%s/%s/@v/%s.mod", proxy, path, v))
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(res)
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return nil, errProxyNotFound
+	default:
+		return nil, fmt.Errorf("unexpected status %s from %s", res.Status, proxy)
+	}
+	return io.ReadAll(res.Body)
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/heyvito/go-require-generator/pkg/resolver"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// processProxy resolves req through the module proxy protocol instead of
+// git, picking the version go get would: the highest release listed by the
+// proxy, then the highest pre-release, and @latest for modules without any
+// tagged version, skipping versions the module retracts. Modules GOPROXY
+// and GONOPROXY have fetched directly are resolved through git instead, as
+// go get does. Internal modules are only looked up on proxies approved by
+// the policy.
+func processProxy(ctx context.Context, git *gitRunner, cfg *Config, opts resolveOptions, req repoRequest) (resolution, error) {
+	if req.LocalURL != "" {
+		return resolution{}, errors.New(tr("local repositories cannot be resolved through a module proxy"))
+	}
+	path := req.ModulePath
+	if err := checkProxySources(cfg.Policy, path); err != nil {
+		return resolution{}, err
+	}
+
+	var r resolution
+	err := git.stage(ctx, "proxy", func(ctx context.Context) (err error) {
+		r, err = selectProxyVersion(ctx, path, opts, req)
+		return err
+	})
+	if errors.Is(err, errProxyDirect) {
+		git.logf("%s is fetched directly according to GOPROXY and GONOPROXY; resolving it through git\n", path)
+		opts.Proxy = false
+		return processRepo(ctx, git, cfg, opts, req)
+	}
+	if err != nil {
+		return resolution{}, proxyModeError(path, err)
+	}
+	if req.Ref == "" {
+		r = opts.Overrides.apply(r)
+	}
+
+	if violations := cfg.Policy.check(r.Path, r.Version); len(violations) > 0 {
+		floor := false
+		for _, v := range violations {
+			floor = floor || !v.Ceiling
+		}
+		if floor || req.Ref != "" || opts.Channel != "" || r.Note != "" {
+			return resolution{}, violations[0]
+		}
+		var versions []string
+		err := git.stage(ctx, "proxy", func(ctx context.Context) (err error) {
+			versions, _, err = proxyVersions(ctx, path)
+			return err
+		})
+		if err != nil {
+			return resolution{}, proxyModeError(path, err)
+		}
+		tag, ok := cfg.Policy.newestAllowed(path, versionTags(versions))
+		if !ok {
+			return resolution{}, fmt.Errorf(tr("%w; no release satisfies the policy"), violations[0])
+		}
+		res := resolution{Path: path, Version: tag.Name, Warnings: r.Warnings}
+		res.warn(warnPolicyDowngrade, tr("%s; selected %s instead"), violations[0], tag.Name)
+		r = res
+	}
+
+	err = git.stage(ctx, "proxy", func(ctx context.Context) (err error) {
+		r, err = enforceProxyRetractions(ctx, git, cfg.Policy, opts, req, r)
+		return err
+	})
+	if err != nil {
+		return resolution{}, proxyModeError(path, err)
+	}
+
+	checkReleaseAssets(ctx, git, &r, "", "", opts.Platforms)
+	return r, nil
+}
+
+// checkProxySources verifies that every proxy GOPROXY may query for path is
+// approved by the internal rules of policy, before any of them is contacted,
+// so the path of an internal module is never sent to a public proxy. Modules
+// fetched directly are checked against their repository by processRepo.
+func checkProxySources(policy Policy, path string) error {
+	if len(policy.Internal) == 0 || goEnv().bypassesProxy(path) {
+		return nil
+	}
+	entries, err := parseGoProxy(goProxy())
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.URL == "direct" || e.URL == "off" {
+			break
+		}
+		if err = policy.checkSource(path, urlHost(e.URL)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selectProxyVersion picks the version of the module at path to emit in
+// proxy mode.
+func selectProxyVersion(ctx context.Context, path string, opts resolveOptions, req repoRequest) (resolution, error) {
+	if req.Ref != "" && isVersionQuery(req.Ref) {
+		// Proxies only answer exact queries; the go command matches the
+		// others against the list of versions.
+		versions, _, err := proxyVersions(ctx, path)
+		if err != nil {
+			return resolution{}, err
+		}
+		tag, err := matchVersionQuery(versionTags(versions), req.Ref)
+		if err != nil {
+			return resolution{}, err
+		}
+		return resolution{Path: path, Version: tag.Name}, nil
+	}
+	if req.Ref != "" {
+		// Proxies resolve revisions to their canonical version, the same
+		// way go get path@rev does.
+		info, _, err := proxyLookup(ctx, path, req.Ref)
+		if err != nil {
+			return resolution{}, err
+		}
+		return resolution{Path: path, Version: info.Version}, nil
+	}
+
+	versions, _, err := proxyVersions(ctx, path)
+	if err != nil && !errors.Is(err, errProxyNotFound) {
+		return resolution{}, err
+	}
+	tags := versionTags(versions)
+
+	if opts.Channel != "" {
+		tag, ok := latestInChannel(tags, opts.Channel)
+		if !ok {
+			return resolution{}, fmt.Errorf(tr("no %s pre-release versions found"), opts.Channel)
+		}
+		return resolution{Path: path, Version: tag.Name}, nil
+	}
+
+	if tag, ok, err := latestAllowed(path, tags, opts, nil); err != nil || ok {
+		return resolution{Path: path, Version: tag.Name}, err
+	}
+
+	info, _, err := proxyLookup(ctx, path, "")
+	if err != nil {
+		return resolution{}, err
+	}
+	return resolution{Path: path, Version: info.Version}, nil
+}
+
+// enforceProxyRetractions makes sure r is not retracted by the module, as
+// enforceRetractions does for repositories, reading the retractions from the
+// go.mod the proxy serves for the latest version it lists.
+func enforceProxyRetractions(ctx context.Context, git *gitRunner, policy Policy, opts resolveOptions, req repoRequest, r resolution) (resolution, error) {
+	if module.IsPseudoVersion(r.Version) {
+		return r, nil
+	}
+	versions, _, err := proxyVersions(ctx, r.Path)
+	if err != nil {
+		return resolution{}, err
+	}
+	tags := versionTags(versions)
+	latest, ok, _ := resolver.LatestVersion(tags, preFallback)
+	if !ok {
+		return r, nil
+	}
+	data, err := proxyGoMod(ctx, r.Path, latest.Name)
+	if errors.Is(err, errProxyNotFound) {
+		git.logf("Ignoring retractions of %s: no proxy serves its go.mod at %s\n", r.Path, latest.Name)
+		return r, nil
+	}
+	if err != nil {
+		return resolution{}, err
+	}
+	f, err := modfile.ParseLax("go.mod", data, nil)
+	if err != nil {
+		git.logf("Ignoring retractions of %s: failed parsing its go.mod at %s: %s\n", r.Path, latest.Name, err)
+		return r, nil
+	}
+	retract := resolver.Retraction(f.Retract, r.Version)
+	if retract == nil {
+		return r, nil
+	}
+	retracted := retractedError(r.Version, retract)
+	if req.Ref != "" || opts.Channel != "" || r.Note != "" {
+		r.warn(warnRetracted, "%s", retracted)
+		return r, nil
+	}
+
+	var candidates []remoteTag
+	for _, t := range tags {
+		if resolver.Retraction(f.Retract, t.Name) == nil && policy.allows(r.Path, t.Name) {
+			candidates = append(candidates, t)
+		}
+	}
+	tag, ok, err := latestAllowed(r.Path, candidates, opts, nil)
+	if err != nil || !ok {
+		return resolution{}, fmt.Errorf(tr("%w; no older version is available"), retracted)
+	}
+	res := resolution{Path: r.Path, Version: tag.Name, Warnings: r.Warnings}
+	res.warn(warnRetracted, tr("%s; selected %s instead"), retracted, res.Version)
+	return res, nil
+}
+
+// versionTags converts versions listed by a proxy to remoteTags, so the
+// selection helpers written for git tags apply to them. Commits are unknown.
+func versionTags(versions []string) []remoteTag {
+	tags := make([]remoteTag, len(versions))
+	for i, v := range versions {
+		tags[i] = remoteTag{Name: v}
+	}
+	return tags
+}
+
+// proxyModeError explains failures that are specific to proxy mode.
+func proxyModeError(path string, err error) error {
+	switch {
+	case errors.Is(err, errProxyOff):
+		return errors.New(tr("module downloads are disabled by GOPROXY=off"))
+	case errors.Is(err, errProxyNotFound):
+		return fmt.Errorf(tr("%s was not found on any proxy"), path)
+	}
+	return err
+}
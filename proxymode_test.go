@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// setGoEnv replaces the Go environment seen by grg for the duration of a
+// test.
+func setGoEnv(t *testing.T, env goEnvironment) {
+	t.Helper()
+	saved := goEnv()
+	goEnvValue = env
+	t.Cleanup(func() { goEnvValue = saved })
+}
+
+func TestProcessProxyInternalModule(t *testing.T) {
+	queried := false
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queried = true
+		http.NotFound(w, r)
+	}))
+	defer proxy.Close()
+	setGoEnv(t, goEnvironment{Proxy: proxy.URL + ",direct"})
+
+	cfg := &Config{Policy: Policy{Internal: []InternalRule{{Match: "corp.example.com/*", Hosts: []string{"git.corp.example.com"}}}}}
+	req := repoRequest{Input: "corp.example.com/lib", ModulePath: "corp.example.com/lib"}
+	_, err := processRepo(context.Background(), &gitRunner{}, cfg, resolveOptions{Proxy: true}, req)
+	if err == nil || !strings.Contains(err.Error(), "internal module") {
+		t.Errorf("processRepo() = %v, want an internal module error", err)
+	}
+	if queried {
+		t.Error("processRepo() queried a proxy that is not an approved host")
+	}
+}
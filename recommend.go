@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Recommendation suggests modules that are commonly used along with others.
+type Recommendation struct {
+	// When is a path.Match glob matched against the packages the project
+	// imports and the modules it requires.
+	When string `yaml:"when"`
+	// Suggest lists the module paths to suggest when When matches.
+	Suggest []string `yaml:"suggest"`
+	// Reason explains why the modules are suggested.
+	Reason string `yaml:"reason"`
+}
+
+func (r Recommendation) validate(i int) error {
+	if _, err := path.Match(r.When, ""); err != nil || r.When == "" {
+		return fmt.Errorf("recommendation #%d has an invalid when pattern %q", i+1, r.When)
+	}
+	if len(r.Suggest) == 0 {
+		return fmt.Errorf("recommendation for %s suggests no modules", r.When)
+	}
+	return nil
+}
+
+// defaultRecommendations is used when the configuration file defines no
+// recommendations.
+var defaultRecommendations = []Recommendation{
+	{
+		When:    "go.opentelemetry.io/otel/sdk",
+		Suggest: []string{"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"},
+		Reason:  "the OpenTelemetry SDK needs an exporter to send traces anywhere",
+	},
+	{
+		When:    "google.golang.org/grpc",
+		Suggest: []string{"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"},
+		Reason:  "instruments gRPC clients and servers",
+	},
+	{
+		When:    "github.com/prometheus/client_golang/prometheus",
+		Suggest: []string{"github.com/prometheus/client_golang"},
+		Reason:  "promhttp exposes the registered metrics over HTTP",
+	},
+}
+
+var recommendCommand = &cli.Command{
+	Name:  "recommend",
+	Usage: "Suggests modules commonly paired with the ones the current module uses",
+	Description: "Suggestions come from the recommendations key of the configuration file, for example:\n\n" +
+		"  recommendations:\n" +
+		"    - when: go.opentelemetry.io/otel/sdk\n" +
+		"      suggest: [go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc]\n" +
+		"      reason: the SDK needs an exporter\n\n" +
+		"A built-in set is used when none are configured. Modules already required are not suggested.",
+	Flags: []cli.Flag{modfileFlag},
+	Action: func(ctx *cli.Context) error {
+		git, cfg, err := setup(ctx)
+		if err != nil {
+			return err
+		}
+		modPath, err := goModPath(ctx)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		f, err := readGoMod(modPath)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+
+		used := map[string]bool{}
+		required := map[string]bool{}
+		for _, r := range f.Require {
+			used[r.Mod.Path] = true
+			required[r.Mod.Path] = true
+		}
+		imports, err := projectImports(filepath.Dir(modPath))
+		if err != nil {
+			return cli.Exit(trf("Could not read the project's imports: %s", err), 1)
+		}
+		for _, i := range imports {
+			used[i] = true
+		}
+
+		rules := cfg.Recommendations
+		if len(rules) == 0 {
+			rules = defaultRecommendations
+		}
+		suggestions := recommend(rules, used, required)
+		if len(suggestions) == 0 {
+			fmt.Println(tr("No recommendations"))
+			return nil
+		}
+
+		opts := resolveOptions{Proxy: ctx.Bool("proxy")}
+		for _, s := range suggestions {
+			r, err := processRepo(ctx.Context, git, cfg, opts, repoRequest{Input: s.Module, ModulePath: s.Module})
+			if err != nil {
+				fmt.Printf(tr("// %s (%s): could not be resolved: %s\n"), s.Module, s.note(), err)
+				continue
+			}
+			printWarnings(os.Stderr, r)
+			r.Note = s.note()
+			fmt.Println(r.requireLine())
+		}
+		return nil
+	},
+}
+
+// suggestion is a module recommended by one or more rules.
+type suggestion struct {
+	Module string
+	// Because lists the imports or modules that triggered the suggestion.
+	Because []string
+	Reason  string
+}
+
+func (s suggestion) note() string {
+	note := "used with " + strings.Join(s.Because, ", ")
+	if s.Reason != "" {
+		note += ": " + s.Reason
+	}
+	return note
+}
+
+// recommend returns the modules suggested by rules for a project using the
+// packages and modules in used, except those already required, sorted by
+// module path.
+func recommend(rules []Recommendation, used, required map[string]bool) []suggestion {
+	byModule := map[string]*suggestion{}
+	for _, rule := range rules {
+		var because []string
+		for u := range used {
+			if ok, _ := path.Match(rule.When, u); ok {
+				because = append(because, u)
+			}
+		}
+		if len(because) == 0 {
+			continue
+		}
+		sort.Strings(because)
+		for _, m := range rule.Suggest {
+			if required[m] {
+				continue
+			}
+			s, ok := byModule[m]
+			if !ok {
+				s = &suggestion{Module: m, Reason: rule.Reason}
+				byModule[m] = s
+			}
+			s.Because = append(s.Because, because...)
+		}
+	}
+
+	result := make([]suggestion, 0, len(byModule))
+	for _, s := range byModule {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Module < result[j].Module })
+	return result
+}
+
+// projectImports returns the packages imported by the Go files of the module
+// rooted at dir, excluding nested modules, vendored code and testdata.
+func projectImports(dir string) ([]string, error) {
+	seen := map[string]bool{}
+	fset := token.NewFileSet()
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if p != dir && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(p, "go.mod")); p != dir && err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(p, ".go") {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, p, nil, parser.ImportsOnly)
+		if err != nil {
+			// Files that do not parse do not build either; skip them.
+			return nil
+		}
+		for _, i := range f.Imports {
+			if v, err := strconv.Unquote(i.Path.Value); err == nil {
+				seen[v] = true
+			}
+		}
+		return nil
+	})
+	imports := make([]string, 0, len(seen))
+	for i := range seen {
+		imports = append(imports, i)
+	}
+	sort.Strings(imports)
+	return imports, err
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runManifest records an invocation of grg: its inputs, the environment it
+// ran in, the decisions taken for each input and the output produced. It is
+// written by --record and verified by grg replay.
+type runManifest struct {
+	Created time.Time `json:"created"`
+	// Flags holds the global flags the run was invoked with, in --name=value
+	// form.
+	Flags []string `json:"flags"`
+	// Inputs holds the repositories resolved, including those read from a
+	// batch.
+	Inputs []string `json:"inputs"`
+	// Env holds the environment variables influencing resolution, redacted.
+	Env     []string         `json:"env"`
+	Results []recordedResult `json:"results"`
+	Output  []string         `json:"output,omitempty"`
+}
+
+// recordedResult is the outcome of resolving a single input.
+type recordedResult struct {
+	Input    string    `json:"input"`
+	Path     string    `json:"path,omitempty"`
+	Version  string    `json:"version,omitempty"`
+	Note     string    `json:"note,omitempty"`
+	Warnings []warning `json:"warnings,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// unrecordedFlags lists global flags left out of manifests: the recording
+// itself, and batch handling, as batches are recorded as plain inputs.
+var unrecordedFlags = map[string]bool{
+	"record":      true,
+	"batch":       true,
+	"batch-limit": true,
+	"help":        true,
+}
+
+// newRunManifest starts a manifest for the invocation described by ctx,
+// resolving inputs.
+func newRunManifest(ctx *cli.Context, inputs []string) *runManifest {
+	m := &runManifest{Created: time.Now().UTC(), Inputs: inputs}
+	for _, f := range ctx.App.Flags {
+		name := f.Names()[0]
+		if unrecordedFlags[name] || !ctx.IsSet(name) {
+			continue
+		}
+		if _, ok := f.(*cli.StringSliceFlag); ok {
+			for _, v := range ctx.StringSlice(name) {
+				m.Flags = append(m.Flags, fmt.Sprintf("--%s=%s", name, v))
+			}
+			continue
+		}
+		m.Flags = append(m.Flags, fmt.Sprintf("--%s=%v", name, ctx.Value(name)))
+	}
+	for _, kv := range relevantEnv() {
+		m.Env = append(m.Env, redactEnv(kv))
+	}
+	sort.Strings(m.Env)
+	return m
+}
+
+// add records the outcome of req. It does nothing on a nil manifest, so
+// callers need not check whether recording is enabled.
+func (m *runManifest) add(req repoRequest, r resolution, err error) {
+	if m == nil {
+		return
+	}
+	res := recordedResult{Input: req.Input}
+	if err != nil {
+		res.Error = err.Error()
+	} else {
+		res.Path, res.Version, res.Note, res.Warnings = r.Path, r.Version, r.Note, r.Warnings
+	}
+	m.Results = append(m.Results, res)
+}
+
+// write saves the manifest to path along with output.
+func (m *runManifest) write(path string, output []string) error {
+	if m == nil {
+		return nil
+	}
+	m.Output = output
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func readRunManifest(path string) (*runManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &runManifest{}
+	if err = json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid run manifest %s: %w", path, err)
+	}
+	return m, nil
+}
+
+var replayCommand = &cli.Command{
+	Name:      "replay",
+	Usage:     "Re-runs a recorded invocation and verifies it produces the same results",
+	ArgsUsage: "run.json",
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() != 1 {
+			return cli.ShowSubcommandHelp(ctx)
+		}
+		recorded, err := readRunManifest(ctx.Args().First())
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+
+		dir, err := os.MkdirTemp("", "grg-replay-")
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		defer func() { _ = os.RemoveAll(dir) }()
+		replayPath := filepath.Join(dir, "run.json")
+
+		// The replayed run reports its own failures; they are compared
+		// below rather than ending the process.
+		app := *ctx.App
+		app.ExitErrHandler = func(*cli.Context, error) {}
+		args := append([]string{app.Name, "--record", replayPath}, recorded.Flags...)
+		args = append(append(args, "--"), recorded.Inputs...)
+		_ = app.Run(args)
+
+		replayed, err := readRunManifest(replayPath)
+		if err != nil {
+			return cli.Exit(trf("Replay did not complete: %s", err), 1)
+		}
+
+		for _, d := range diffEnv(recorded.Env, replayed.Env) {
+			warnf(tr("environment differs: %s"), d)
+		}
+		diffs := diffResults(recorded.Results, replayed.Results)
+		fmt.Println()
+		if len(diffs) == 0 {
+			fmt.Printf(tr("Replay of %s matches the recorded results\n"), ctx.Args().First())
+			return nil
+		}
+		fmt.Println(tr("The replay differs from the recorded run:"))
+		for _, d := range diffs {
+			fmt.Printf("  %s\n", d)
+		}
+		return cli.Exit("", 1)
+	},
+}
+
+// diffEnv describes the variables that differ between two environment
+// snapshots.
+func diffEnv(recorded, replayed []string) []string {
+	toMap := func(env []string) map[string]string {
+		m := map[string]string{}
+		for _, kv := range env {
+			k, v, _ := strings.Cut(kv, "=")
+			m[k] = v
+		}
+		return m
+	}
+	a, b := toMap(recorded), toMap(replayed)
+	var diffs []string
+	for k, v := range a {
+		if w, ok := b[k]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s was %q and is now unset", k, v))
+		} else if v != w {
+			diffs = append(diffs, fmt.Sprintf("%s was %q and is now %q", k, v, w))
+		}
+	}
+	for k, v := range b {
+		if _, ok := a[k]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s was unset and is now %q", k, v))
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+// diffResults describes the inputs whose outcome differs between two runs.
+func diffResults(recorded, replayed []recordedResult) []string {
+	byInput := map[string]recordedResult{}
+	for _, r := range replayed {
+		byInput[r.Input] = r
+	}
+	outcome := func(r recordedResult) string {
+		if r.Error != "" {
+			return "error: " + r.Error
+		}
+		return r.Path + " " + r.Version
+	}
+
+	var diffs []string
+	for _, r := range recorded {
+		got, ok := byInput[r.Input]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: missing from the replay", r.Input))
+			continue
+		}
+		if outcome(r) != outcome(got) {
+			diffs = append(diffs, fmt.Sprintf("%s: recorded %s, replayed %s", r.Input, outcome(r), outcome(got)))
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const redacted = "***"
+
+var (
+	urlPattern       = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s'"]+`)
+	authHeaderPrefix = regexp.MustCompile(`(?i)(authorization:\s*)(\S+\s+)?\S+`)
+	sensitiveEnvKey  = regexp.MustCompile(`(?i)(TOKEN|SECRET|PASSWORD|PASSWD|CREDENTIAL|AUTH|_KEY$|^KEY$)`)
+	sensitiveQuery   = regexp.MustCompile(`(?i)(token|secret|password|key|signature|auth)`)
+)
+
+// redactURL removes credentials from a single URL. Usernames are kept for
+// protocols where they are customarily not secret (e.g. ssh://git@host), but
+// HTTP(S) usernames are removed as well, since tokens are commonly passed that
+// way.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+
+	changed := false
+	if u.User != nil {
+		_, hasPassword := u.User.Password()
+		switch {
+		case hasPassword:
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			changed = true
+		case u.Scheme == "http" || u.Scheme == "https":
+			u.User = url.User(redacted)
+			changed = true
+		}
+	}
+
+	if u.RawQuery != "" {
+		q := u.Query()
+		for k := range q {
+			if sensitiveQuery.MatchString(k) {
+				q.Set(k, redacted)
+				changed = true
+			}
+		}
+		if changed {
+			u.RawQuery = q.Encode()
+		}
+	}
+
+	if !changed {
+		return raw
+	}
+	// url.URL escapes the placeholder; undo it for readability.
+	return strings.ReplaceAll(u.String(), url.QueryEscape(redacted), redacted)
+}
+
+// redactString removes credentials from every URL and authorization header
+// found in s.
+func redactString(s string) string {
+	s = urlPattern.ReplaceAllStringFunc(s, redactURL)
+	return authHeaderPrefix.ReplaceAllString(s, "${1}"+redacted)
+}
+
+// redactEnv returns a KEY=VALUE entry with its value hidden when the key looks
+// like it holds a credential, and with credentials stripped from any URLs
+// otherwise.
+func redactEnv(kv string) string {
+	k, v, ok := strings.Cut(kv, "=")
+	if !ok {
+		return kv
+	}
+	if sensitiveEnvKey.MatchString(k) {
+		return k + "=" + redacted
+	}
+	return k + "=" + redactString(v)
+}
+
+// relevantEnvPrefixes lists environment variables that influence how git and
+// Go resolve repositories, and are therefore worth capturing in verbose logs.
+var relevantEnvPrefixes = []string{"GIT_", "SSH_", "GO", "HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy"}
+
+// relevantEnv returns the environment variables that may influence
+// resolution, in KEY=VALUE form.
+func relevantEnv() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		for _, p := range relevantEnvPrefixes {
+			if strings.HasPrefix(kv, p) {
+				env = append(env, kv)
+				break
+			}
+		}
+	}
+	return env
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/heyvito/go-require-generator/pkg/resolver"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/modfile"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// registryFileName is the name of the registry file in the cache directory.
+const registryFileName = "registry.json"
+
+// registry records the internal modules of the groups synced through grg
+// registry sync, along with their latest versions, so they need not be
+// selected among the tags of their repositories.
+type registry struct {
+	// Groups maps host/group to the time the group was last synced.
+	Groups map[string]time.Time `json:"groups"`
+	// Modules maps module paths to what is known about them.
+	Modules map[string]registryModule `json:"modules"`
+}
+
+// registryModule is a module recorded in the registry.
+type registryModule struct {
+	// Repo is the repository holding the module, as host/path.
+	Repo string `json:"repo"`
+	// Version is the latest version of the module, empty when it has no
+	// tag usable as a module version.
+	Version string `json:"version,omitempty"`
+	Commit  string `json:"commit,omitempty"`
+	// LastActivity is when the host last reported activity in the
+	// repository.
+	LastActivity time.Time `json:"lastActivity"`
+	SyncedAt     time.Time `json:"syncedAt"`
+}
+
+// loadRegistry reads the registry in the cache directory dir, returning an
+// empty one when it does not exist.
+func loadRegistry(dir string) (*registry, error) {
+	reg := &registry{Groups: map[string]time.Time{}, Modules: map[string]registryModule{}}
+	data, err := os.ReadFile(filepath.Join(dir, registryFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return reg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, reg); err != nil {
+		return nil, fmt.Errorf("corrupted registry %s: %w", filepath.Join(dir, registryFileName), err)
+	}
+	if reg.Groups == nil {
+		reg.Groups = map[string]time.Time{}
+	}
+	if reg.Modules == nil {
+		reg.Modules = map[string]registryModule{}
+	}
+	return reg, nil
+}
+
+func (reg *registry) save(dir string) error {
+	data, err := json.MarshalIndent(reg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, registryFileName), data)
+}
+
+// lookup returns the version of req recorded in the registry, which only
+// happens for requests of the latest version whose selection needs nothing
+// but the tags, and when the recorded version satisfies the policy. ok is
+// false when the version must be selected among the tags instead. The
+// version is subject to the same checks as a selected one, overrides
+// included.
+func (reg *registry) lookup(git *gitRunner, cfg *Config, opts resolveOptions, req repoRequest) (resolution, bool) {
+	if reg == nil || req.Ref != "" || req.LocalURL != "" {
+		return resolution{}, false
+	}
+	if opts.Channel != "" || opts.Nightly || opts.Scheme != nil || opts.Constraint != nil || opts.Prereleases != preFallback ||
+		opts.CrossCheck || opts.VerifySignatures || opts.Inspect || len(opts.Platforms) > 0 || opts.GoVersion != "" {
+		return resolution{}, false
+	}
+	m, ok := reg.Modules[req.ModulePath]
+	if !ok || m.Version == "" {
+		return resolution{}, false
+	}
+	host, _, _ := strings.Cut(m.Repo, "/")
+	if cfg.Policy.checkSource(req.ModulePath, host) != nil || len(cfg.Policy.check(req.ModulePath, m.Version)) > 0 {
+		return resolution{}, false
+	}
+	git.logf("Using %s %s from the registry, synced at %s\n", req.ModulePath, m.Version, m.SyncedAt.Local().Format(time.DateTime))
+	return resolution{Path: req.ModulePath, Version: m.Version}, true
+}
+
+var registryCommand = &cli.Command{
+	Name:  "registry",
+	Usage: "Maintains a local registry of internal modules, whose versions are used instead of selecting among their tags",
+	Subcommands: []*cli.Command{
+		registrySyncCommand,
+	},
+}
+
+var registrySyncCommand = &cli.Command{
+	Name:  "sync",
+	Usage: "Records the Go modules of a GitLab group and its subgroups, along with their latest versions",
+	Description: "Only repositories with activity since the previous sync of the group are queried, unless\n" +
+		"--full is given. Repositories are recognized as modules by the go.mod at the root of their\n" +
+		"default branch. A token for private groups is read from GITLAB_TOKEN.",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "from",
+			Usage:    "GitLab `HOST` to sync from, or the URL of its web interface",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "group",
+			Usage:    "Full path of the `GROUP` to sync, such as platform or platform/backend",
+			Required: true,
+		},
+		&cli.BoolFlag{
+			Name:  "full",
+			Usage: "Queries every repository of the group, forgetting modules that no longer exist",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		dir, err := cacheDirFlag(ctx)
+		if err != nil {
+			return err
+		}
+		git, cfg, err := setup(ctx)
+		if err != nil {
+			return err
+		}
+		api, host, err := gitlabAPI(ctx.String("from"))
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		group := strings.Trim(ctx.String("group"), "/")
+
+		reg, err := loadRegistry(dir)
+		if err != nil {
+			return cli.Exit(trf("Could not read the registry: %s", err), 1)
+		}
+		key := host + "/" + group
+		since := reg.Groups[key]
+		if ctx.Bool("full") {
+			since = time.Time{}
+			for mod, m := range reg.Modules {
+				if strings.HasPrefix(m.Repo, key+"/") {
+					delete(reg.Modules, mod)
+				}
+			}
+		}
+
+		started := time.Now()
+		projects, err := listGitLabProjects(ctx.Context, api, group, since)
+		if err != nil {
+			return cli.Exit(trf("Could not list the projects of %s: %s", group, err), 1)
+		}
+		updated := 0
+		for _, p := range projects {
+			mod, err := gitlabModulePath(ctx.Context, api, p)
+			if err != nil {
+				warnf(tr("could not read go.mod of %s: %s"), p.PathWithNamespace, err)
+				continue
+			}
+			if mod == "" {
+				git.logf("%s has no go.mod; skipping\n", p.PathWithNamespace)
+				continue
+			}
+			m, err := syncRegistryModule(ctx.Context, git, cfg, host, p, mod)
+			if err != nil {
+				warnf(tr("could not list tags of %s: %s"), p.PathWithNamespace, err)
+				continue
+			}
+			reg.Modules[mod] = m
+			updated++
+			version := m.Version
+			if version == "" {
+				version = tr("no release")
+			}
+			fmt.Printf("%s %s\n", mod, version)
+		}
+		reg.Groups[key] = started
+		if err = reg.save(dir); err != nil {
+			return cli.Exit(trf("Could not write the registry: %s", err), 1)
+		}
+
+		total := 0
+		for _, m := range reg.Modules {
+			if strings.HasPrefix(m.Repo, key+"/") {
+				total++
+			}
+		}
+		fmt.Printf(tr("Synced %s: %d modules updated, %d recorded\n"), key, updated, total)
+		return nil
+	},
+}
+
+// gitlabAPI returns the base URL of the REST API of the GitLab instance at
+// from, a host or the URL of its web interface, along with its host.
+func gitlabAPI(from string) (string, string, error) {
+	if !strings.Contains(from, "://") {
+		from = "https://" + from
+	}
+	u, err := url.Parse(from)
+	if err != nil || u.Host == "" {
+		return "", "", fmt.Errorf("invalid GitLab host %q", from)
+	}
+	return strings.TrimSuffix(u.String(), "/") + "/api/v4", u.Hostname(), nil
+}
+
+// gitlabProject is a project as listed by the GitLab API.
+type gitlabProject struct {
+	ID                int       `json:"id"`
+	PathWithNamespace string    `json:"path_with_namespace"`
+	DefaultBranch     string    `json:"default_branch"`
+	LastActivityAt    time.Time `json:"last_activity_at"`
+}
+
+// gitlabGet performs a GET request against the GitLab API, authenticated
+// with GITLAB_TOKEN when set. The response is nil when the resource does not
+// exist.
+func gitlabGet(ctx context.Context, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv("GITLAB_TOKEN"); token != "" {
+		req.Header.Set("PRIVATE-TOKEN", token)
+	}
+	res, err := apiClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		closeBody(res)
+		return nil, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		closeBody(res)
+		return nil, fmt.Errorf("unexpected status %s from %s", res.Status, u)
+	}
+	return res, nil
+}
+
+// listGitLabProjects lists the projects of group and its subgroups with
+// activity after since, or all of them when since is zero. Archived
+// projects are left out.
+func listGitLabProjects(ctx context.Context, api, group string, since time.Time) ([]gitlabProject, error) {
+	q := url.Values{
+		"include_subgroups": {"true"},
+		"archived":          {"false"},
+		"per_page":          {"100"},
+		"order_by":          {"id"},
+		"sort":              {"asc"},
+	}
+	if !since.IsZero() {
+		// GitLab updates the last activity of a project at most once an
+		// hour, so activity during the hour before the previous sync may
+		// not have been reported then.
+		q.Set("last_activity_after", since.Add(-time.Hour).UTC().Format(time.RFC3339))
+	}
+
+	var projects []gitlabProject
+	for page := "1"; page != ""; {
+		q.Set("page", page)
+		res, err := gitlabGet(ctx, fmt.Sprintf("%s/groups/%s/projects?%s", api, url.PathEscape(group), q.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		if res == nil {
+			return nil, fmt.Errorf("group %s was not found", group)
+		}
+		var batch []gitlabProject
+		err = json.NewDecoder(res.Body).Decode(&batch)
+		page = res.Header.Get("X-Next-Page")
+		closeBody(res)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, batch...)
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].PathWithNamespace < projects[j].PathWithNamespace })
+	return projects, nil
+}
+
+// gitlabModulePath reads the module path declared by the go.mod at the root
+// of p's default branch, returning an empty string when there is none.
+func gitlabModulePath(ctx context.Context, api string, p gitlabProject) (string, error) {
+	if p.DefaultBranch == "" {
+		return "", nil
+	}
+	u := fmt.Sprintf("%s/projects/%d/repository/files/go.mod/raw?ref=%s", api, p.ID, url.QueryEscape(p.DefaultBranch))
+	res, err := gitlabGet(ctx, u)
+	if err != nil || res == nil {
+		return "", err
+	}
+	defer closeBody(res)
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	return modfile.ModulePath(data), nil
+}
+
+// syncRegistryModule lists the tags of p, fetched as grg fetches any
+// repository of host, and records the latest version of mod, the module at
+// its root, skipping tags the go command cannot use as versions of mod as
+// resolution does.
+func syncRegistryModule(ctx context.Context, git *gitRunner, cfg *Config, host string, p gitlabProject, mod string) (registryModule, error) {
+	repo := host + "/" + p.PathWithNamespace
+	m := registryModule{Repo: repo, LastActivity: p.LastActivityAt, SyncedAt: time.Now()}
+	s, err := openSession(ctx, git, cfg, repoRequest{Input: repo, ModulePath: repo})
+	if err != nil {
+		return registryModule{}, err
+	}
+	defer s.close()
+	tags, err := s.remoteTags(ctx)
+	if err != nil {
+		return registryModule{}, err
+	}
+	if s.remoteOnly() {
+		tag, ok, _ := resolver.LatestVersion(tags, preFallback)
+		if ok && resolver.IsModuleVersion(tag.Name) {
+			m.Version, m.Commit = tag.Name, tag.Commit
+		}
+		return m, nil
+	}
+	tag, version, ok, err := resolver.LatestModuleVersion(mod, tags, preFallback, moduleFileOf(ctx, s))
+	if err != nil {
+		return registryModule{}, err
+	}
+	if ok && resolver.IsModuleVersion(tag.Name) {
+		m.Version, m.Commit = version, tag.Commit
+	}
+	return m, nil
+}
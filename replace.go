@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"os"
+)
+
+var replaceCommand = &cli.Command{
+	Name:      "replace",
+	Usage:     "Resolves the current version of a fork and prints the replace directive pointing a module at it",
+	ArgsUsage: "upstream-module fork-repo[@ref]",
+	Description: "The fork's version is selected as when printing require directives, so its latest tag is\n" +
+		"used unless a ref is given, in which case commits without a tag get a pseudo-version. Forks\n" +
+		"usually keep the upstream module path in their go.mod, which the go command accepts for\n" +
+		"replacements.",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "write",
+			Usage: "Adds the replace directive to go.mod instead of printing it",
+		},
+		smokeTestFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return cli.ShowSubcommandHelp(ctx)
+		}
+		if err := validateSmokeTest(ctx.String("smoke-test")); err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		if ctx.IsSet("smoke-test") && !ctx.Bool("write") {
+			return cli.Exit(tr("--smoke-test requires --write"), 1)
+		}
+		upstream := ctx.Args().Get(0)
+		if err := module.CheckPath(upstream); err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+
+		git, cfg, err := setup(ctx)
+		if err != nil {
+			return err
+		}
+		opts, err := resolveOptionsFromFlags(ctx)
+		if err != nil {
+			return err
+		}
+		reqs, err := buildRequests(ctx.Args().Slice()[1:], ctx.StringSlice("module-path"))
+		if err == nil {
+			err = applyRefFlags(reqs, ctx.String("commit"), ctx.String("tag"), ctx.String("branch"))
+		}
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		r, err := processRepo(ctx.Context, git, cfg, opts, reqs[0])
+		if err == nil {
+			r, err = forkReplacement(upstream, reqs[0], r)
+		}
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		printWarnings(os.Stderr, r)
+
+		if !ctx.Bool("write") {
+			f := &modfile.File{Syntax: &modfile.FileSyntax{}}
+			if err = f.AddReplace(upstream, "", r.Path, r.Version); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			data, err := f.Format()
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			fmt.Print(string(data))
+			return nil
+		}
+
+		path, err := findGoMod(".")
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		backup, err := backupGoMod(path)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		mod, err := readGoMod(path)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		required := false
+		for _, req := range mod.Require {
+			required = required || req.Mod.Path == upstream
+		}
+		if err = mod.AddReplace(upstream, "", r.Path, r.Version); err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		if err = writeGoMod(path, mod); err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		if cmd := ctx.String("smoke-test"); cmd != "" {
+			if err = smokeTest(backup, cmd); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+		}
+		fmt.Printf(tr("Replaced %s with %s %s in %s\n"), upstream, r.Path, r.Version, path)
+		if !required {
+			fmt.Printf(tr("%s does not require %s, so the replacement has no effect yet\n"), path, upstream)
+		}
+		return nil
+	},
+}
+
+// forkReplacement returns r, a resolution of the fork req refers to, with
+// the module path that can replace upstream. Forks keeping the upstream
+// module path in their go.mod are replaced by the fork's path, followed by
+// the major version suffix of upstream, which the go command looks up in
+// the fork's repository; warnings about the declared path are dropped, as it
+// is expected. A fork declaring any other module path cannot replace
+// upstream.
+func forkReplacement(upstream string, req repoRequest, r resolution) (resolution, error) {
+	if r.Path != upstream {
+		if prefix, _, ok := module.SplitPathVersion(r.Path); !ok || prefix != req.ModulePath {
+			return resolution{}, fmt.Errorf("go.mod of %s at %s declares module %s, so it cannot replace %s", req.ModulePath, r.Version, r.Path, upstream)
+		}
+		return r, nil
+	}
+
+	r.Path = req.ModulePath
+	if _, pathMajor, _ := module.SplitPathVersion(upstream); pathMajor != "" {
+		if _, forkMajor, _ := module.SplitPathVersion(r.Path); forkMajor == "" {
+			r.Path += pathMajor
+		}
+	}
+	warnings := r.Warnings[:0]
+	for _, w := range r.Warnings {
+		if w.Code != warnModulePathMismatch {
+			warnings = append(warnings, w)
+		}
+	}
+	r.Warnings = warnings
+	return r, nil
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// resolveFlags are the flags of grg resolve. They are global flags as well,
+// so bare grg repo-url keeps accepting them, and the ones selecting versions
+// apply to the other commands resolving repositories.
+var resolveFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "channel",
+		Usage: "Selects the newest pre-release tag of the given channel (alpha, beta or rc)",
+	},
+	&cli.StringFlag{
+		Name:  "constraint",
+		Usage: "Picks the newest version satisfying a semver `RANGE`, such as ^1.4, ~1.2 or \"<2.0.0\"",
+	},
+	&cli.BoolFlag{
+		Name:  "include-pre",
+		Usage: "Picks the highest tag even when it is a pre-release such as v1.5.0-rc.1",
+	},
+	&cli.BoolFlag{
+		Name:  "stable-only",
+		Usage: "Never picks pre-release tags, failing when a repository has nothing else",
+	},
+	&cli.StringFlag{
+		Name:  "compatible-with-go",
+		Usage: "Skips versions whose go.mod requires a Go release newer than `VERSION` (e.g. 1.20), picking the newest one that does not",
+	},
+	&cli.BoolFlag{
+		Name:  "nightly",
+		Usage: "Emits the pseudo-version of the default branch's HEAD even when tags exist",
+	},
+	&cli.StringFlag{
+		Name:  "commit",
+		Usage: "Resolves the given commit `SHA` of repositories given without @ref",
+	},
+	&cli.StringFlag{
+		Name:  "tag",
+		Usage: "Resolves the given tag of repositories given without @ref",
+	},
+	&cli.StringFlag{
+		Name:  "branch",
+		Usage: "Resolves the tip of the given branch of repositories given without @ref, usually as a pseudo-version",
+	},
+	&cli.BoolFlag{
+		Name:  "cross-check",
+		Usage: "Verifies resolved versions against the module proxy, failing when they disagree",
+	},
+	&cli.BoolFlag{
+		Name:  "accept-moved-tag",
+		Usage: "Allows emitting tags that point to a different commit than when they were first seen",
+	},
+	&cli.StringSliceFlag{
+		Name:  "module-path",
+		Usage: "Module path to emit for a local repository",
+	},
+	&cli.BoolFlag{
+		Name:  "verify-signatures",
+		Usage: "Fails unless the selected tag, or commit for pseudo-versions, is signed by a key the policy allows",
+	},
+	&cli.BoolFlag{
+		Name:  "warn-unsigned",
+		Usage: "Warns when the selected tag is not signed, as is always done for modules with a signature rule in the policy",
+	},
+	&cli.StringFlag{
+		Name:  "version-scheme",
+		Usage: "How release tags are ordered: semver, calver, or a regular expression capturing numeric version parts",
+		Value: "semver",
+	},
+	&cli.StringSliceFlag{
+		Name:  "platform",
+		Usage: "Warns when the selected release has no prebuilt binary for `GOOS/GOARCH` (may be repeated)",
+	},
+	&cli.StringFlag{
+		Name:    "format",
+		Aliases: []string{"output"},
+		Usage:   "Output format: require, go-get, go-install, script, env, brew or scoop",
+		Value:   "require",
+	},
+	&cli.StringFlag{
+		Name:  "shell",
+		Usage: "Shell scripts are generated for with --format script: bash or pwsh",
+		Value: "bash",
+	},
+	&cli.StringFlag{
+		Name:  "env-prefix",
+		Usage: "Prefix of the variable names emitted by --format env",
+		Value: "MODULE_",
+	},
+	&cli.StringFlag{
+		Name:  "env-key",
+		Usage: "Derives --format env variable names from the last element of the module path (base) or all of it (full)",
+		Value: "base",
+	},
+	&cli.StringFlag{
+		Name:  "sort",
+		Usage: "Orders results by path, version or host instead of completion order",
+	},
+	&cli.StringFlag{
+		Name:  "group-by",
+		Usage: "Groups results by host; host is the only supported value",
+	},
+	&cli.StringFlag{
+		Name:  "eol",
+		Usage: "Line terminator of the output: lf or crlf",
+		Value: "lf",
+	},
+	&cli.DurationFlag{
+		Name:  "deadline",
+		Usage: "Stops after `DURATION` (e.g. 2m), sharing it evenly across repositories and reporting the ones left as timed out",
+	},
+	&cli.BoolFlag{
+		Name:  "no-registry",
+		Usage: "Queries repositories even when the registry maintained by grg registry sync knows their latest version",
+	},
+	&cli.BoolFlag{
+		Name:  "no-precheck",
+		Usage: "Skips checking that every host is reachable before cloning",
+	},
+	&cli.BoolFlag{
+		Name:  "proxy",
+		Usage: "Resolves versions through the module proxies in GOPROXY instead of cloning, picking what go get would",
+	},
+	&cli.IntFlag{
+		Name:  "jobs",
+		Value: 1,
+		Usage: "Number of repositories resolved concurrently; output keeps the order of the arguments",
+	},
+	&cli.StringFlag{
+		Name:  "record",
+		Usage: "Writes a manifest of the run, for auditing or grg replay, to `FILE`",
+	},
+	&cli.StringFlag{
+		Name:  "batch",
+		Usage: "Reads repositories from `FILE` (one per line, - for stdin) and prints results as NDJSON as they complete",
+	},
+	&cli.IntFlag{
+		Name:  "batch-limit",
+		Usage: "Maximum number of repositories accepted in batch mode",
+		Value: defaultBatchLimit,
+	},
+	&cli.BoolFlag{
+		Name:  "explain",
+		Usage: "Prints the stages each repository went through and the time they took to stderr",
+	},
+	&cli.BoolFlag{
+		Name:  "with-sum",
+		Usage: "Also prints the go.sum lines of the selected versions, from the checksum database or computed locally for modules GONOSUMDB exempts",
+	},
+}
+
+var resolveCommand = &cli.Command{
+	Name:      "resolve",
+	Usage:     "Obtains require statements for git repositories, which is also what grg does without a command",
+	ArgsUsage: "repo-url [repo-url [repo-url [...]]]",
+	Description: "Each repo-url is either a module-like path such as github.com/user/repo, or a\n" +
+		"local repository given as a file:// URL or filesystem path. Local repositories\n" +
+		"require their module path to be declared through --module-path, once per local\n" +
+		"repository, in the same order. A repo-url may end in @ref to resolve a commit,\n" +
+		"tag or branch instead of the latest release.",
+	Flags:  resolveFlags,
+	Before: rejectShadowedFlags,
+	Action: resolveAction,
+}
+
+// rejectShadowedFlags fails when flags of grg resolve were given before the
+// command name, where its own defaults would silently take precedence.
+func rejectShadowedFlags(ctx *cli.Context) error {
+	parent := ctx.Lineage()[1]
+	for _, f := range resolveFlags {
+		if name := f.Names()[0]; parent.IsSet(name) && !ctx.IsSet(name) {
+			return cli.Exit(trf("--%s must be given after %s", name, ctx.Command.Name), 1)
+		}
+	}
+	return nil
+}
+
+// resolveAction resolves the repositories given as arguments, or through
+// --batch, and prints the results in the selected format.
+func resolveAction(ctx *cli.Context) error {
+	args := ctx.Args().Slice()
+	batch := ctx.IsSet("batch")
+	if batch {
+		batchArgs, err := readBatch(ctx.String("batch"))
+		if err != nil {
+			return cli.Exit(trf("Could not read batch: %s", err), 1)
+		}
+		args = append(args, batchArgs...)
+		if len(args) > ctx.Int("batch-limit") {
+			return cli.Exit(trf("Batch contains %d repositories, exceeding the limit of %d", len(args), ctx.Int("batch-limit")), 1)
+		}
+	}
+
+	if len(args) == 0 {
+		if ctx.Command.Name == "resolve" {
+			return cli.ShowSubcommandHelp(ctx)
+		}
+		return cli.ShowAppHelp(ctx)
+	}
+
+	formatName := ctx.String("format")
+	format, ok := outputFormats[formatName]
+	if !ok {
+		return cli.Exit(trf("Unknown format %q; valid formats are %s", ctx.String("format"), strings.Join(outputFormatNames(), ", ")), 1)
+	}
+	eol, ok := eols[ctx.String("eol")]
+	if !ok {
+		return cli.Exit(trf("Unknown line terminator %q; use lf or crlf", ctx.String("eol")), 1)
+	}
+	out := eolWriter{w: os.Stdout, eol: eol}
+	sortBy := ctx.String("sort")
+	if _, ok := sortKeys[sortBy]; sortBy != "" && !ok {
+		return cli.Exit(trf("Unknown sort key %q; use path, version or host", sortBy), 1)
+	}
+	if g := ctx.String("group-by"); g != "" && g != "host" {
+		return cli.Exit(trf("Unknown grouping %q; only host is supported", g), 1)
+	}
+	groupByHost := ctx.String("group-by") == "host"
+	jobs := ctx.Int("jobs")
+	if jobs < 1 {
+		return cli.Exit(trf("--jobs must be at least 1, got %d", jobs), 1)
+	}
+	// Ordered output can only be written once every repository
+	// was resolved.
+	ordered := sortBy != "" || groupByHost
+	withSum := ctx.Bool("with-sum")
+	if withSum && (batch || formatName != "require") {
+		return cli.Exit(tr("--with-sum is only supported with --format require"), 1)
+	}
+
+	git, cfg, err := setup(ctx)
+	if err != nil {
+		return err
+	}
+
+	opts, err := resolveOptionsFromFlags(ctx)
+	if err != nil {
+		return err
+	}
+
+	reqs, err := buildRequests(args, ctx.StringSlice("module-path"))
+	if err == nil {
+		err = applyRefFlags(reqs, ctx.String("commit"), ctx.String("tag"), ctx.String("branch"))
+	}
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	// The first interrupt stops resolution and prints what was
+	// resolved so far; a second one terminates grg immediately.
+	interrupt, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-interrupt.Done()
+		stop()
+	}()
+
+	var unreachable map[string]error
+	if !opts.Proxy && !ctx.Bool("no-precheck") {
+		unreachable = precheckHosts(interrupt, git, cfg, reqs)
+	}
+	budget := newRunBudget(ctx.Duration("deadline"))
+	var rec *runManifest
+	if ctx.IsSet("record") {
+		rec = newRunManifest(ctx, args)
+	}
+
+	if batch {
+		defer shareSSHConnections(git)()
+		failed := false
+		var outcomes []outcome
+		resolveAligned(interrupt, budget, git, cfg, opts, reqs, jobs, unreachable, func(o outcome) {
+			if ordered {
+				outcomes = append(outcomes, o)
+			} else {
+				writeBatchResult(out, o.req, o.r, o.err)
+			}
+			rec.add(o.req, o.r, o.err)
+			failed = failed || o.err != nil
+		})
+		sortOutcomes(outcomes, sortBy, groupByHost)
+		for _, o := range outcomes {
+			writeBatchResult(out, o.req, o.r, o.err)
+		}
+		if err = rec.write(ctx.String("record"), nil); err != nil {
+			return cli.Exit(trf("Could not write run manifest: %s", err), 1)
+		}
+		if interrupt.Err() != nil {
+			return cli.Exit(tr("Interrupted"), 130)
+		}
+		if failed {
+			return cli.Exit("", 1)
+		}
+		return nil
+	}
+
+	errOut := io.Writer(out)
+	if machineFormats[formatName] {
+		errOut = os.Stderr
+	} else {
+		_, _ = fmt.Fprintln(out)
+	}
+
+	// Formats rendering each resolution on its own are printed as
+	// repositories complete; the others once all of them did.
+	outOpts := outputOptions{
+		Shell:     ctx.String("shell"),
+		EnvPrefix: ctx.String("env-prefix"),
+		EnvKey:    ctx.String("env-key"),
+	}
+	var resolved []outcome
+	var results []string
+	errorList := map[string]string{}
+	emit := func(rs []resolution) error {
+		lines, err := format(rs, outOpts)
+		if err != nil {
+			return err
+		}
+		for _, l := range lines {
+			_, _ = fmt.Fprintln(out, l)
+		}
+		results = append(results, lines...)
+		return nil
+	}
+
+	sumdb := &checksummer{cacheDir: opts.CacheDir}
+	sums := map[string][]string{}
+	resolveAligned(interrupt, budget, git, cfg, opts, reqs, jobs, unreachable, func(o outcome) {
+		printWarnings(os.Stderr, o.r)
+		if o.err == nil && withSum {
+			g := *git
+			g.timings = o.r.Timings
+			if sums[o.req.Input], o.err = sumdb.sums(interrupt, &g, o.r.Path, o.r.Version); o.err != nil {
+				o.err = fmt.Errorf("could not obtain the checksums of %s %s: %w", o.r.Path, o.r.Version, o.err)
+			}
+		}
+		if ctx.Bool("explain") {
+			printExplain(os.Stderr, o.req.Input, o.r)
+		}
+		rec.add(o.req, o.r, o.err)
+		if o.err == nil && streamedFormats[formatName] && !ordered {
+			o.err = emit([]resolution{o.r})
+		}
+		if o.err != nil {
+			errorList[o.req.Input] = o.err.Error()
+		} else {
+			resolved = append(resolved, o)
+		}
+	})
+
+	if (!streamedFormats[formatName] || ordered) && len(resolved) > 0 {
+		sortOutcomes(resolved, sortBy, groupByHost)
+		// Only go.mod syntax has room for group headers; other
+		// formats are just ordered by host.
+		var groups [][]resolution
+		for i, o := range resolved {
+			if i == 0 || (groupByHost && formatName == "require" && o.host() != resolved[i-1].host()) {
+				groups = append(groups, nil)
+			}
+			groups[len(groups)-1] = append(groups[len(groups)-1], o.r)
+		}
+		for i, g := range groups {
+			if groupByHost && formatName == "require" {
+				if i > 0 {
+					_, _ = fmt.Fprintln(out)
+				}
+				host, _, _ := strings.Cut(g[0].Path, "/")
+				_, _ = fmt.Fprintf(out, "// %s\n", host)
+			}
+			if err = emit(g); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+		}
+	}
+
+	if withSum && len(resolved) > 0 {
+		_, _ = fmt.Fprintln(out)
+		for _, o := range resolved {
+			for _, l := range sums[o.req.Input] {
+				_, _ = fmt.Fprintln(out, l)
+			}
+		}
+	}
+
+	if err = rec.write(ctx.String("record"), results); err != nil {
+		return cli.Exit(trf("Could not write run manifest: %s", err), 1)
+	}
+
+	if len(errorList) > 0 {
+		_, _ = fmt.Fprintln(errOut)
+		_, _ = fmt.Fprintln(errOut, tr("The following errors were found:"))
+		// Errors are listed in the order repositories were given.
+		printed := map[string]bool{}
+		for _, req := range reqs {
+			if err, ok := errorList[req.Input]; ok && !printed[req.Input] {
+				_, _ = fmt.Fprintf(errOut, "  %s: %s\n", req.Input, err)
+				printed[req.Input] = true
+			}
+		}
+		_, _ = fmt.Fprintln(errOut)
+	}
+
+	if interrupt.Err() != nil {
+		return cli.Exit(tr("Interrupted"), 130)
+	}
+	if len(errorList) > 0 {
+		return cli.Exit(tr("One or more repositories could not be processed"), 1)
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/heyvito/go-require-generator/pkg/resolver"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"strings"
+)
+
+var retractCommand = &cli.Command{
+	Name:      "retract",
+	Usage:     "Adds retract directives to the current module's go.mod",
+	ArgsUsage: "version|[low,high] [...]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "reason",
+			Usage: "Rationale recorded as a comment above each retract directive",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() == 0 {
+			return cli.ShowSubcommandHelp(ctx)
+		}
+
+		intervals := make([]modfile.VersionInterval, 0, ctx.NArg())
+		for _, v := range ctx.Args().Slice() {
+			vi, err := parseVersionInterval(v)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			intervals = append(intervals, vi)
+		}
+
+		path, err := findGoMod(".")
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		f, err := readGoMod(path)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+
+		for _, vi := range intervals {
+			if isRetracted(f, vi) {
+				fmt.Printf(tr("%s is already retracted\n"), formatVersionInterval(vi))
+				continue
+			}
+			if err = f.AddRetract(vi, ctx.String("reason")); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			fmt.Printf(tr("Retracted %s\n"), formatVersionInterval(vi))
+		}
+
+		if err = writeGoMod(path, f); err != nil {
+			return cli.Exit(trf("Could not write %s: %s", path, err), 1)
+		}
+		return nil
+	},
+}
+
+// parseVersionInterval parses a single version or a [low,high] interval, as
+// written in go.mod retract directives.
+func parseVersionInterval(v string) (modfile.VersionInterval, error) {
+	low, high := v, v
+	if strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]") {
+		var ok bool
+		low, high, ok = strings.Cut(v[1:len(v)-1], ",")
+		if !ok {
+			return modfile.VersionInterval{}, fmt.Errorf("invalid version interval %s", v)
+		}
+		low, high = strings.TrimSpace(low), strings.TrimSpace(high)
+	}
+
+	for _, s := range []string{low, high} {
+		if !semver.IsValid(s) || semver.Canonical(s) != s {
+			return modfile.VersionInterval{}, fmt.Errorf("%s is not a canonical semantic version", s)
+		}
+	}
+	if semver.Compare(low, high) > 0 {
+		return modfile.VersionInterval{}, fmt.Errorf("invalid version interval %s: %s is greater than %s", v, low, high)
+	}
+	return modfile.VersionInterval{Low: low, High: high}, nil
+}
+
+func formatVersionInterval(vi modfile.VersionInterval) string {
+	if vi.Low == vi.High {
+		return vi.Low
+	}
+	return fmt.Sprintf("[%s, %s]", vi.Low, vi.High)
+}
+
+func isRetracted(f *modfile.File, vi modfile.VersionInterval) bool {
+	for _, r := range f.Retract {
+		if r.VersionInterval == vi {
+			return true
+		}
+	}
+	return false
+}
+
+// moduleRetractions returns the retract directives of the module cloned in s,
+// which are read from the go.mod of its latest version, as the go command
+// does, whatever version is being selected. A go.mod that cannot be parsed
+// retracts nothing.
+func moduleRetractions(ctx context.Context, s *repoSession, path string) ([]*modfile.Retract, error) {
+	if s.retractionsRead {
+		return s.retractions, nil
+	}
+	tags, err := s.remoteTags(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing tags: %w", err)
+	}
+	var versions []remoteTag
+	for _, t := range tags {
+		if resolver.IsModuleVersion(t.Name) {
+			versions = append(versions, t)
+		}
+	}
+	latest, _, ok, err := resolver.LatestModuleVersion(path, versions, preFallback, moduleFileOf(ctx, s))
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		file, data, err := moduleFileAt(ctx, s, resolution{Path: path, Version: latest.Name}, s.subdir)
+		if err != nil {
+			return nil, err
+		}
+		if data != nil {
+			if f, err := modfile.ParseLax(file, data, nil); err != nil {
+				s.git.logf("Ignoring retractions of %s: failed parsing %s at %s: %s\n", path, file, latest.Name, err)
+			} else {
+				s.retractions = f.Retract
+			}
+		}
+	}
+	s.retractionsRead = true
+	return s.retractions, nil
+}
+
+// retractedError describes version being retracted by retract, along with
+// its rationale.
+func retractedError(version string, retract *modfile.Retract) error {
+	if retract.Rationale != "" {
+		return fmt.Errorf("%s is retracted: %s", version, strings.TrimSuffix(retract.Rationale, "."))
+	}
+	return fmt.Errorf("%s is retracted", version)
+}
+
+// enforceRetractions makes sure r is not retracted by the module itself. When
+// the latest version is retracted, the newest older version that is not is
+// selected instead, among the tags the latest version could have been picked
+// from. Explicitly requested versions are kept with a warning, as go get
+// does, and pseudo-versions are never retracted.
+func enforceRetractions(ctx context.Context, s *repoSession, policy Policy, opts resolveOptions, req repoRequest, r resolution) (resolution, error) {
+	if s.remoteOnly() || module.IsPseudoVersion(r.Version) {
+		return r, nil
+	}
+	retractions, err := moduleRetractions(ctx, s, r.Path)
+	if err != nil {
+		return resolution{}, err
+	}
+	retract := resolver.Retraction(retractions, r.Version)
+	if retract == nil {
+		return r, nil
+	}
+	retracted := retractedError(r.Version, retract)
+	if req.Ref != "" || opts.Channel != "" || opts.Nightly || r.Note != "" {
+		r.warn(warnRetracted, "%s", retracted)
+		return r, nil
+	}
+
+	candidates, err := olderCandidates(ctx, s, policy, opts, r)
+	if err != nil {
+		return resolution{}, err
+	}
+	if len(candidates) == 0 {
+		return resolution{}, fmt.Errorf("%w; no older version is available", retracted)
+	}
+	res := resolution{Path: r.Path, Version: candidates[0].Name, Warnings: r.Warnings}
+	res.warn(warnRetracted, "%s; selected %s instead", retracted, res.Version)
+	return res, nil
+}
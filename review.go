@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var reviewCommand = &cli.Command{
+	Name:  "review",
+	Usage: "Prints a Markdown report of go.mod changes between a base revision and the working tree",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "base",
+			Usage: "Revision to compare the working tree against",
+			Value: "origin/main",
+		},
+		modfileFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		git, cfg, err := setup(ctx)
+		if err != nil {
+			return err
+		}
+
+		path, err := goModPath(ctx)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		head, err := readGoMod(path)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		base, err := readBaseGoMod(ctx.Context, git, path, ctx.String("base"))
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+
+		changes := diffRequires(base, head)
+		if len(changes) == 0 {
+			fmt.Println(tr("No dependency changes."))
+			return nil
+		}
+
+		opts := resolveOptions{Inspect: true}
+		fmt.Printf(tr("## Dependency review\n\nComparing `%s` against `%s`.\n\n"), filepath.Base(path), ctx.String("base"))
+		fmt.Println(tr("| Module | Change | Version | Latest | License | Vulnerabilities | Last activity |"))
+		fmt.Println("|---|---|---|---|---|---|---|")
+		var removed []requireChange
+		for _, c := range changes {
+			if c.New == "" {
+				removed = append(removed, c)
+				continue
+			}
+			fmt.Println(reviewRow(ctx.Context, git, cfg, opts, c))
+		}
+
+		if len(removed) > 0 {
+			fmt.Print(tr("\n### Removed\n\n"))
+			for _, c := range removed {
+				fmt.Printf("- `%s` %s\n", c.Path, c.Old)
+			}
+		}
+		return nil
+	},
+}
+
+// readBaseGoMod reads the go.mod at path as of rev. A go.mod that did not
+// exist at rev is returned as an empty file, so every requirement appears as
+// added.
+func readBaseGoMod(ctx context.Context, git *gitRunner, path, rev string) (*modfile.File, error) {
+	dir := filepath.Dir(path)
+	root, err := git.run(ctx, dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not inside a git repository", dir)
+	}
+	if _, err = git.run(ctx, dir, nil, "rev-parse", "--verify", "--quiet", rev+"^{commit}"); err != nil {
+		return nil, fmt.Errorf("unknown revision %s", rev)
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := git.run(ctx, dir, nil, "show", rev+":"+filepath.ToSlash(rel))
+	if err != nil {
+		return &modfile.File{}, nil
+	}
+	f, err := modfile.Parse(rev+":"+rel, []byte(data), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing %s at %s: %w", rel, rev, err)
+	}
+	return f, nil
+}
+
+// changedGoMods returns the files among mods, parsed by readGoMod, whose
+// contents differ from the go.mod at the same path as of rev, including
+// those that did not exist then.
+func changedGoMods(ctx context.Context, git *gitRunner, mods []*modfile.File, rev string) ([]*modfile.File, error) {
+	var changed []*modfile.File
+	for _, f := range mods {
+		base, err := readBaseGoMod(ctx, git, f.Syntax.Name, rev)
+		if err != nil {
+			return nil, err
+		}
+		if base.Syntax == nil || !bytes.Equal(modfile.Format(base.Syntax), modfile.Format(f.Syntax)) {
+			changed = append(changed, f)
+		}
+	}
+	return changed, nil
+}
+
+// requireChange describes a requirement that differs between two go.mod
+// files. Old is empty for added modules, and New for removed ones.
+type requireChange struct {
+	Path string
+	Old  string
+	New  string
+}
+
+func (c requireChange) kind() string {
+	switch {
+	case c.Old == "":
+		return "added"
+	case c.New == "":
+		return "removed"
+	case semver.Compare(c.New, c.Old) > 0:
+		return "upgraded"
+	default:
+		return "downgraded"
+	}
+}
+
+// diffRequires returns the requirements that were added, removed or changed
+// from base to head, sorted by module path.
+func diffRequires(base, head *modfile.File) []requireChange {
+	old, cur := requiredVersions(base), requiredVersions(head)
+	var changes []requireChange
+	for p, v := range cur {
+		if old[p] != v {
+			changes = append(changes, requireChange{Path: p, Old: old[p], New: v})
+		}
+	}
+	for p, v := range old {
+		if _, ok := cur[p]; !ok {
+			changes = append(changes, requireChange{Path: p, Old: v})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// reviewRow resolves and inspects the module of c, returning its row in the
+// review table. Data that could not be obtained is shown as a question mark.
+func reviewRow(ctx context.Context, git *gitRunner, cfg *Config, opts resolveOptions, c requireChange) string {
+	version := c.New
+	if c.Old != "" {
+		version = fmt.Sprintf("%s → %s", c.Old, c.New)
+	}
+
+	latest, license, activity := "?", "?", "?"
+	// The module path may carry a /vN suffix that is not part of the
+	// repository path.
+	repoPath, _, _ := module.SplitPathVersion(c.Path)
+	r, err := processRepo(ctx, git, cfg, opts, repoRequest{Input: c.Path, ModulePath: repoPath})
+	if err != nil {
+		git.logf("Could not resolve %s: %s\n", c.Path, err)
+	} else {
+		latest = r.Version
+		if latest == c.New {
+			latest = "✓"
+		}
+		if r.Info.License != "" {
+			license = r.Info.License
+		} else {
+			license = "none found"
+		}
+		if !r.Info.LastCommit.IsZero() {
+			activity = r.Info.LastCommit.Format(time.DateOnly)
+		}
+	}
+
+	vulns := "?"
+	found, err := queryVulnerabilities(ctx, c.Path, c.New)
+	if err != nil {
+		git.logf("Could not query vulnerabilities for %s: %s\n", c.Path, err)
+	} else if len(found) == 0 {
+		vulns = "none"
+	} else {
+		ids := make([]string, len(found))
+		for i, v := range found {
+			ids[i] = v.ID
+		}
+		vulns = "⚠️ " + strings.Join(ids, ", ")
+	}
+
+	return fmt.Sprintf("| `%s` | %s | %s | %s | %s | %s | %s |", c.Path, c.kind(), version, latest, license, vulns, activity)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"golang.org/x/mod/modfile"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChangedGoMods(t *testing.T) {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not available")
+	}
+	dir := filepath.Join(t.TempDir(), "repo")
+	gitCommand(t, "", "init", "--quiet", "--initial-branch=main", dir)
+	write := func(name, data string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a/go.mod", "module example.com/a\n")
+	write("b/go.mod", "module example.com/b\n\nrequire example.com/x v1.0.0\n")
+	gitCommand(t, dir, "add", "-A")
+	gitCommand(t, dir, "commit", "--quiet", "-m", "base")
+
+	// Reformatting a is not a change; c did not exist at the base revision.
+	write("a/go.mod", "module   example.com/a\n\n")
+	write("b/go.mod", "module example.com/b\n\nrequire example.com/x v1.1.0\n")
+	write("c/go.mod", "module example.com/c\n")
+
+	var mods []*modfile.File
+	for _, name := range []string{"a", "b", "c"} {
+		f, err := readGoMod(filepath.Join(dir, name, "go.mod"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		mods = append(mods, f)
+	}
+	git := &gitRunner{path: gitPath}
+
+	changed, err := changedGoMods(context.Background(), git, mods, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range changed {
+		got = append(got, f.Module.Mod.Path)
+	}
+	if strings.Join(got, " ") != "example.com/b example.com/c" {
+		t.Errorf("changedGoMods() = %v, want [example.com/b example.com/c]", got)
+	}
+
+	if _, err = changedGoMods(context.Background(), git, mods, "no-such-branch"); err == nil {
+		t.Error("changedGoMods() with an unknown revision succeeded, want an error")
+	}
+}
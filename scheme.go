@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/mod/semver"
+	"regexp"
+	"strconv"
+)
+
+// calverPattern matches calendar versions such as 2024.05, v24.5.1 or
+// 2024.05.01-2, capturing their numeric components.
+var calverPattern = regexp.MustCompile(`^v?(\d{2}|\d{4})[.-](\d{1,2})(?:[.-](\d{1,2}))?(?:[.-](\d+))?$`)
+
+// versionScheme orders the tags of repositories not following semver. Tags
+// are compared by the numeric values of the pattern's capture groups, in
+// order.
+type versionScheme struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// parseVersionScheme parses a --version-scheme value: semver, calver, or a
+// regular expression with at least one capture group. semver yields nil, as
+// it is handled by the default resolution.
+func parseVersionScheme(v string) (*versionScheme, error) {
+	switch v {
+	case "", "semver":
+		return nil, nil
+	case "calver":
+		return &versionScheme{name: "calver", pattern: calverPattern}, nil
+	}
+	re, err := regexp.Compile(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version scheme %q: expected semver, calver or a regular expression: %w", v, err)
+	}
+	if re.NumSubexp() == 0 {
+		return nil, fmt.Errorf("version scheme %q must capture the numeric parts of the version in groups", v)
+	}
+	return &versionScheme{name: v, pattern: re}, nil
+}
+
+// key returns the components tag is ordered by, and false when tag does not
+// follow the scheme.
+func (s *versionScheme) key(tag string) ([]int, bool) {
+	m := s.pattern.FindStringSubmatch(tag)
+	if m == nil {
+		return nil, false
+	}
+	key := make([]int, 0, len(m)-1)
+	for _, g := range m[1:] {
+		if g == "" {
+			key = append(key, 0)
+			continue
+		}
+		n, err := strconv.Atoi(g)
+		if err != nil {
+			return nil, false
+		}
+		key = append(key, n)
+	}
+	return key, true
+}
+
+// latest returns the newest tag following the scheme.
+func (s *versionScheme) latest(tags []remoteTag) (remoteTag, bool) {
+	var best remoteTag
+	var bestKey []int
+	for _, t := range tags {
+		k, ok := s.key(t.Name)
+		if ok && (bestKey == nil || compareKeys(k, bestKey) > 0) {
+			best, bestKey = t, k
+		}
+	}
+	return best, bestKey != nil
+}
+
+func compareKeys(a, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(a) - len(b)
+}
+
+// selectSchemeVersion picks the newest tag following scheme. Tags that are
+// already canonical semver versions are emitted as they are; any other tag is
+// mapped to the pseudo-version of the commit it points to, noting the tag on
+// the require line.
+func selectSchemeVersion(ctx context.Context, s *repoSession, path string, scheme *versionScheme) (resolution, error) {
+	tags, err := s.remoteTags(ctx)
+	if err != nil {
+		return resolution{}, fmt.Errorf("failed listing tags: %w", err)
+	}
+	tag, ok := scheme.latest(tags)
+	if !ok {
+		return resolution{}, fmt.Errorf("no tags follow the %s version scheme", scheme.name)
+	}
+	if semver.IsValid(tag.Name) && semver.Canonical(tag.Name) == tag.Name {
+		return resolution{Path: path, Version: tag.Name}, nil
+	}
+
+	full, err := fetchCommit(ctx, s, tag.Commit)
+	if err != nil {
+		return resolution{}, err
+	}
+	version, err := pseudoVersion(ctx, s, path, full)
+	if err != nil {
+		return resolution{}, err
+	}
+	return resolution{Path: path, Version: version, Note: tag.Name}, nil
+}
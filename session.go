@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/heyvito/go-require-generator/pkg/resolver"
+	"golang.org/x/mod/modfile"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errNoTempDir is returned when no writable directory is available to hold
+// clones.
+var errNoTempDir = errors.New("no writable temporary directory")
+
+// fallbackTempDirs are tried, in order, when the default temporary directory
+// is not writable. /dev/shm is memory-backed on most Linux systems and often
+// remains writable in locked-down containers.
+var fallbackTempDirs = []string{"/dev/shm"}
+
+// tempDir creates a temporary directory for a clone, falling back to
+// fallbackTempDirs when TMPDIR cannot be written to.
+func tempDir() (string, error) {
+	dir, err := os.MkdirTemp("", "")
+	if err == nil {
+		return dir, nil
+	}
+	for _, base := range fallbackTempDirs {
+		if d, ferr := os.MkdirTemp(base, ""); ferr == nil {
+			return d, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %w", errNoTempDir, err)
+}
+
+// repoSession is a single repository, shared by every step that needs it
+// while resolving a request. The repository is only queried through
+// ls-remote until a step needs its objects. Steps reading a given commit,
+// such as the go.mod of a tag, only fetch that commit into an empty
+// repository; the repository is shallowly cloned once a step needs the
+// default branch. Remote state that is expensive to obtain, such as the
+// advertised tags and the complete history, is fetched at most once per
+// session.
+type repoSession struct {
+	git *gitRunner
+	// remote is the URL the repository is queried and cloned from.
+	remote string
+	// dir is the temporary directory holding the bare repository in its
+	// "repo" subdirectory. It is empty when no temporary directory is
+	// available, in which case the repository can never be cloned.
+	dir string
+	// initialized is set once dir holds a repository commits can be
+	// fetched into, and cloned once it holds the default branch as well.
+	initialized bool
+	cloned      bool
+	// location tells where the repository was found.
+	location repoLocation
+	// subdir is the directory of the repository the requested path refers
+	// to, empty for the repository root.
+	subdir string
+	// tagPrefix is the prefix of the tags of the module, such as "sub/" for
+	// a module in the sub directory of a monorepo tagged as sub/v1.2.3. It is
+	// known once the tags are listed.
+	tagPrefix string
+
+	tags       []remoteTag
+	tagsErr    error
+	tagsListed bool
+	// duplicateTags maps the tags kept by remoteTags to the tags dropped
+	// for only differing from them in case or v prefix.
+	duplicateTags map[string][]remoteTag
+	// complete is set once the full history has been fetched.
+	complete bool
+	// retractions holds the retract directives of the module's latest
+	// version, once read by moduleRetractions.
+	retractions     []*modfile.Retract
+	retractionsRead bool
+}
+
+// openSession locates the repository req refers to and reserves a temporary
+// directory to clone it into, when needed. The session must be closed to
+// remove it. When no temporary directory can be created, a remote-only
+// session is returned instead.
+func openSession(ctx context.Context, git *gitRunner, cfg *Config, req repoRequest) (*repoSession, error) {
+	var loc repoLocation
+	err := git.stage(ctx, "locate", func(ctx context.Context) (err error) {
+		loc, err = locateRequest(ctx, git, cfg, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s := &repoSession{git: git, remote: loc.URL, location: loc}
+	s.subdir = moduleSubdir(req, loc.Root)
+	s.dir, err = tempDir()
+	if errors.Is(err, errNoTempDir) {
+		git.logf("%s; querying the remote without cloning\n", err)
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ensureRepo makes sure dir holds a bare repository with the remote as its
+// origin, initializing an empty one unless the repository was cloned, so
+// single commits can be fetched without cloning.
+func (s *repoSession) ensureRepo(ctx context.Context) error {
+	if s.initialized {
+		return nil
+	}
+	if s.remoteOnly() {
+		return s.errRemoteOnly("fetching commits")
+	}
+	if _, err := s.git.run(ctx, s.dir, nil, "init", "--quiet", "--bare", "repo"); err != nil {
+		return fmt.Errorf("failed initializing a repository: %w", err)
+	}
+	if _, err := s.git.run(ctx, filepath.Join(s.dir, "repo"), nil, "remote", "add", "origin", s.remote); err != nil {
+		return fmt.Errorf("failed initializing a repository: %w", err)
+	}
+	s.initialized = true
+	return nil
+}
+
+// ensureClone shallowly clones the repository, unless it already was. When
+// commits were already fetched into an empty repository, the default
+// branch's HEAD is fetched next to them instead.
+func (s *repoSession) ensureClone(ctx context.Context) error {
+	if s.cloned {
+		return nil
+	}
+	if s.remoteOnly() {
+		return s.errRemoteOnly("cloning")
+	}
+	err := s.git.stage(ctx, "clone", func(ctx context.Context) error {
+		if s.initialized {
+			return fetchHead(ctx, s.git, s.repo())
+		}
+		return cloneRepo(ctx, s.git, s.remote, s.dir, true)
+	})
+	if err != nil {
+		return fmt.Errorf("failed clonning %s: %w", s.remote, gitFailure(err))
+	}
+	s.initialized, s.cloned = true, true
+	return nil
+}
+
+// remoteOnly reports whether s can never be cloned, and can therefore only
+// answer questions about advertised refs.
+func (s *repoSession) remoteOnly() bool {
+	return s.dir == ""
+}
+
+// errRemoteOnly builds the error returned by operations that need a clone
+// when running in a remote-only session.
+func (s *repoSession) errRemoteOnly(what string) error {
+	return fmt.Errorf("%s requires a clone, but %w is available", what, errNoTempDir)
+}
+
+// sourceHost returns the host the repository is fetched from, after any
+// url.<base>.insteadOf rewrites configured in git. Repositories on the local
+// filesystem are reported as fetched from localhost.
+func (s *repoSession) sourceHost(ctx context.Context) (string, error) {
+	u, err := s.git.run(ctx, "", nil, "ls-remote", "--get-url", s.remote)
+	if err != nil {
+		return "", fmt.Errorf("failed determining the repository URL: %w", err)
+	}
+	return urlHost(u), nil
+}
+
+func (s *repoSession) close() {
+	if s.dir != "" {
+		_ = os.RemoveAll(s.dir)
+	}
+}
+
+// repo returns the path of the bare repository, or an empty string when it
+// was neither cloned nor initialized.
+func (s *repoSession) repo() string {
+	if !s.initialized {
+		return ""
+	}
+	return filepath.Join(s.dir, "repo")
+}
+
+// remoteTags returns the tags of the module advertised by the repository,
+// listing them on first use. For modules tagged with a prefix, only tags
+// carrying it are returned, named after the version they stand for. Tags
+// only differing in case or v prefix are reduced to one, as dedupeTags
+// describes.
+func (s *repoSession) remoteTags(ctx context.Context) ([]remoteTag, error) {
+	if !s.tagsListed {
+		var tags []remoteTag
+		s.tagsErr = s.git.stage(ctx, "tags", func(ctx context.Context) (err error) {
+			tags, err = listRemoteTags(ctx, s.git, "", s.remote)
+			return err
+		})
+		s.tagPrefix = resolver.TagPrefix(tags, s.subdir)
+		if s.tagPrefix != "" {
+			s.git.logf("Using tags prefixed with %s\n", s.tagPrefix)
+		}
+		for _, t := range tags {
+			if name, ok := strings.CutPrefix(t.Name, s.tagPrefix); ok {
+				t.Name = name
+				s.tags = append(s.tags, t)
+			}
+		}
+		s.tags, s.duplicateTags = dedupeTags(s.tags)
+		s.tagsListed = true
+	}
+	return s.tags, s.tagsErr
+}
+
+// tagRef returns the full reference of the tag of the module named name by
+// remoteTags.
+func (s *repoSession) tagRef(ctx context.Context, name string) string {
+	_, _ = s.remoteTags(ctx)
+	return "refs/tags/" + s.tagPrefix + name
+}
+
+// lookupRef finds the tag or branch named by req.Ref through the advertised
+// refs, without cloning. Tags are looked up among the module's tags, by the
+// names remoteTags gives them, and version queries such as v1.2 or <v1.3.0
+// select one of them as go get would. For branches, the returned remoteTag
+// has no name.
+func (s *repoSession) lookupRef(ctx context.Context, req repoRequest) (remoteTag, error) {
+	if req.RefKind != refBranch {
+		tags, err := s.remoteTags(ctx)
+		if err != nil {
+			return remoteTag{}, fmt.Errorf("failed listing tags: %w", err)
+		}
+		if req.RefKind == refAny && isVersionQuery(req.Ref) {
+			return matchVersionQuery(tags, req.Ref)
+		}
+		for _, t := range tags {
+			if t.Name == req.Ref {
+				return t, nil
+			}
+		}
+		if req.RefKind == refTag {
+			return remoteTag{}, fmt.Errorf("no tag named %s", req.Ref)
+		}
+	}
+
+	out, err := s.git.run(ctx, "", nil, "ls-remote", "--heads", s.remote, "refs/heads/"+req.Ref)
+	if err != nil {
+		return remoteTag{}, fmt.Errorf("failed listing branches: %w", err)
+	}
+	sha, _, ok := strings.Cut(out, "\t")
+	if !ok {
+		if req.RefKind == refBranch {
+			return remoteTag{}, fmt.Errorf("no branch named %s", req.Ref)
+		}
+		return remoteTag{}, fmt.Errorf("no tag or branch named %s", req.Ref)
+	}
+	return remoteTag{Commit: sha}, nil
+}
+
+// fetchHistory turns the shallow clone into a complete one, including every
+// branch and tag. Subsequent calls do nothing.
+func (s *repoSession) fetchHistory(ctx context.Context) error {
+	if s.complete {
+		return nil
+	}
+	if s.remoteOnly() {
+		return s.errRemoteOnly("fetching the repository history")
+	}
+	if err := s.ensureClone(ctx); err != nil {
+		return err
+	}
+	err := s.git.stage(ctx, "history", func(ctx context.Context) error {
+		return fetchHistory(ctx, s.git, s.repo())
+	})
+	if err != nil {
+		return err
+	}
+	s.complete = true
+	return nil
+}
+
+// urlHost returns the host of a git URL, which may use the scp-like
+// [user@]host:path syntax.
+func urlHost(raw string) string {
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return ""
+		}
+		if u.Scheme == "file" {
+			return "localhost"
+		}
+		return u.Hostname()
+	}
+	host, _, ok := strings.Cut(raw, ":")
+	if !ok || strings.ContainsAny(host, "/\\") {
+		// A plain path.
+		return "localhost"
+	}
+	if _, h, ok := strings.Cut(host, "@"); ok {
+		host = h
+	}
+	return host
+}
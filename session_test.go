@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitCommand runs git in dir for a test, failing it on errors.
+func gitCommand(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "tag.gpgSign=false", "-c", "commit.gpgSign=false"}, args...)...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s: %s: %s", strings.Join(args, " "), err, out)
+	}
+}
+
+// commitFiles commits files, mapping names to contents, to the repository
+// at dir.
+func commitFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		gitCommand(t, dir, "add", name)
+	}
+	gitCommand(t, dir, "commit", "--quiet", "--allow-empty", "-m", "commit")
+}
+
+func TestProcessRepoWithoutClone(t *testing.T) {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not available")
+	}
+	dir := filepath.Join(t.TempDir(), "repo")
+	gitCommand(t, "", "init", "--quiet", "--initial-branch=main", dir)
+	commitFiles(t, dir, map[string]string{"go.mod": "module example.com/repo\n", "a.go": "package a\n"})
+	gitCommand(t, dir, "tag", "-a", "-m", "release", "v1.0.0")
+	commitFiles(t, dir, nil)
+	gitCommand(t, dir, "tag", "v1.1.0")
+	// The latest version retracts itself along with v1.1.0, so reading its
+	// go.mod is required to select v1.0.0.
+	commitFiles(t, dir, map[string]string{"go.mod": "module example.com/repo\n\nretract [v1.1.0, v1.2.0]\n"})
+	gitCommand(t, dir, "tag", "v1.2.0")
+	commitFiles(t, dir, nil)
+
+	var transcript strings.Builder
+	git := &gitRunner{path: gitPath, logFile: &transcript}
+	req := repoRequest{Input: dir, ModulePath: "example.com/repo", LocalURL: "file://" + filepath.ToSlash(dir)}
+	r, err := processRepo(context.Background(), git, &Config{}, resolveOptions{WarnUnsigned: true}, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Path != "example.com/repo" || r.Version != "v1.0.0" {
+		t.Fatalf("processRepo() = %s %s, want example.com/repo v1.0.0", r.Path, r.Version)
+	}
+	// The signature of the annotated tag is checked from its tag object.
+	if len(r.Warnings) != 2 || r.Warnings[0].Code != warnRetracted || r.Warnings[1].Code != warnUnsignedTag {
+		t.Errorf("processRepo() warned %+v, want retraction and unsigned tag warnings", r.Warnings)
+	}
+	for _, line := range strings.Split(transcript.String(), "\n") {
+		if strings.Contains(line, "Executing") && strings.Contains(line, " clone ") {
+			t.Errorf("processRepo() cloned the repository: %s", line)
+		}
+	}
+
+	// Signatures are not checked unless asked for.
+	r, err = processRepo(context.Background(), git, &Config{}, resolveOptions{}, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Warnings) != 1 || r.Warnings[0].Code != warnRetracted {
+		t.Errorf("processRepo() warned %+v, want a retraction warning", r.Warnings)
+	}
+}
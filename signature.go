@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/mod/module"
+	"regexp"
+	"strings"
+)
+
+// sshGoodSignature matches the line git prints for a valid SSH signature,
+// capturing the key's fingerprint.
+var sshGoodSignature = regexp.MustCompile(`^Good "git" signature .* key (SHA256:\S+)`)
+
+// signatureFingerprints verifies the signature of rev, a tag when tag is set
+// or a commit otherwise, and returns the fingerprints of the key that made
+// it. Keys are looked up through the user's git and gpg configuration, so
+// signers must be trusted there for their signatures to be considered valid.
+func signatureFingerprints(ctx context.Context, s *repoSession, rev string, tag bool) ([]string, error) {
+	cmd := "verify-commit"
+	if tag {
+		cmd = "verify-tag"
+	}
+	var lines []string
+	_, err := s.git.runWatched(ctx, s.repo(), nil, func(line string) error {
+		lines = append(lines, line)
+		return nil
+	}, cmd, "--raw", rev)
+
+	var fingerprints []string
+	for _, l := range lines {
+		if fields := strings.Fields(l); len(fields) > 2 && fields[0] == "[GNUPG:]" && fields[1] == "VALIDSIG" {
+			// The primary key's fingerprint follows the signing key's when
+			// the signature was made by a subkey.
+			fingerprints = append(fingerprints, fields[2])
+			if len(fields) > 11 {
+				fingerprints = append(fingerprints, fields[11])
+			}
+		}
+		if m := sshGoodSignature.FindStringSubmatch(l); m != nil {
+			fingerprints = append(fingerprints, m[1])
+		}
+	}
+	if err != nil || len(fingerprints) == 0 {
+		reason := "no valid signature found"
+		if len(lines) > 0 && !strings.HasPrefix(lines[len(lines)-1], "[GNUPG:]") {
+			reason = lines[len(lines)-1]
+		}
+		return nil, fmt.Errorf("%s", reason)
+	}
+	return fingerprints, nil
+}
+
+// normalizeFingerprint makes OpenPGP fingerprints comparable regardless of
+// case and grouping. SSH fingerprints are base64 and kept as they are.
+func normalizeFingerprint(f string) string {
+	if strings.HasPrefix(f, "SHA256:") {
+		return f
+	}
+	return strings.ToUpper(strings.ReplaceAll(f, " ", ""))
+}
+
+// fingerprintAllowed reports whether any of the signer's fingerprints is
+// listed in allowed. OpenPGP entries may also be long key IDs, which match
+// the end of the fingerprint.
+func fingerprintAllowed(fingerprints, allowed []string) bool {
+	for _, f := range fingerprints {
+		f = normalizeFingerprint(f)
+		for _, a := range allowed {
+			a = normalizeFingerprint(a)
+			if f == a || (!strings.HasPrefix(a, "SHA256:") && len(a) >= 16 && strings.HasSuffix(f, a)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// verifySignature requires the tag of r.Version, or the commit of a
+// pseudo-version, to carry a valid signature, made by a key allowed by every
+// signature rule of policy applying to r.Path.
+func verifySignature(ctx context.Context, s *repoSession, policy Policy, r resolution) error {
+	if s.remoteOnly() {
+		return s.errRemoteOnly("verifying signatures")
+	}
+	if err := s.ensureClone(ctx); err != nil {
+		return err
+	}
+
+	rev, tag := s.tagRef(ctx, versionTag(r.Version)), true
+	if module.IsPseudoVersion(r.Version) {
+		sha, err := module.PseudoVersionRev(r.Version)
+		if err != nil {
+			return err
+		}
+		rev, tag = sha, false
+	} else if _, err := s.git.run(ctx, s.repo(), nil, "fetch", "--depth=1", "origin", "+"+rev+":"+rev); err != nil {
+		return fmt.Errorf("failed fetching tag %s: %w", r.Version, err)
+	}
+
+	fingerprints, err := signatureFingerprints(ctx, s, rev, tag)
+	if err != nil {
+		return fmt.Errorf("signature of %s %s could not be verified: %w", r.Path, r.Version, err)
+	}
+	for _, rule := range policy.signers(r.Path) {
+		if !fingerprintAllowed(fingerprints, rule.Fingerprints) {
+			msg := fmt.Sprintf("%s %s is signed by %s, which is not allowed by the policy", r.Path, r.Version, fingerprints[0])
+			if rule.Reason != "" {
+				msg += " (" + rule.Reason + ")"
+			}
+			return fmt.Errorf("%s", msg)
+		}
+	}
+	s.git.logf("Signature of %s %s made by %s\n", r.Path, r.Version, fingerprints[0])
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// smokeTestFlag lets commands writing go.mod verify that the module still
+// compiles with the new requirements.
+var smokeTestFlag = &cli.StringFlag{
+	Name:  "smoke-test",
+	Usage: "After writing go.mod, runs go `build` or go vet on ./... and restores go.mod and go.sum when it fails",
+}
+
+// validateSmokeTest checks the value of --smoke-test.
+func validateSmokeTest(cmd string) error {
+	if cmd != "" && cmd != "build" && cmd != "vet" {
+		return fmt.Errorf("unknown smoke test %q; use build or vet", cmd)
+	}
+	return nil
+}
+
+// goModBackup holds the contents of a go.mod file and its go.sum, so they
+// can be restored after a change breaks the build.
+type goModBackup struct {
+	path string
+	mod  []byte
+	// sum is nil when the module had no go.sum.
+	sum []byte
+}
+
+func backupGoMod(path string) (*goModBackup, error) {
+	b := &goModBackup{path: path}
+	var err error
+	if b.mod, err = os.ReadFile(path); err != nil {
+		return nil, err
+	}
+	b.sum, err = os.ReadFile(b.sumPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *goModBackup) sumPath() string {
+	return filepath.Join(filepath.Dir(b.path), "go.sum")
+}
+
+// restore writes back the saved go.mod and go.sum, removing a go.sum that
+// did not exist before.
+func (b *goModBackup) restore() error {
+	if err := os.WriteFile(b.path, b.mod, 0o644); err != nil {
+		return err
+	}
+	if b.sum == nil {
+		err := os.Remove(b.sumPath())
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return os.WriteFile(b.sumPath(), b.sum, 0o644)
+}
+
+// smokeTest runs go cmd ./... in the module b was taken from. Missing
+// go.sum entries are added through -mod=mod, and workspaces are ignored so
+// only the module's own requirements are exercised. When the command fails,
+// b is restored and the error carries the command's output.
+func smokeTest(b *goModBackup, cmd string) error {
+	args := []string{cmd, "./..."}
+	if cmd == "build" {
+		// Binaries of main packages are discarded rather than left in the
+		// module.
+		args = []string{cmd, "-o", os.DevNull, "./..."}
+	}
+	c := exec.Command("go", args...)
+	c.Dir = filepath.Dir(b.path)
+	c.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	out, err := c.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if rerr := b.restore(); rerr != nil {
+		return fmt.Errorf("go %s failed, and %s could not be restored: %w", cmd, b.path, rerr)
+	}
+	return fmt.Errorf("go %s failed, so %s was restored:\n%s", cmd, b.path, indent(strings.TrimSpace(string(out))))
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/modfile"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Snippet is a named set of requirements and replacements configured to be
+// added to go.mod files together, such as an organisation's standard stack.
+type Snippet struct {
+	Description string `yaml:"description"`
+	// Requires lists repositories required at their current version, using
+	// the repo[@sha] syntax accepted on the command line.
+	Requires []string `yaml:"requires"`
+	// Replaces lists replace directives to add along with the requirements.
+	Replaces []SnippetReplace `yaml:"replaces"`
+}
+
+// SnippetReplace is a replace directive of a Snippet.
+type SnippetReplace struct {
+	// Old is the replaced module path, optionally followed by @version to
+	// only replace that version.
+	Old string `yaml:"old"`
+	// New is either a local directory, a module path followed by @version,
+	// or a repository whose current version is resolved.
+	New string `yaml:"new"`
+}
+
+func (s Snippet) validate(name string) error {
+	if len(s.Requires) == 0 && len(s.Replaces) == 0 {
+		return fmt.Errorf("snippet %s is empty", name)
+	}
+	for i, r := range s.Replaces {
+		if r.Old == "" || r.New == "" {
+			return fmt.Errorf("replace #%d of snippet %s needs both old and new", i+1, name)
+		}
+	}
+	return nil
+}
+
+var snippetCommand = &cli.Command{
+	Name:      "snippet",
+	Usage:     "Resolves a snippet of requirements and replacements defined in the configuration file",
+	ArgsUsage: "[name]",
+	Description: "Snippets are configured under the snippets key of the configuration file, for example:\n\n" +
+		"  snippets:\n" +
+		"    observability:\n" +
+		"      description: Company logging and metrics stack\n" +
+		"      requires:\n" +
+		"        - github.com/acme/log\n" +
+		"        - github.com/acme/metrics\n" +
+		"      replaces:\n" +
+		"        - old: github.com/upstream/tracing\n" +
+		"          new: github.com/acme/tracing\n\n" +
+		"Without a name, the configured snippets are listed.",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "write",
+			Usage: "Adds the snippet to go.mod instead of printing it",
+		},
+		smokeTestFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		if err := validateSmokeTest(ctx.String("smoke-test")); err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		if ctx.IsSet("smoke-test") && !ctx.Bool("write") {
+			return cli.Exit(tr("--smoke-test requires --write"), 1)
+		}
+		git, cfg, err := setup(ctx)
+		if err != nil {
+			return err
+		}
+		if ctx.NArg() == 0 {
+			listSnippets(cfg)
+			return nil
+		}
+		name := ctx.Args().First()
+		sn, ok := cfg.Snippets[name]
+		if !ok {
+			return cli.Exit(trf("Unknown snippet %q", name), 1)
+		}
+
+		f, err := resolveSnippet(ctx.Context, git, cfg, sn)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+
+		if !ctx.Bool("write") {
+			data, err := f.Format()
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			fmt.Print(string(data))
+			return nil
+		}
+
+		path, err := findGoMod(".")
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		backup, err := backupGoMod(path)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		mod, err := readGoMod(path)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		for _, r := range f.Require {
+			if err = mod.AddRequire(r.Mod.Path, r.Mod.Version); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+		}
+		for _, r := range f.Replace {
+			if err = mod.AddReplace(r.Old.Path, r.Old.Version, r.New.Path, r.New.Version); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+		}
+		if err = writeGoMod(path, mod); err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		if cmd := ctx.String("smoke-test"); cmd != "" {
+			if err = smokeTest(backup, cmd); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+		}
+		fmt.Printf(tr("Added snippet %s to %s\n"), name, path)
+		return nil
+	},
+}
+
+func listSnippets(cfg *Config) {
+	if len(cfg.Snippets) == 0 {
+		fmt.Println(tr("No snippets are configured"))
+		return
+	}
+	names := make([]string, 0, len(cfg.Snippets))
+	for n := range cfg.Snippets {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		if d := cfg.Snippets[n].Description; d != "" {
+			fmt.Printf("%s: %s\n", n, d)
+		} else {
+			fmt.Println(n)
+		}
+	}
+}
+
+// resolveSnippet resolves the current versions of sn's requirements and
+// replacement targets, returning them as the directives of a go.mod file.
+func resolveSnippet(ctx context.Context, git *gitRunner, cfg *Config, sn Snippet) (*modfile.File, error) {
+	f := &modfile.File{Syntax: &modfile.FileSyntax{}}
+	reqs, err := buildRequests(sn.Requires, nil)
+	if err != nil {
+		return nil, err
+	}
+	var errs []string
+	for _, req := range reqs {
+		r, err := processRepo(ctx, git, cfg, resolveOptions{}, req)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("  %s: %s", req.Input, err))
+			continue
+		}
+		printWarnings(os.Stderr, r)
+		f.AddNewRequire(r.Path, r.Version, false)
+	}
+
+	for _, rep := range sn.Replaces {
+		oldPath, oldVersion, _ := strings.Cut(rep.Old, "@")
+		newPath, newVersion, hasVersion := strings.Cut(rep.New, "@")
+		if !modfile.IsDirectoryPath(rep.New) && !hasVersion {
+			r, err := processRepo(ctx, git, cfg, resolveOptions{}, repoRequest{Input: rep.New, ModulePath: strings.Trim(rep.New, "/")})
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("  %s: %s", rep.New, err))
+				continue
+			}
+			printWarnings(os.Stderr, r)
+			newPath, newVersion = r.Path, r.Version
+		} else if modfile.IsDirectoryPath(rep.New) {
+			newPath, newVersion = rep.New, ""
+		}
+		if err = f.AddReplace(oldPath, oldVersion, newPath, newVersion); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("the following errors were found:\n%s", strings.Join(errs, "\n"))
+	}
+	return f, nil
+}
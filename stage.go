@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errStageTimedOut is wrapped by the errors of stages cancelled by their
+// --stage-timeout.
+var errStageTimedOut = errors.New("timed out")
+
+// resolutionStages lists the stages of a resolution that can be given a
+// timeout, in the order they usually run.
+var resolutionStages = []string{
+	// locate finds the repository holding the requested path.
+	"locate",
+	// tags lists the tags the repository advertises.
+	"tags",
+	// clone makes the shallow clone of the repository.
+	"clone",
+	// history completes the clone with every branch and tag.
+	"history",
+	// proxy queries the module proxies, with --proxy or --cross-check.
+	"proxy",
+	// checksum obtains go.sum lines, with --with-sum.
+	"checksum",
+}
+
+// parseStageTimeouts parses --stage-timeout values of the form
+// stage=duration.
+func parseStageTimeouts(values []string) (map[string]time.Duration, error) {
+	timeouts := map[string]time.Duration{}
+	for _, v := range values {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid stage timeout %q: expected stage=duration, such as clone=2m", v)
+		}
+		known := false
+		for _, s := range resolutionStages {
+			known = known || s == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown stage %q; stages are %s", name, strings.Join(resolutionStages, ", "))
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q for stage %s", value, name)
+		}
+		timeouts[name] = d
+	}
+	return timeouts, nil
+}
+
+// stageTiming is the time spent in a stage while resolving a repository.
+type stageTiming struct {
+	Stage    string
+	Duration time.Duration
+	// Runs is the number of times the stage ran, such as when a
+	// repository is opened again to align a group.
+	Runs int
+}
+
+// stageTimings collects the time spent in each stage of a resolution. It is
+// shared by the runners derived for the resolution, and is safe for
+// concurrent use.
+type stageTimings struct {
+	mu     sync.Mutex
+	start  time.Time
+	stages []stageTiming
+}
+
+func newStageTimings() *stageTimings {
+	return &stageTimings{start: time.Now()}
+}
+
+func (t *stageTimings) add(stage string, d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.stages {
+		if t.stages[i].Stage == stage {
+			t.stages[i].Duration += d
+			t.stages[i].Runs++
+			return
+		}
+	}
+	t.stages = append(t.stages, stageTiming{Stage: stage, Duration: d, Runs: 1})
+}
+
+// list returns the recorded stages in the order they first ran, along with
+// the time elapsed since t was created.
+func (t *stageTimings) list() ([]stageTiming, time.Duration) {
+	if t == nil {
+		return nil, 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]stageTiming(nil), t.stages...), time.Since(t.start)
+}
+
+// stage runs fn as the named stage of a resolution, with a context derived
+// from ctx that is cancelled once the stage's timeout, if any, expires. The
+// time fn takes is recorded for --explain.
+func (g *gitRunner) stage(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	if d := g.stageTimeouts[name]; d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, d, fmt.Errorf("%s stage %w after %s", name, errStageTimedOut, d))
+		defer cancel()
+	}
+	start := time.Now()
+	err := fn(ctx)
+	g.timings.add(name, time.Since(start))
+	if cause := contextErr(ctx); cause != nil && err != nil {
+		return cause
+	}
+	return err
+}
+
+// contextErr returns the error reported for work cancelled through ctx:
+// errInterrupted when grg was interrupted, errTimedOut once the share of
+// --deadline was used, and the stage's error for stage timeouts. It returns
+// nil while ctx is active.
+func contextErr(ctx context.Context) error {
+	if ctx == nil || ctx.Err() == nil {
+		return nil
+	}
+	switch cause := context.Cause(ctx); {
+	case errors.Is(cause, context.Canceled):
+		return errInterrupted
+	case errors.Is(cause, context.DeadlineExceeded):
+		return errTimedOut
+	default:
+		return cause
+	}
+}
+
+// printExplain writes the stages run while resolving input and their
+// durations to w.
+func printExplain(w io.Writer, input string, r resolution) {
+	if r.Timings == nil {
+		return
+	}
+	stages, total := r.Timings.list()
+	_, _ = fmt.Fprintf(w, tr("explain: %s took %s\n"), input, total.Round(time.Millisecond))
+	for _, s := range stages {
+		runs := ""
+		if s.Runs > 1 {
+			runs = fmt.Sprintf(" (%d runs)", s.Runs)
+		}
+		_, _ = fmt.Fprintf(w, "  %-9s %s%s\n", s.Stage, s.Duration.Round(time.Millisecond), runs)
+	}
+}
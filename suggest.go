@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/xrash/smetrics"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// suggestionThreshold is the minimum Jaro-Winkler similarity for a repository
+// name to be offered as a suggestion.
+const suggestionThreshold = 0.8
+
+// repoLister lists repository names owned by an account on a given host.
+// found is false when the owner itself does not exist.
+type repoLister func(ctx context.Context, owner string) (names []string, found bool, err error)
+
+// repoListers holds the hosts for which suggestions can be made.
+var repoListers = map[string]repoLister{
+	"github.com": listGitHubRepos,
+}
+
+func listGitHubRepos(ctx context.Context, owner string) ([]string, bool, error) {
+	u := fmt.Sprintf("https://api.github.com/users/%s/repos?per_page=100&sort=updated", url.PathEscape(owner))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := apiClient.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer closeBody(res)
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("unexpected status %s listing repositories of %s", res.Status, owner)
+	}
+
+	var repos []struct {
+		Name string `json:"name"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&repos); err != nil {
+		return nil, false, err
+	}
+
+	names := make([]string, len(repos))
+	for i, r := range repos {
+		names[i] = r.Name
+	}
+	return names, true, nil
+}
+
+// ownerVariants returns common misspellings of an owner name, such as
+// swapped dashes and underscores.
+func ownerVariants(owner string) []string {
+	seen := map[string]bool{owner: true}
+	var variants []string
+	for _, v := range []string{
+		strings.ReplaceAll(owner, "_", "-"),
+		strings.ReplaceAll(owner, "-", "_"),
+		strings.ReplaceAll(owner, "-", ""),
+		strings.ReplaceAll(owner, "_", ""),
+	} {
+		if !seen[v] {
+			seen[v] = true
+			variants = append(variants, v)
+		}
+	}
+	return variants
+}
+
+// suggestRepos queries the host API for repositories with names similar to
+// the one at path, returning up to three module-like paths. Errors are
+// reported through git's verbose log and otherwise ignored, as suggestions are
+// best-effort.
+func suggestRepos(ctx context.Context, git *gitRunner, host, path string) []string {
+	list, ok := repoListers[host]
+	if !ok {
+		return nil
+	}
+	segments := strings.Split(path, "/")
+	if len(segments) < 2 {
+		return nil
+	}
+	owner, name := segments[0], strings.ToLower(segments[1])
+
+	type scored struct {
+		path  string
+		score float64
+	}
+	var found []scored
+
+	owners := append([]string{owner}, ownerVariants(owner)...)
+	for i, o := range owners {
+		names, exists, err := list(ctx, o)
+		if err != nil {
+			git.logf("Could not list repositories for suggestions: %s\n", err)
+			return nil
+		}
+		if !exists {
+			continue
+		}
+		for _, n := range names {
+			score := smetrics.JaroWinkler(name, strings.ToLower(n), 0.7, 4)
+			if score >= suggestionThreshold && (i > 0 || !strings.EqualFold(n, name)) {
+				found = append(found, scored{fmt.Sprintf("%s/%s/%s", host, o, n), score})
+			}
+		}
+		if i == 0 {
+			// The owner exists; its name is very unlikely to be the typo.
+			break
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool { return found[i].score > found[j].score })
+	var result []string
+	for i := 0; i < len(found) && i < 3; i++ {
+		result = append(result, found[i].path)
+	}
+	return result
+}
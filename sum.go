@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/mod/semver"
+	"golang.org/x/mod/sumdb"
+	"golang.org/x/mod/sumdb/note"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// sumGolangOrgKey is the verifier key of sum.golang.org, which the go command
+// knows without it being configured in GOSUMDB.
+const sumGolangOrgKey = "sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ux18htTTAD8OuAn8"
+
+// checksummer obtains the go.sum lines of module versions: from the checksum
+// database configured in GOSUMDB, verifying its proofs, or through go mod
+// download for modules GONOSUMDB or GOPRIVATE leave out of it.
+type checksummer struct {
+	// cacheDir holds the tiles and latest tree of the checksum database,
+	// which are kept in memory when empty.
+	cacheDir string
+
+	once sync.Once
+	ops  *sumDBOps
+	err  error
+}
+
+// sums returns the go.sum lines of path at version, for its content and its
+// go.mod file, as the checksum stage of git's resolution.
+func (c *checksummer) sums(ctx context.Context, git *gitRunner, path, version string) ([]string, error) {
+	var lines []string
+	err := git.stage(ctx, "checksum", func(ctx context.Context) error {
+		if goEnv().bypassesSumDB(path) {
+			var err error
+			lines, err = downloadSums(ctx, git, path, version)
+			return err
+		}
+		c.once.Do(func() { c.ops, c.err = newSumDBOps(goEnv().SumDB, c.cacheDir) })
+		if c.err != nil {
+			return c.err
+		}
+		// Clients are cheap, and one per lookup lets its requests be
+		// cancelled along with the stage.
+		client := sumdb.NewClient(sumDBRemote{sumDBOps: c.ops, ctx: ctx, git: git})
+		for _, v := range []string{version, version + "/go.mod"} {
+			found, err := client.Lookup(path, v)
+			if err != nil {
+				return err
+			}
+			lines = append(lines, found...)
+		}
+		return nil
+	})
+	return lines, err
+}
+
+// newSumDBOps returns the storage of the client of the checksum database
+// described by gosumdb, in the GOSUMDB syntax: a database name the go
+// command knows, or a verifier key, optionally followed by the URL to reach
+// it.
+func newSumDBOps(gosumdb, cacheDir string) (*sumDBOps, error) {
+	fields := strings.Fields(gosumdb)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("invalid GOSUMDB %q", gosumdb)
+	}
+	key, url := fields[0], ""
+	switch key {
+	case "sum.golang.org":
+		key = sumGolangOrgKey
+	case "sum.golang.google.cn":
+		key, url = sumGolangOrgKey, "https://sum.golang.google.cn"
+	}
+	verifier, err := note.NewVerifier(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GOSUMDB %q: %w", gosumdb, err)
+	}
+	if len(fields) == 2 {
+		url = fields[1]
+	} else if url == "" {
+		url = "https://" + verifier.Name()
+	}
+	ops := &sumDBOps{key: key, url: strings.TrimSuffix(url, "/"), mem: map[string][]byte{}}
+	if cacheDir != "" {
+		ops.dir = filepath.Join(cacheDir, "sumdb")
+	}
+	return ops, nil
+}
+
+// sumDBOps provides checksum database clients with grg's cache, shared by
+// every lookup.
+type sumDBOps struct {
+	key string
+	url string
+	// dir holds the configuration and cached tiles, which are kept in mem
+	// when empty.
+	dir string
+
+	mu  sync.Mutex
+	mem map[string][]byte
+}
+
+// sumDBRemote completes sumDBOps with access to the database, through
+// requests made on behalf of git. The requests are cancelled along with
+// ctx, which sumdb.ClientOps has no way to pass.
+type sumDBRemote struct {
+	*sumDBOps
+	ctx context.Context
+	git *gitRunner
+}
+
+func (o sumDBRemote) ReadRemote(path string) ([]byte, error) {
+	u := o.url + path
+	o.git.logf("Fetching %s\n", u)
+	res, err := httpGet(o.ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(res)
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s from %s", res.Status, u)
+	}
+	return io.ReadAll(res.Body)
+}
+
+func (o *sumDBOps) ReadConfig(file string) ([]byte, error) {
+	if file == "key" {
+		return []byte(o.key), nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	data, err := o.read(filepath.Join("config", file))
+	if errors.Is(err, os.ErrNotExist) {
+		// The latest tree is unknown until the first lookup.
+		return nil, nil
+	}
+	return data, err
+}
+
+func (o *sumDBOps) WriteConfig(file string, old, new []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	name := filepath.Join("config", file)
+	cur, err := o.read(name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if !bytes.Equal(cur, old) {
+		return sumdb.ErrWriteConflict
+	}
+	return o.write(name, new)
+}
+
+func (o *sumDBOps) ReadCache(file string) ([]byte, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.read(filepath.Join("cache", file))
+}
+
+func (o *sumDBOps) WriteCache(file string, data []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_ = o.write(filepath.Join("cache", file), data)
+}
+
+func (o sumDBRemote) Log(msg string) {
+	o.git.logf("%s\n", msg)
+}
+
+func (o *sumDBOps) SecurityError(msg string) {
+	warnf("%s", msg)
+}
+
+// read and write access the file name, relative to dir, and must be called
+// with mu held.
+func (o *sumDBOps) read(name string) ([]byte, error) {
+	if o.dir == "" {
+		if data, ok := o.mem[name]; ok {
+			return data, nil
+		}
+		return nil, os.ErrNotExist
+	}
+	return os.ReadFile(filepath.Join(o.dir, name))
+}
+
+func (o *sumDBOps) write(name string, data []byte) error {
+	if o.dir == "" {
+		o.mem[name] = data
+		return nil
+	}
+	return writeFileAtomic(filepath.Join(o.dir, name), data)
+}
+
+// downloadSums obtains the go.sum lines of path at version through go mod
+// download, which computes them from the module itself.
+func downloadSums(ctx context.Context, git *gitRunner, path, version string) ([]string, error) {
+	goPath, err := exec.LookPath("go")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not in the checksum database, and computing its checksums requires the go command", path)
+	}
+	c := exec.CommandContext(ctx, goPath, "mod", "download", "-json", path+"@"+version)
+	// Outside of any module, so the current one does not interfere.
+	c.Dir = os.TempDir()
+	c.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=")
+	git.logf("Running go mod download -json %s@%s\n", path, version)
+	out, err := c.Output()
+	var info struct {
+		Sum      string
+		GoModSum string
+		Error    string
+	}
+	if jerr := json.Unmarshal(out, &info); jerr != nil {
+		if err != nil {
+			return nil, fmt.Errorf("go mod download failed: %w", err)
+		}
+		return nil, jerr
+	}
+	if info.Error != "" {
+		return nil, errors.New(info.Error)
+	}
+	return []string{
+		fmt.Sprintf("%s %s %s", path, version, info.Sum),
+		fmt.Sprintf("%s %s/go.mod %s", path, version, info.GoModSum),
+	}, nil
+}
+
+// addGoSumLines merges lines into the go.sum file at path, creating it when
+// needed, and keeps it sorted as the go command does.
+func addGoSumLines(path string, lines []string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	seen := map[string]bool{}
+	var all []string
+	for _, l := range append(strings.Split(string(data), "\n"), lines...) {
+		if l = strings.TrimSpace(l); l != "" && !seen[l] {
+			seen[l] = true
+			all = append(all, l)
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		a, b := strings.Fields(all[i]), strings.Fields(all[j])
+		if len(a) < 2 || len(b) < 2 {
+			return false
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		va, vb := strings.TrimSuffix(a[1], "/go.mod"), strings.TrimSuffix(b[1], "/go.mod")
+		if c := semver.Compare(va, vb); c != 0 {
+			return c < 0
+		}
+		return a[1] < b[1]
+	})
+	return os.WriteFile(path, []byte(strings.Join(all, "\n")+"\n"), 0o644)
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var tagReleaseCommand = &cli.Command{
+	Name:      "tag-release",
+	Usage:     "Tags a release of the modules of the current repository, prefixing tags of nested modules with their directory, and pushes the tags at once",
+	ArgsUsage: "version",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "modules",
+			Usage: "Directories of the modules to tag, separated by commas",
+			Value: cli.NewStringSlice("./"),
+		},
+		&cli.BoolFlag{
+			Name:  "sign",
+			Usage: "Signs the tags, as git tag -s does",
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Verifies the modules and prints the tags without creating them",
+		},
+		&cli.StringFlag{
+			Name:  "remote",
+			Usage: "Remote the tags are pushed to",
+			Value: "origin",
+		},
+		&cli.BoolFlag{
+			Name:  "no-push",
+			Usage: "Creates the tags locally without pushing them",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() != 1 {
+			return cli.ShowSubcommandHelp(ctx)
+		}
+		version := ctx.Args().First()
+		if !semver.IsValid(version) || semver.Canonical(version) != version {
+			return cli.Exit(trf("%s is not a canonical semantic version", version), 1)
+		}
+
+		git, _, err := setup(ctx)
+		if err != nil {
+			return err
+		}
+		root, err := git.run(ctx.Context, "", nil, "rev-parse", "--show-toplevel")
+		if err != nil {
+			return cli.Exit(trf("Not inside a git repository: %s", err), 1)
+		}
+
+		var releases []moduleRelease
+		for _, dir := range ctx.StringSlice("modules") {
+			m, err := loadModuleRelease(root, dir, version)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			releases = append(releases, m)
+		}
+
+		if problems := checkModuleReleases(ctx.Context, git, root, releases, version); len(problems) > 0 {
+			fmt.Println(tr("Cannot tag the release:"))
+			for _, p := range problems {
+				fmt.Printf("  %s\n", p)
+			}
+			return cli.Exit("", 1)
+		}
+
+		if ctx.Bool("dry-run") {
+			for _, m := range releases {
+				fmt.Printf(tr("Would tag %s as %s\n"), m.Path, m.Tag)
+			}
+			return nil
+		}
+
+		// Every tag is created before any is pushed, and all of them are
+		// pushed at once, so a failure never publishes part of the release.
+		var tags []string
+		for _, m := range releases {
+			args := []string{"tag", "-a", "-m", m.Path + " " + version, m.Tag}
+			if ctx.Bool("sign") {
+				args[1] = "-s"
+			}
+			if _, err = git.run(ctx.Context, root, nil, args...); err != nil {
+				deleteTags(ctx.Context, git, root, tags)
+				return cli.Exit(trf("Could not tag %s: %s", m.Path, err), 1)
+			}
+			tags = append(tags, m.Tag)
+			fmt.Printf(tr("Tagged %s as %s\n"), m.Path, m.Tag)
+		}
+
+		remote := ctx.String("remote")
+		if ctx.Bool("no-push") {
+			fmt.Printf(tr("\nPublish the release with: git push --atomic %s %s\n"), remote, strings.Join(tags, " "))
+			return nil
+		}
+		refs := make([]string, len(tags))
+		for i, t := range tags {
+			refs[i] = "refs/tags/" + t
+		}
+		if _, err = git.run(ctx.Context, root, nil, append([]string{"push", "--atomic", remote}, refs...)...); err != nil {
+			deleteTags(ctx.Context, git, root, tags)
+			return cli.Exit(trf("Could not push the tags to %s, which were deleted locally: %s", remote, err), 1)
+		}
+		fmt.Printf(tr("Pushed %s to %s\n"), strings.Join(tags, " "), remote)
+		return nil
+	},
+}
+
+// deleteTags deletes the local tags of a release that could not be
+// completed, so it can be tagged again.
+func deleteTags(ctx context.Context, git *gitRunner, root string, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	if _, err := git.run(ctx, root, nil, append([]string{"tag", "-d"}, tags...)...); err != nil {
+		warnf(tr("could not delete the tags %s: %s"), strings.Join(tags, " "), err)
+	}
+}
+
+// moduleRelease is a module of the current repository about to be tagged.
+type moduleRelease struct {
+	// Dir is the module's directory, relative to the repository root and
+	// slash-separated.
+	Dir  string
+	Path string
+	Tag  string
+	// Requires maps the modules required by the module to their versions.
+	Requires map[string]string
+}
+
+// loadModuleRelease reads the go.mod in dir, checking that its module path
+// suits version, and names the tag of the release: the version itself for
+// the module at the repository root, or prefixed with the module's directory
+// otherwise, as releaseTagPrefix describes. A major version subdirectory such
+// as api/v2 is not part of the prefix, as the go command looks for api/v2.x.y
+// tags.
+func loadModuleRelease(root, dir, version string) (moduleRelease, error) {
+	rel, err := repoRelativeDir(root, dir)
+	if err != nil {
+		return moduleRelease{}, err
+	}
+	f, err := readGoMod(filepath.Join(root, filepath.FromSlash(rel), "go.mod"))
+	if err != nil {
+		return moduleRelease{}, err
+	}
+	if f.Module == nil {
+		return moduleRelease{}, fmt.Errorf(tr("%s declares no module"), filepath.Join(dir, "go.mod"))
+	}
+	m := moduleRelease{Dir: rel, Path: f.Module.Mod.Path, Requires: map[string]string{}}
+	if _, pathMajor, _ := module.SplitPathVersion(m.Path); module.CheckPathMajor(version, pathMajor) != nil {
+		major := semver.Major(version)
+		if major == "v0" || major == "v1" {
+			return moduleRelease{}, fmt.Errorf(tr("cannot release %s as %s: %s versions require a module path without a major version suffix"), m.Path, version, major)
+		}
+		return moduleRelease{}, fmt.Errorf(tr("cannot release %s as %s: the module path must end in /%s"), m.Path, version, major)
+	}
+	for _, r := range f.Require {
+		m.Requires[r.Mod.Path] = r.Mod.Version
+	}
+
+	m.Tag = releaseTagPrefix(rel) + version
+	return m, nil
+}
+
+// repoRelativeDir returns the path of dir relative to the repository root,
+// slash-separated, failing when dir is outside of the repository.
+func repoRelativeDir(root, dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf(tr("%s is outside of the repository at %s"), dir, root)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// releaseTagPrefix returns the prefix of the release tags of the module in
+// dir, relative to the repository root and slash-separated: nothing for the
+// root, and the directory followed by a slash otherwise, without any major
+// version subdirectory.
+func releaseTagPrefix(dir string) string {
+	if dir == "." || dir == "" {
+		return ""
+	}
+	if p, major, ok := module.SplitPathVersion(dir); ok && major != "" {
+		dir = strings.TrimSuffix(p, "/")
+	}
+	if dir == "" {
+		return ""
+	}
+	return dir + "/"
+}
+
+// checkModuleReleases lists the reasons releases cannot be tagged as
+// version: a module listed twice, go.mod files with uncommitted changes,
+// tags that already exist, and modules requiring another module of the
+// release at a version other than the one being tagged.
+func checkModuleReleases(ctx context.Context, git *gitRunner, root string, releases []moduleRelease, version string) []string {
+	var problems []string
+	byPath := map[string]bool{}
+	for _, m := range releases {
+		if byPath[m.Path] {
+			problems = append(problems, trf("%s is listed more than once", m.Path))
+		}
+		byPath[m.Path] = true
+	}
+
+	for _, m := range releases {
+		gomod := path.Join(m.Dir, "go.mod")
+		if out, err := git.run(ctx, root, nil, "status", "--porcelain", "--", gomod); err != nil || out != "" {
+			problems = append(problems, trf("%s has uncommitted changes", gomod))
+		}
+		if _, err := git.run(ctx, root, nil, "rev-parse", "-q", "--verify", "refs/tags/"+m.Tag); err == nil {
+			problems = append(problems, trf("tag %s already exists", m.Tag))
+		}
+		for _, other := range releases {
+			if v, ok := m.Requires[other.Path]; ok && v != version {
+				problems = append(problems, trf("%s requires %s %s instead of %s", m.Path, other.Path, v, version))
+			}
+		}
+	}
+	return problems
+}
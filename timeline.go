@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/semver"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var timelineCommand = &cli.Command{
+	Name:      "timeline",
+	Usage:     "Prints the release history of a repository",
+	ArgsUsage: "repo-url",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "include-pre",
+			Usage: "Includes pre-release tags",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() != 1 {
+			return cli.ShowSubcommandHelp(ctx)
+		}
+
+		git, cfg, err := setup(ctx)
+		if err != nil {
+			return err
+		}
+		reqs, err := buildRequests(ctx.Args().Slice(), ctx.StringSlice("module-path"))
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+
+		s, err := openSession(ctx.Context, git, cfg, reqs[0])
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		defer s.close()
+		if err = s.fetchHistory(ctx.Context); err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+
+		releases, err := listReleases(ctx.Context, s, ctx.Bool("include-pre"))
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		if len(releases) == 0 {
+			fmt.Println(tr("No releases found"))
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, tr("TAG\tDATE\tINTERVAL\tCOMMITS"))
+		for i, r := range releases {
+			interval := "-"
+			if i > 0 {
+				interval = formatInterval(r.Date.Sub(releases[i-1].Date))
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", r.Tag, r.Date.Format(time.DateOnly), interval, r.Commits)
+		}
+		return w.Flush()
+	},
+}
+
+// release is a tagged version in a repository's history.
+type release struct {
+	Tag  string
+	Date time.Time
+	// Commits is the number of commits since the previous release.
+	Commits int
+}
+
+// listReleases returns the semver tags of the fully cloned repository in s,
+// in version order.
+func listReleases(ctx context.Context, s *repoSession, includePre bool) ([]release, error) {
+	git, repo := s.git, s.repo()
+	out, err := git.run(ctx, repo, nil, "for-each-ref", "refs/tags", "--format=%(refname:strip=2) %(creatordate:unix)")
+	if err != nil {
+		return nil, fmt.Errorf("failed listing tags: %w", err)
+	}
+
+	var releases []release
+	for _, line := range strings.Split(out, "\n") {
+		tag, ts, ok := strings.Cut(line, " ")
+		if !ok || !semver.IsValid(tag) || (!includePre && semver.Prerelease(tag) != "") {
+			continue
+		}
+		sec, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			continue
+		}
+		releases = append(releases, release{Tag: tag, Date: time.Unix(sec, 0).UTC()})
+	}
+	sort.Slice(releases, func(i, j int) bool { return semver.Compare(releases[i].Tag, releases[j].Tag) < 0 })
+
+	for i := range releases {
+		rng := releases[i].Tag
+		if i > 0 {
+			rng = releases[i-1].Tag + ".." + releases[i].Tag
+		}
+		count, err := git.run(ctx, repo, nil, "rev-list", "--count", rng)
+		if err != nil {
+			return nil, fmt.Errorf("failed counting commits for %s: %w", releases[i].Tag, err)
+		}
+		releases[i].Commits, _ = strconv.Atoi(count)
+	}
+	return releases, nil
+}
+
+// formatInterval formats d in days, or hours when shorter than a day.
+// Intervals are negative when a higher version was released before a lower
+// one, such as a patch to an older release line.
+func formatInterval(d time.Duration) string {
+	if d <= -time.Hour {
+		return "-" + formatInterval(-d)
+	}
+	if d < 24*time.Hour {
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
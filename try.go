@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+var tryCommand = &cli.Command{
+	Name:      "try",
+	Usage:     "Adds a repository to a throwaway module and opens a shell there, or runs a Go file in it",
+	ArgsUsage: "repo-url",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "run",
+			Usage: "Copies the Go `FILE` into the module as main.go and runs it instead of opening a shell",
+		},
+		&cli.BoolFlag{
+			Name:  "keep",
+			Usage: "Keeps the module once done, printing its location",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() != 1 {
+			return cli.ShowSubcommandHelp(ctx)
+		}
+		var source []byte
+		if p := ctx.String("run"); p != "" {
+			var err error
+			if source, err = os.ReadFile(p); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+		}
+
+		git, cfg, err := setup(ctx)
+		if err != nil {
+			return err
+		}
+		reqs, err := buildRequests(ctx.Args().Slice(), ctx.StringSlice("module-path"))
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		r, err := processRepo(ctx.Context, git, cfg, resolveOptions{}, reqs[0])
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		printWarnings(os.Stderr, r)
+
+		dir, err := os.MkdirTemp("", "grg-try-")
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		if ctx.Bool("keep") {
+			defer fmt.Printf(tr("The module was kept at %s\n"), dir)
+		} else {
+			defer func() { _ = os.RemoveAll(dir) }()
+		}
+		if err = initTryModule(dir, r); err != nil {
+			return cli.Exit(trf("Could not create the module: %s", err), 1)
+		}
+
+		var cmd *exec.Cmd
+		if source != nil {
+			if err = os.WriteFile(filepath.Join(dir, "main.go"), source, 0o644); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			cmd = exec.Command("go", "run", ".")
+		} else {
+			shell := userShell()
+			hint := tr("exit it to discard the module")
+			if ctx.Bool("keep") {
+				hint = tr("exit it when done")
+			}
+			fmt.Printf(tr("Opening %s in a module requiring %s %s; %s\n"), shell, r.Path, r.Version, hint)
+			cmd = exec.Command(shell)
+		}
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		// The exit status of a shell is that of the last command run in it,
+		// which says nothing about grg.
+		if err = cmd.Run(); err != nil && source != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		return nil
+	},
+}
+
+// initTryModule creates a module in dir requiring r, using the go directive
+// go mod init picks for the installed toolchain.
+func initTryModule(dir string, r resolution) error {
+	c := exec.Command("go", "mod", "init", "grg-try")
+	c.Dir = dir
+	if out, err := c.CombinedOutput(); err != nil {
+		return fmt.Errorf("go mod init failed: %s", out)
+	}
+	path := filepath.Join(dir, "go.mod")
+	f, err := readGoMod(path)
+	if err != nil {
+		return err
+	}
+	if err = f.AddRequire(r.Path, r.Version); err != nil {
+		return err
+	}
+	return writeGoMod(path, f)
+}
+
+// userShell returns the user's shell, falling back to the platform's
+// default one.
+func userShell() string {
+	if s := os.Getenv("SHELL"); s != "" {
+		return s
+	}
+	if runtime.GOOS == "windows" {
+		if s := os.Getenv("ComSpec"); s != "" {
+			return s
+		}
+		return "cmd.exe"
+	}
+	return "/bin/sh"
+}
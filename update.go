@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"os"
+	"strings"
+)
+
+var updateCommand = &cli.Command{
+	Name:  "update",
+	Usage: "Updates the requirements of the nearest go.mod to their latest versions",
+	Description: "Versions are selected as when printing require directives, so global flags, pins, policy\n" +
+		"and alignment groups apply, and requirements are never downgraded. By default updates stay\n" +
+		"within the required major version; --patch keeps the minor version as well, and --major\n" +
+		"allows any. New major versions of modules with a major version suffix are other modules,\n" +
+		"whose imports must change, so they are only reported.",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "only",
+			Usage: "Only updates modules matching `PATTERN`, a glob where a trailing * also matches nested paths (may be repeated)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "exclude",
+			Usage: "Leaves modules matching `PATTERN` alone (may be repeated)",
+		},
+		&cli.BoolFlag{
+			Name:  "major",
+			Usage: "Allows updating to any newer version",
+		},
+		&cli.BoolFlag{
+			Name:  "minor",
+			Usage: "Allows minor and patch updates (the default)",
+		},
+		&cli.BoolFlag{
+			Name:  "patch",
+			Usage: "Only allows patch updates",
+		},
+		&cli.BoolFlag{
+			Name:  "indirect",
+			Usage: "Also updates requirements marked // indirect",
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Prints the changes without writing go.mod",
+		},
+		&cli.StringFlag{
+			Name:  "modfile",
+			Usage: "Updates the go.mod file at `PATH` instead of the one found from the current directory",
+		},
+		smokeTestFlag,
+		changedOnlyFlag,
+		changedBaseFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		if err := validateSmokeTest(ctx.String("smoke-test")); err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		level := ""
+		for _, l := range []string{"major", "minor", "patch"} {
+			if !ctx.Bool(l) {
+				continue
+			}
+			if level != "" {
+				return cli.Exit(trf("--%s and --%s cannot be used together", level, l), 1)
+			}
+			level = l
+		}
+		if level != "" && ctx.IsSet("constraint") {
+			return cli.Exit(trf("--%s cannot be combined with --constraint", level), 1)
+		}
+		if level == "" {
+			level = "minor"
+		}
+
+		path, err := goModPath(ctx)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		mod, err := readGoMod(path)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		git, cfg, err := setup(ctx)
+		if err != nil {
+			return err
+		}
+		opts, err := resolveOptionsFromFlags(ctx)
+		if err != nil {
+			return err
+		}
+		if ctx.Bool("changed-only") {
+			changed, err := changedGoMods(ctx.Context, git, []*modfile.File{mod}, ctx.String("base"))
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			if len(changed) == 0 {
+				fmt.Printf(tr("No go.mod changed since %s\n"), ctx.String("base"))
+				return nil
+			}
+		}
+
+		var current []module.Version
+		var outcomes []outcome
+		for _, r := range mod.Require {
+			if (r.Indirect && !ctx.Bool("indirect")) || !selectedForUpdate(r.Mod.Path, ctx.StringSlice("only"), ctx.StringSlice("exclude")) {
+				continue
+			}
+			repoPath, _, _ := module.SplitPathVersion(r.Mod.Path)
+			req := repoRequest{Input: r.Mod.Path, ModulePath: repoPath}
+			res, err := processRepo(ctx.Context, git, cfg, updateOptions(opts, r.Mod.Version, level), req)
+			current = append(current, r.Mod)
+			outcomes = append(outcomes, outcome{req: req, r: res, err: err})
+		}
+		alignGroups(ctx.Context, git, cfg, opts, outcomes)
+
+		var changes []requireChange
+		var errs []string
+		for i, o := range outcomes {
+			m := current[i]
+			printWarnings(os.Stderr, o.r)
+			allowed := updateOptions(opts, m.Version, level).Constraint
+			switch {
+			case o.err != nil:
+				errs = append(errs, fmt.Sprintf("  %s: %s", m.Path, o.err))
+			case o.r.Path != m.Path:
+				fmt.Printf(tr("%s %s is available as %s, which requires changing imports\n"), m.Path, o.r.Version, o.r.Path)
+			case semver.Compare(o.r.Version, m.Version) <= 0:
+			case allowed != nil && !allowed.allows(o.r.Version):
+				fmt.Printf(tr("%s was aligned at %s, which a %s update does not allow\n"), m.Path, o.r.Version, level)
+			default:
+				changes = append(changes, requireChange{Path: m.Path, Old: m.Version, New: o.r.Version})
+			}
+		}
+
+		if len(changes) == 0 && len(errs) == 0 {
+			fmt.Println(tr("All requirements are up to date"))
+			return nil
+		}
+		if len(changes) > 0 {
+			fmt.Printf("--- %s\n+++ %s\n", path, path)
+			for _, c := range changes {
+				fmt.Printf("-\t%s %s\n+\t%s %s\n", c.Path, c.Old, c.Path, c.New)
+			}
+		}
+		if len(changes) > 0 && ctx.Bool("dry-run") {
+			fmt.Println(tr("No changes were written"))
+		} else if len(changes) > 0 {
+			backup, err := backupGoMod(path)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			for _, c := range changes {
+				if err = mod.AddRequire(c.Path, c.New); err != nil {
+					return cli.Exit(err.Error(), 1)
+				}
+			}
+			if err = writeGoMod(path, mod); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			if cmd := ctx.String("smoke-test"); cmd != "" {
+				if err = smokeTest(backup, cmd); err != nil {
+					return cli.Exit(err.Error(), 1)
+				}
+			}
+			fmt.Printf(tr("Wrote %s\n"), path)
+		}
+		if len(errs) > 0 {
+			return cli.Exit(tr("The following errors were found:")+"\n"+strings.Join(errs, "\n"), 1)
+		}
+		return nil
+	},
+}
+
+// selectedForUpdate reports whether the module at path passes the --only and
+// --exclude patterns.
+func selectedForUpdate(path string, only, exclude []string) bool {
+	for _, p := range exclude {
+		if groupMatches(p, path) {
+			return false
+		}
+	}
+	if len(only) == 0 {
+		return true
+	}
+	for _, p := range only {
+		if groupMatches(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateOptions returns opts restricted to the versions an update of a module
+// required at current may pick at level: the same minor version for patch,
+// the same major version for minor, and any version for major. Explicit
+// constraints and selection modes not picking among releases are kept, as
+// are modules without releases, which the restriction would rule out.
+func updateOptions(opts resolveOptions, current, level string) resolveOptions {
+	if opts.Constraint != nil || opts.Channel != "" || opts.Nightly || opts.Scheme != nil || isUntaggedPseudoVersion(current) {
+		return opts
+	}
+	switch level {
+	case "patch":
+		opts.Constraint, _ = parseConstraint(semver.MajorMinor(current))
+	case "minor":
+		opts.Constraint, _ = parseConstraint(semver.Major(current))
+	}
+	return opts
+}
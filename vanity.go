@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/heyvito/go-require-generator/pkg/input"
+	"io"
+	"strings"
+)
+
+// importMeta is a go-import meta tag, mapping the import paths under Prefix
+// to the repository at RepoRoot.
+type importMeta struct {
+	Prefix   string
+	VCS      string
+	RepoRoot string
+}
+
+// discoversImports reports whether the repositories of paths on host are
+// found through go-import meta tags before being probed. Hosts whose
+// repositories grg knows how to find, and those given a clone-url, are not.
+func discoversImports(cfg *Config, host string) bool {
+	if _, ok := input.FixedDepth(host); ok {
+		return false
+	}
+	return cfg.Hosts[host].CloneURL == ""
+}
+
+// discoverImport performs the discovery of the go command for the import
+// path path, requesting it with ?go-get=1 and returning the go-import meta
+// tag path falls under. Like the go command, a tag whose prefix is shorter
+// than path must be served for the prefix as well. ok is false when no tag
+// was found, as for hosts serving the repositories themselves.
+func discoverImport(ctx context.Context, git *gitRunner, path string) (meta importMeta, ok bool, err error) {
+	metas, err := fetchImportMetas(ctx, git, path)
+	if err != nil || len(metas) == 0 {
+		return importMeta{}, false, err
+	}
+	meta, ok, err = matchImportMeta(metas, path)
+	if err != nil || !ok {
+		return importMeta{}, false, err
+	}
+	if meta.Prefix != path {
+		prefixMetas, err := fetchImportMetas(ctx, git, meta.Prefix)
+		if err != nil {
+			return importMeta{}, false, err
+		}
+		if m, found, _ := matchImportMeta(prefixMetas, meta.Prefix); !found || m != meta {
+			return importMeta{}, false, fmt.Errorf("the go-import meta tag of %s, for %s, is not served for %s", path, meta.Prefix, meta.Prefix)
+		}
+	}
+	if meta.VCS != "git" {
+		return importMeta{}, false, fmt.Errorf("%s is hosted in a %s repository, which grg cannot resolve", meta.Prefix, meta.VCS)
+	}
+	return meta, true, nil
+}
+
+// fetchImportMetas requests path with ?go-get=1 over HTTPS, or over HTTP for
+// paths matching GOINSECURE when HTTPS fails, and returns the go-import meta
+// tags of the response. Responses are parsed whatever their status, as
+// some hosts serve the tags along with a 404.
+func fetchImportMetas(ctx context.Context, git *gitRunner, path string) ([]importMeta, error) {
+	schemes := []string{"https"}
+	if goEnv().insecure(path) {
+		schemes = append(schemes, "http")
+	}
+	var err error
+	for _, scheme := range schemes {
+		u := scheme + "://" + path + "?go-get=1"
+		git.logf("Fetching %s\n", u)
+		res, rerr := httpGet(ctx, u)
+		if rerr != nil {
+			err = rerr
+			continue
+		}
+		metas, perr := parseImportMetas(io.LimitReader(res.Body, maxMetaSize))
+		closeBody(res)
+		return metas, perr
+	}
+	if cause := contextErr(ctx); cause != nil {
+		return nil, cause
+	}
+	// Hosts unreachable over HTTP may still serve git, as over SSH.
+	git.logf("Could not discover the repository of %s: %s\n", path, err)
+	return nil, nil
+}
+
+// maxMetaSize bounds the part of a ?go-get=1 response searched for meta
+// tags, which are expected in its head.
+const maxMetaSize = 1 << 20
+
+// parseImportMetas returns the go-import meta tags of the HTML document in r,
+// up to the end of its head, leniently as the go command does.
+func parseImportMetas(r io.Reader) ([]importMeta, error) {
+	d := xml.NewDecoder(r)
+	d.CharsetReader = func(charset string, in io.Reader) (io.Reader, error) {
+		if strings.EqualFold(charset, "utf-8") || strings.EqualFold(charset, "ascii") {
+			return in, nil
+		}
+		return nil, fmt.Errorf("cannot decode charset %s", charset)
+	}
+	d.Strict = false
+	var metas []importMeta
+	for {
+		t, err := d.RawToken()
+		if err != nil {
+			if errors.Is(err, io.EOF) || len(metas) > 0 {
+				return metas, nil
+			}
+			return nil, err
+		}
+		if e, ok := t.(xml.StartElement); ok && strings.EqualFold(e.Name.Local, "body") {
+			return metas, nil
+		}
+		if e, ok := t.(xml.EndElement); ok && strings.EqualFold(e.Name.Local, "head") {
+			return metas, nil
+		}
+		e, ok := t.(xml.StartElement)
+		if !ok || !strings.EqualFold(e.Name.Local, "meta") || xmlAttr(e, "name") != "go-import" {
+			continue
+		}
+		if f := strings.Fields(xmlAttr(e, "content")); len(f) == 3 {
+			metas = append(metas, importMeta{Prefix: f[0], VCS: f[1], RepoRoot: f[2]})
+		}
+	}
+}
+
+func xmlAttr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if strings.EqualFold(a.Name.Local, name) {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// matchImportMeta returns the tag among metas whose prefix path falls under.
+// Tags for the mod protocol of module proxies are ignored, since grg reads
+// repositories, and more than one match is an error.
+func matchImportMeta(metas []importMeta, path string) (importMeta, bool, error) {
+	var match importMeta
+	found := false
+	for _, m := range metas {
+		if m.VCS == "mod" || (path != m.Prefix && !strings.HasPrefix(path, m.Prefix+"/")) {
+			continue
+		}
+		if found {
+			return importMeta{}, false, fmt.Errorf("%s matches more than one go-import meta tag, for %s and %s", path, match.Prefix, m.Prefix)
+		}
+		match, found = m, true
+	}
+	return match, found, nil
+}
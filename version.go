@@ -0,0 +1,432 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/heyvito/go-require-generator/pkg/resolver"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"strings"
+)
+
+// channels lists the pre-release channels accepted by --channel.
+var channels = []string{"alpha", "beta", "rc"}
+
+// resolveOptions holds the settings that affect how versions are selected.
+type resolveOptions struct {
+	// Channel restricts selection to pre-release tags of the given channel
+	// (e.g. "rc" matches v1.2.0-rc.1). Empty means no restriction.
+	Channel string
+	// Nightly ignores tags and always selects the pseudo-version of the
+	// default branch's HEAD.
+	Nightly bool
+	// Overrides holds the project's pinned versions. Pins do not apply to
+	// requests for an explicit version.
+	Overrides overrides
+	// Inspect gathers repoInfo about each resolved repository.
+	Inspect bool
+	// CrossCheck verifies every resolved version against the module proxy.
+	CrossCheck bool
+	// CacheDir is the persistent cache directory used to detect moved tags.
+	// Empty disables the cache.
+	CacheDir string
+	// AcceptMovedTag allows emitting a tag that now points to a different
+	// commit than when it was first seen.
+	AcceptMovedTag bool
+	// Platforms lists GOOS/GOARCH pairs whose prebuilt binaries are
+	// expected among the selected release's assets.
+	Platforms []string
+	// Scheme orders tags of repositories not following semver. Nil selects
+	// versions through semver.
+	Scheme *versionScheme
+	// VerifySignatures requires the selected version to carry a valid
+	// signature, made by a key the policy allows.
+	VerifySignatures bool
+	// WarnUnsigned warns when the selected tag carries no signature, which
+	// is otherwise only checked for modules a signature rule of the policy
+	// applies to.
+	WarnUnsigned bool
+	// Proxy resolves versions through the module proxies in GOPROXY instead
+	// of git.
+	Proxy bool
+	// Prereleases tells whether pre-release tags may be picked as the
+	// latest version.
+	Prereleases prereleaseMode
+	// Constraint restricts the latest version to a semver range. Nil
+	// allows any version.
+	Constraint *versionConstraint
+	// GoVersion is the Go release selected versions must build with, as
+	// given through --compatible-with-go. Empty allows any.
+	GoVersion string
+	// Registry is consulted before selecting among the tags of a
+	// repository. Nil disables it.
+	Registry *registry
+}
+
+func (o resolveOptions) validate() error {
+	if o.Nightly && o.Channel != "" {
+		return fmt.Errorf("--nightly and --channel cannot be used together")
+	}
+	if o.Scheme != nil && (o.Nightly || o.Channel != "") {
+		return fmt.Errorf("--version-scheme cannot be combined with --nightly or --channel")
+	}
+	if o.Prereleases != preFallback && (o.Channel != "" || o.Nightly) {
+		return fmt.Errorf("--include-pre and --stable-only cannot be combined with --channel or --nightly")
+	}
+	if o.Constraint != nil && (o.Channel != "" || o.Nightly || o.Scheme != nil) {
+		return fmt.Errorf("--constraint cannot be combined with --channel, --nightly or --version-scheme")
+	}
+	if err := validateGoVersion(o.GoVersion); err != nil {
+		return err
+	}
+	if o.Proxy && o.GoVersion != "" {
+		return fmt.Errorf("--proxy cannot be combined with --compatible-with-go")
+	}
+	if o.Proxy && (o.Nightly || o.Scheme != nil || o.VerifySignatures || o.WarnUnsigned || o.CrossCheck || o.Inspect) {
+		return fmt.Errorf("--proxy cannot be combined with --nightly, --version-scheme, --verify-signatures, --warn-unsigned, --cross-check or --inspect")
+	}
+	for _, p := range o.Platforms {
+		if err := validatePlatform(p); err != nil {
+			return err
+		}
+	}
+	return validateChannel(o.Channel)
+}
+
+func validateChannel(channel string) error {
+	if channel == "" {
+		return nil
+	}
+	for _, c := range channels {
+		if c == channel {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown channel %q, expected one of %s", channel, strings.Join(channels, ", "))
+}
+
+// inChannel reports whether version is a pre-release of the given channel.
+// Both dotted (-rc.1) and undotted (-rc1) forms are accepted.
+func inChannel(version, channel string) bool {
+	pre := strings.TrimPrefix(semver.Prerelease(version), "-")
+	if !strings.HasPrefix(pre, channel) {
+		return false
+	}
+	rest := pre[len(channel):]
+	return rest == "" || rest[0] == '.' || (rest[0] >= '0' && rest[0] <= '9')
+}
+
+// isUntaggedPseudoVersion reports whether v is a pseudo-version not based on
+// any tag, such as v0.0.0-20240102150405-abcdefabcdef, as used for modules
+// without releases.
+func isUntaggedPseudoVersion(v string) bool {
+	if !module.IsPseudoVersion(v) {
+		return false
+	}
+	base, _ := module.PseudoVersionBase(v)
+	return base == ""
+}
+
+// sanitizeVersion replaces a version of r taken from a tag the go command
+// would reject with the version of the commit the tag points to: a valid
+// tag at the same commit, or a pseudo-version. The tag is noted on the
+// require line, as for tags of other version schemes.
+func sanitizeVersion(ctx context.Context, s *repoSession, r resolution) (resolution, error) {
+	if !semver.IsValid(r.Version) || resolver.IsModuleVersion(r.Version) {
+		return r, nil
+	}
+	tags, err := s.remoteTags(ctx)
+	if err != nil {
+		return resolution{}, fmt.Errorf("failed listing tags: %w", err)
+	}
+	for _, t := range tags {
+		if t.Name != r.Version {
+			continue
+		}
+		reason := "it is not in canonical form"
+		if semver.Build(t.Name) != "" {
+			reason = "build metadata is not allowed"
+		}
+		res, err := resolveCommit(ctx, s, r.Path, t.Commit)
+		if err != nil {
+			return resolution{}, fmt.Errorf("tag %s is not a valid module version (%s), and its commit could not be resolved: %w", t.Name, reason, err)
+		}
+		res.Note, res.Warnings = t.Name, r.Warnings
+		res.warn(warnTagSanitized, "tag %s is not a valid module version (%s); using %s instead", t.Name, reason, res.Version)
+		return res, nil
+	}
+	return r, nil
+}
+
+// dedupeTags reduces tags that only differ in case or v prefix, such as
+// 1.2.3, V1.2.3 and v1.2.3, to the one usable as a module version, or
+// otherwise the lowest by byte order, so selection never depends on which
+// of them is listed first. The dropped tags are returned, keyed by the name
+// of the tag kept in their place.
+func dedupeTags(tags []remoteTag) ([]remoteTag, map[string][]remoteTag) {
+	key := func(name string) string {
+		return "v" + strings.TrimPrefix(strings.ToLower(name), "v")
+	}
+	preferred := func(a, b remoteTag) bool {
+		if resolver.IsModuleVersion(a.Name) != resolver.IsModuleVersion(b.Name) {
+			return resolver.IsModuleVersion(a.Name)
+		}
+		return a.Name < b.Name
+	}
+
+	keep := map[string]remoteTag{}
+	for _, t := range tags {
+		if k, ok := keep[key(t.Name)]; !ok || preferred(t, k) {
+			keep[key(t.Name)] = t
+		}
+	}
+	var result []remoteTag
+	dropped := map[string][]remoteTag{}
+	for _, t := range tags {
+		k := keep[key(t.Name)]
+		if k.Name == t.Name {
+			result = append(result, t)
+		} else {
+			dropped[k.Name] = append(dropped[k.Name], t)
+		}
+	}
+	return result, dropped
+}
+
+// reportDuplicateTags warns when the version of r was picked among tags
+// dedupeTags reduced, pointing out duplicates at other commits, which
+// others may resolve differently.
+func reportDuplicateTags(s *repoSession, r *resolution) {
+	dropped := s.duplicateTags[versionTag(r.Version)]
+	if len(dropped) == 0 {
+		return
+	}
+	commit := ""
+	for _, t := range s.tags {
+		if t.Name == versionTag(r.Version) {
+			commit = t.Commit
+		}
+	}
+	names := make([]string, len(dropped))
+	moved := false
+	for i, t := range dropped {
+		names[i] = t.Name
+		moved = moved || t.Commit != commit
+	}
+	msg := "tag %s is duplicated as %s, which only differ in case or v prefix; using %s"
+	if moved {
+		msg = "tag %s is duplicated as %s, which only differ in case or v prefix but point at other commits; using %s"
+	}
+	r.warn(warnDuplicateTags, msg, versionTag(r.Version), strings.Join(names, ", "), versionTag(r.Version))
+}
+
+// tagAtCommit returns the highest tag pointing at commit that is usable as a
+// module version.
+func tagAtCommit(tags []remoteTag, commit string) (remoteTag, bool) {
+	var best remoteTag
+	found := false
+	for _, t := range tags {
+		if t.Commit != commit || !resolver.IsModuleVersion(t.Name) {
+			continue
+		}
+		if !found || semver.Compare(t.Name, best.Name) > 0 {
+			best, found = t, true
+		}
+	}
+	return best, found
+}
+
+// prereleaseMode tells whether pre-release tags are eligible as the latest
+// version, as described by resolver.PrereleaseMode.
+type prereleaseMode = resolver.PrereleaseMode
+
+const (
+	preFallback = resolver.PreFallback
+	preInclude  = resolver.PreInclude
+	preExclude  = resolver.PreExclude
+)
+
+// latestAllowed returns the latest version of the module at path among tags
+// that opts allows: satisfying its constraint, with pre-releases eligible
+// according to its mode. When goMod is given, it reads the go.mod of the
+// module at a tag, and tags the go command cannot use as versions of path
+// are skipped, as resolver.LatestModuleVersion describes; without it, as for
+// versions listed by a proxy, every tag is assumed usable. ok is false when
+// there is no semver tag at all; a constraint no tag satisfies is an error.
+func latestAllowed(path string, tags []remoteTag, opts resolveOptions, goMod func(remoteTag) ([]byte, error)) (tag remoteTag, ok bool, err error) {
+	if opts.Constraint != nil {
+		if tags = opts.Constraint.filter(tags); len(tags) == 0 {
+			return remoteTag{}, false, fmt.Errorf("no version satisfies the constraint %s", opts.Constraint)
+		}
+	}
+	if goMod == nil {
+		return resolver.LatestVersion(tags, opts.Prereleases)
+	}
+	tag, _, ok, err = resolver.LatestModuleVersion(path, tags, opts.Prereleases, goMod)
+	return tag, ok, err
+}
+
+// latestInChannel returns the highest semver tag that belongs to the given
+// pre-release channel.
+func latestInChannel(tags []remoteTag, channel string) (remoteTag, bool) {
+	var best remoteTag
+	found := false
+	for _, t := range tags {
+		if !semver.IsValid(t.Name) || !inChannel(t.Name, channel) {
+			continue
+		}
+		if !found || semver.Compare(t.Name, best.Name) > 0 {
+			best, found = t, true
+		}
+	}
+	return best, found
+}
+
+// versionTag returns the name of the tag version was selected from, which
+// lacks the +incompatible suffix of versions of modules without a go.mod.
+func versionTag(version string) string {
+	return strings.TrimSuffix(version, "+incompatible")
+}
+
+// versionCommit returns the full SHA of the commit r.Version refers to in
+// the clone in s.
+func versionCommit(ctx context.Context, s *repoSession, r resolution) (string, error) {
+	if module.IsPseudoVersion(r.Version) {
+		rev, err := module.PseudoVersionRev(r.Version)
+		if err != nil {
+			return "", err
+		}
+		if err = s.ensureClone(ctx); err != nil {
+			return "", err
+		}
+		return s.git.run(ctx, s.repo(), nil, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	}
+
+	tags, err := s.remoteTags(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed listing tags: %w", err)
+	}
+	for _, t := range tags {
+		if t.Name == versionTag(r.Version) {
+			return t.Commit, nil
+		}
+	}
+	return "", fmt.Errorf("tag %s was not found", versionTag(r.Version))
+}
+
+// crossCheck compares the commit r resolved to against what the module
+// proxy reports for the same version, failing when they disagree. A
+// mismatch usually means a tag was moved after the proxy cached it.
+func crossCheck(ctx context.Context, s *repoSession, r *resolution) error {
+	commit, err := versionCommit(ctx, s, *r)
+	if err != nil {
+		return fmt.Errorf("cannot cross-check %s: %w", r.Path, err)
+	}
+
+	var info *proxyInfo
+	var proxy string
+	err = s.git.stage(ctx, "proxy", func(ctx context.Context) (err error) {
+		info, proxy, err = proxyLookup(ctx, r.Path, r.Version)
+		return err
+	})
+	switch {
+	case errors.Is(err, errProxyNotFound):
+		r.warn(warnCrossCheckSkipped, "%s is not available on any proxy; cross-check skipped", r.Version)
+		return nil
+	case errors.Is(err, errProxyDirect):
+		r.warn(warnCrossCheckSkipped, "%s is fetched directly according to GOPROXY and GONOPROXY; cross-check skipped", r.Version)
+		return nil
+	case err != nil:
+		return fmt.Errorf("cannot cross-check %s: %w", r.Path, err)
+	}
+
+	if info.Version != r.Version {
+		return fmt.Errorf("cross-check failed: %s resolves %s as %s, but git resolved %s", proxy, r.Path, info.Version, r.Version)
+	}
+	if info.Origin == nil || info.Origin.Hash == "" {
+		s.git.logf("%s does not report the origin of %s %s; only the version was compared\n", proxy, r.Path, r.Version)
+		return nil
+	}
+	if !strings.HasPrefix(info.Origin.Hash, commit) && !strings.HasPrefix(commit, info.Origin.Hash) {
+		hint := ""
+		if !module.IsPseudoVersion(r.Version) {
+			hint = " (was the tag moved?)"
+		}
+		return fmt.Errorf("cross-check failed: %s %s points to %s in git, but to %s on %s%s", r.Path, r.Version, commit, info.Origin.Hash, proxy, hint)
+	}
+	s.git.logf("Cross-check of %s %s against %s succeeded\n", r.Path, r.Version, proxy)
+	return nil
+}
+
+// queryOperators lists the comparison operators of version queries, longest
+// first so <= is not mistaken for <.
+var queryOperators = []string{"<=", ">=", "<", ">"}
+
+// isVersionQuery reports whether ref is a go get version query rather than
+// the name of a commit, tag or branch: a comparison such as <v1.2.3, or a
+// version prefix such as v1 or v1.2.
+func isVersionQuery(ref string) bool {
+	for _, op := range queryOperators {
+		if strings.HasPrefix(ref, op) {
+			return true
+		}
+	}
+	return semver.IsValid(ref) && semver.Build(ref) == "" && semver.Canonical(ref) != ref
+}
+
+// matchVersionQuery picks the tag go get path@query would. Prefixes select
+// the highest matching version, < and <= the highest version below the
+// bound, and > and >= the lowest above it. Releases are preferred over
+// pre-releases, which are only picked when no release matches.
+func matchVersionQuery(tags []remoteTag, query string) (remoteTag, error) {
+	op, bound := "", query
+	for _, o := range queryOperators {
+		if rest, ok := strings.CutPrefix(query, o); ok {
+			op, bound = o, rest
+			break
+		}
+	}
+	if !semver.IsValid(bound) {
+		return remoteTag{}, fmt.Errorf("invalid version query %s: %s is not a semantic version", query, bound)
+	}
+
+	matches := func(v string) bool {
+		c := semver.Compare(v, bound)
+		switch op {
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		case ">=":
+			return c >= 0
+		}
+		// v1 matches every v1.x.y, and v1.2 every v1.2.y.
+		return semver.Major(v) == bound || semver.MajorMinor(v) == bound
+	}
+	lowest := op == ">" || op == ">="
+
+	var release, pre remoteTag
+	for _, t := range tags {
+		if !semver.IsValid(t.Name) || !matches(t.Name) {
+			continue
+		}
+		best := &release
+		if semver.Prerelease(t.Name) != "" {
+			best = &pre
+		}
+		c := semver.Compare(t.Name, best.Name)
+		if best.Name == "" || (lowest && c < 0) || (!lowest && c > 0) {
+			*best = t
+		}
+	}
+	switch {
+	case release.Name != "":
+		return release, nil
+	case pre.Name != "":
+		return pre, nil
+	}
+	return remoteTag{}, fmt.Errorf("no version matches %s", query)
+}
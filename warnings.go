@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// warningCode identifies a class of warning. Codes are stable across
+// releases so automation can react to specific classes; new classes get new
+// codes, and codes are never reused.
+type warningCode string
+
+const (
+	// warnPseudoVersion: no suitable tag was found and a pseudo-version was
+	// emitted instead.
+	warnPseudoVersion warningCode = "GRG001"
+	// warnShallowClone: the shallow clone could not see tags that exist on
+	// the remote, so the result may differ from a full resolution. No longer
+	// emitted since versions are picked among every advertised tag.
+	warnShallowClone warningCode = "GRG002"
+	// warnUnsignedTag: the selected tag carries no signature. Only checked
+	// with --warn-unsigned, or for modules with a signature rule.
+	warnUnsignedTag warningCode = "GRG003"
+	// warnPathGuessed: the repository root was found by probing, and is
+	// shorter than the requested path.
+	warnPathGuessed warningCode = "GRG004"
+	// warnPinnedOutdated: a pin from .grg-overrides is older than the
+	// latest version.
+	warnPinnedOutdated warningCode = "GRG005"
+	// warnMovedTagAccepted: a moved tag was emitted through
+	// --accept-moved-tag.
+	warnMovedTagAccepted warningCode = "GRG006"
+	// warnCrossCheckSkipped: --cross-check could not consult a proxy.
+	warnCrossCheckSkipped warningCode = "GRG007"
+	// warnPolicyDowngrade: the latest version exceeded a policy ceiling and
+	// an older release was selected.
+	warnPolicyDowngrade warningCode = "GRG008"
+	// warnRemoteOnly: no clone could be made, and the version was picked from
+	// the advertised tags alone.
+	warnRemoteOnly warningCode = "GRG009"
+	// warnNoPrebuiltBinary: the selected release lacks a binary for one of
+	// the platforms given through --platform.
+	warnNoPrebuiltBinary warningCode = "GRG010"
+	// warnModulePathMismatch: the go.mod of the selected version declares a
+	// module path other than the requested one, and the declared path was
+	// emitted.
+	warnModulePathMismatch warningCode = "GRG011"
+	// warnGroupAlignment: the version was changed to match the other members
+	// of its alignment group, or the group could not be aligned.
+	warnGroupAlignment warningCode = "GRG012"
+	// warnTagSanitized: the selected tag is not a valid module version, such
+	// as one carrying build metadata, and the version of its commit was
+	// emitted instead.
+	warnTagSanitized warningCode = "GRG013"
+	// warnDuplicateTags: the selected tag has duplicates only differing in
+	// case or v prefix, which were ignored.
+	warnDuplicateTags warningCode = "GRG014"
+	// warnGoVersionDowngrade: the latest version requires a Go release newer
+	// than the one given through --compatible-with-go, and an older version
+	// was selected.
+	warnGoVersionDowngrade warningCode = "GRG015"
+	// warnRetracted: the latest version is retracted by the module's own
+	// go.mod and an older version was selected, or an explicitly requested
+	// version is retracted.
+	warnRetracted warningCode = "GRG016"
+)
+
+// warning is a non-fatal condition found while resolving a repository.
+type warning struct {
+	Code    warningCode `json:"code"`
+	Message string      `json:"message"`
+}
+
+// warn records a warning on r.
+func (r *resolution) warn(code warningCode, format string, args ...any) {
+	r.Warnings = append(r.Warnings, warning{Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// printWarnings writes r's warnings in the text output format.
+func printWarnings(w io.Writer, r resolution) {
+	for _, wn := range r.Warnings {
+		_, _ = fmt.Fprintf(w, tr("warning[%s]: %s: %s\n"), wn.Code, r.Path, wn.Message)
+	}
+}